@@ -1,28 +1,76 @@
 package config
 
 import (
-	"fmt"
+	"log/slog"
 	"os"
+	"time"
 
 	"github.com/jackc/pgx"
 	db "iammati/statuspage/db"
+	"iammati/statuspage/store"
 )
 
-func Database() *pgx.Conn {
-	connConfig := pgx.ConnConfig{
-		Host:     "statuspage-db",
-		Port:     5432,
-		Database: "statuspage",
-		User:     "statuspage",
-		Password: "statuspage",
+var Store store.Store
+
+func connConfig() pgx.ConnConfig {
+	return pgx.ConnConfig{
+		Host:     AppSettings.Database.Host,
+		Port:     uint16(AppSettings.Database.Port),
+		Database: AppSettings.Database.Name,
+		User:     AppSettings.Database.User,
+		Password: AppSettings.Database.Password,
+	}
+}
+
+// Connect opens a single connection using the database settings.
+func Connect() (*pgx.Conn, error) {
+	return pgx.Connect(connConfig())
+}
+
+// Database opens the connection pool, retrying while the database is still
+// starting up, and applies all pending migrations.
+func Database() *pgx.ConnPool {
+	var pool *pgx.ConnPool
+	var err error
+	delay := time.Second
+	for attempt := 0; ; attempt++ {
+		pool, err = pgx.NewConnPool(pgx.ConnPoolConfig{
+			ConnConfig:     connConfig(),
+			MaxConnections: AppSettings.Database.MaxConnections,
+			AcquireTimeout: AppSettings.Database.AcquireTimeout,
+		})
+		if err == nil || !db.IsTransient(err) || attempt >= AppSettings.Database.Retries {
+			break
+		}
+		slog.Warn("Database not reachable yet, retrying", "delay", delay, "error", err)
+		time.Sleep(delay)
+		delay *= 2
 	}
-	conn, err := pgx.Connect(connConfig)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Unable to connect to database: %v\n", err)
+		slog.Error("Unable to connect to database", "error", err)
 		os.Exit(1)
 	}
 
+	conn, err := pool.Acquire()
+	if err != nil {
+		slog.Error("Unable to acquire database connection", "error", err)
+		os.Exit(1)
+	}
 	db.Migrations(conn)
+	pool.Release(conn)
 
-	return conn
+	return pool
+}
+
+// OpenStore returns the store backend selected by the settings.
+func OpenStore() store.Store {
+	if AppSettings.StoreDriver == store.DriverMemory {
+		slog.Warn("Using the in-memory store, nothing will be persisted")
+		return store.NewMemory()
+	}
+	return store.NewPostgres(Database(), store.PostgresOptions{
+		QueryTimeout: AppSettings.Database.QueryTimeout,
+		Retries:      AppSettings.Database.Retries,
+		LogBuffer:    AppSettings.Database.LogBuffer,
+	})
 }
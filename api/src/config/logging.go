@@ -0,0 +1,88 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"iammati/statuspage/store"
+)
+
+// ParseLogLevel accepts debug, info, warn or error in any case.
+func ParseLogLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return level, fmt.Errorf("unknown level '%s', must be debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+// NewLogger writes records at the configured level or above to w as text or
+// JSON. Records are handed to sink as well, if given, which decides on its
+// own which levels it keeps.
+func NewLogger(settings LogSettings, w io.Writer, sink slog.Handler) *slog.Logger {
+	level, _ := ParseLogLevel(settings.Level)
+	options := &slog.HandlerOptions{Level: level}
+
+	var console slog.Handler = slog.NewTextHandler(w, options)
+	if settings.Format == LogJSON {
+		console = slog.NewJSONHandler(w, options)
+	}
+	if sink == nil {
+		return slog.New(console)
+	}
+	return slog.New(fanout{console, sink})
+}
+
+// LogToStore makes the default logger keep the records at the configured
+// database levels in s.
+func LogToStore(s store.Store) {
+	var levels []slog.Level
+	for _, name := range AppSettings.Logging.DatabaseLevels {
+		if level, err := ParseLogLevel(name); err == nil {
+			levels = append(levels, level)
+		}
+	}
+	slog.SetDefault(NewLogger(AppSettings.Logging, os.Stdout, store.NewLogHandler(s, levels...)))
+}
+
+// fanout hands every record to each handler that accepts its level.
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range f {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, handler := range f {
+		if handler.Enabled(ctx, record.Level) {
+			errs = append(errs, handler.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanout, len(f))
+	for i, handler := range f {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	handlers := make(fanout, len(f))
+	for i, handler := range f {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return handlers
+}
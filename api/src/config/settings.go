@@ -0,0 +1,638 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"iammati/statuspage/db"
+	"iammati/statuspage/store"
+)
+
+// Settings holds the application configuration. Every field is resolved
+// from its `default` tag, then the optional settings file, then its `env`
+// variable. Fields tagged `secret` are redacted when dumped.
+type Settings struct {
+	HTTP          HTTPSettings         `yaml:"http" json:"http"`
+	StoreDriver   string               `yaml:"storeDriver" json:"storeDriver" env:"STORE_DRIVER" default:"postgres"`
+	Database      DatabaseSettings     `yaml:"database" json:"database"`
+	AppKey        string               `yaml:"appKey" json:"appKey" env:"APP_KEY" secret:"true"`
+	CACertDir     string               `yaml:"caCertDir" json:"caCertDir" env:"CA_CERT_DIR" default:"/usr/local/share/ca-certificates"`
+	MonitorsFile  string               `yaml:"monitorsFile" json:"monitorsFile" env:"MONITORS_FILE" default:"monitors.yaml"`
+	Flapping      FlappingSettings     `yaml:"flapping" json:"flapping"`
+	Notifications NotificationSettings `yaml:"notifications" json:"notifications"`
+	Metrics       MetricsSettings      `yaml:"metrics" json:"metrics"`
+	Tracing       TracingSettings      `yaml:"tracing" json:"tracing"`
+	Logging       LogSettings          `yaml:"logging" json:"logging"`
+	Discovery     DiscoverySettings    `yaml:"discovery" json:"discovery"`
+	CRD           CRDSettings          `yaml:"crd" json:"crd"`
+	Kubeconfig    string               `yaml:"kubeconfig" json:"kubeconfig" env:"KUBECONFIG"`
+}
+
+type HTTPSettings struct {
+	Port            int           `yaml:"port" json:"port" env:"HTTP_PORT" default:"8080"`
+	CORSOrigin      string        `yaml:"corsOrigin" json:"corsOrigin" env:"HTTP_CORS_ORIGIN" default:"http://app:3000"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" json:"shutdownTimeout" env:"HTTP_SHUTDOWN_TIMEOUT" default:"5s"`
+}
+
+type DatabaseSettings struct {
+	Host     string `yaml:"host" json:"host" env:"DB_HOST" default:"statuspage-db"`
+	Port     int    `yaml:"port" json:"port" env:"DB_PORT" default:"5432"`
+	Name     string `yaml:"name" json:"name" env:"DB_NAME" default:"statuspage"`
+	User     string `yaml:"user" json:"user" env:"DB_USER" default:"statuspage"`
+	Password string `yaml:"password" json:"password" env:"DB_PASSWORD" default:"statuspage" secret:"true"`
+
+	MaxConnections int           `yaml:"maxConnections" json:"maxConnections" env:"DB_MAX_CONNECTIONS" default:"10"`
+	AcquireTimeout time.Duration `yaml:"acquireTimeout" json:"acquireTimeout" env:"DB_ACQUIRE_TIMEOUT" default:"5s"`
+	QueryTimeout   time.Duration `yaml:"queryTimeout" json:"queryTimeout" env:"DB_QUERY_TIMEOUT" default:"5s"`
+	Retries        int           `yaml:"retries" json:"retries" env:"DB_RETRIES" default:"3"`
+	LogBuffer      int           `yaml:"logBuffer" json:"logBuffer" env:"DB_LOG_BUFFER" default:"1024"`
+}
+
+// FlappingSettings configure flap detection; a window of 0 disables it.
+type FlappingSettings struct {
+	Window        int     `yaml:"window" json:"window" env:"FLAP_WINDOW" default:"20"`
+	HighThreshold float64 `yaml:"highThreshold" json:"highThreshold" env:"FLAP_HIGH_THRESHOLD" default:"0.5"`
+	LowThreshold  float64 `yaml:"lowThreshold" json:"lowThreshold" env:"FLAP_LOW_THRESHOLD" default:"0.25"`
+}
+
+// MetricsSettings configure the Prometheus endpoint on /metrics. The
+// certificates of the monitors are checked every CertInterval.
+type MetricsSettings struct {
+	Enabled      bool          `yaml:"enabled" json:"enabled" env:"METRICS_ENABLED" default:"true"`
+	CertInterval time.Duration `yaml:"certInterval" json:"certInterval" env:"METRICS_CERT_INTERVAL" default:"1h"`
+}
+
+// Tracing exporters.
+const (
+	TracingNone   = "none"
+	TracingStdout = "stdout"
+	TracingOTLP   = "otlp"
+)
+
+// TracingSettings configure where OpenTelemetry spans are exported to:
+// nowhere, stdout or an OTLP/HTTP collector. The OTLP endpoint falls back to
+// the standard OTEL_EXPORTER_OTLP_* variables when unset.
+type TracingSettings struct {
+	Exporter     string  `yaml:"exporter" json:"exporter" env:"TRACING_EXPORTER" default:"none"`
+	OTLPEndpoint string  `yaml:"otlpEndpoint" json:"otlpEndpoint" env:"TRACING_OTLP_ENDPOINT"`
+	ServiceName  string  `yaml:"serviceName" json:"serviceName" env:"TRACING_SERVICE_NAME" default:"statuspage"`
+	SampleRatio  float64 `yaml:"sampleRatio" json:"sampleRatio" env:"TRACING_SAMPLE_RATIO" default:"1"`
+}
+
+// Log output formats.
+const (
+	LogText = "text"
+	LogJSON = "json"
+)
+
+// LogSettings configure the application log. Records at Level or above are
+// written to stdout as text or JSON; those at one of DatabaseLevels are kept
+// in the logs table as well.
+type LogSettings struct {
+	Level          string   `yaml:"level" json:"level" env:"LOG_LEVEL" default:"info"`
+	Format         string   `yaml:"format" json:"format" env:"LOG_FORMAT" default:"text"`
+	DatabaseLevels []string `yaml:"databaseLevels" json:"databaseLevels" env:"LOG_DATABASE_LEVELS" default:"info,warn,error"`
+}
+
+// DiscoverySettings configure how monitors are discovered from the pods,
+// Ingresses and Services of the Kubernetes cluster. Every Resync, all
+// objects are synced again. Targets can only be declared in the settings
+// file.
+type DiscoverySettings struct {
+	Enabled bool          `yaml:"enabled" json:"enabled" env:"DISCOVERY_ENABLED" default:"true"`
+	Resync  time.Duration `yaml:"resync" json:"resync" env:"DISCOVERY_RESYNC" default:"10m"`
+	// OptIn only discovers objects annotated with statuspage/enabled=true.
+	OptIn bool `yaml:"optIn" json:"optIn" env:"DISCOVERY_OPT_IN" default:"false"`
+	// Targets select the pods to discover. Without targets, the pods
+	// labelled workload-class=webstack-php in sh-jenniferwalker are.
+	Targets   []DiscoveryTarget `yaml:"targets" json:"targets"`
+	Ingresses IngressDiscovery  `yaml:"ingresses" json:"ingresses"`
+	Services  ServiceDiscovery  `yaml:"services" json:"services"`
+}
+
+// ServiceDiscovery watches the Services in Namespaces, or all namespaces if
+// empty. A Service is only monitored if it names its hosts in the
+// statuspage/host annotation.
+type ServiceDiscovery struct {
+	Enabled    bool     `yaml:"enabled" json:"enabled" env:"DISCOVERY_SERVICES" default:"true"`
+	Namespaces []string `yaml:"namespaces" json:"namespaces" env:"DISCOVERY_SERVICE_NAMESPACES"`
+}
+
+// IngressDiscovery turns the hosts of the Ingresses in Namespaces, or all
+// namespaces if empty, matching Selector into HTTP monitors, and their TLS
+// hosts into certificate monitors if Certificates is set. HTTPRoutes are
+// discovered as well if the Gateway API is installed.
+type IngressDiscovery struct {
+	Enabled      bool            `yaml:"enabled" json:"enabled" env:"DISCOVERY_INGRESSES" default:"true"`
+	HTTPRoutes   bool            `yaml:"httpRoutes" json:"httpRoutes" env:"DISCOVERY_HTTPROUTES" default:"true"`
+	Certificates bool            `yaml:"certificates" json:"certificates" env:"DISCOVERY_CERTIFICATES" default:"true"`
+	Namespaces   []string        `yaml:"namespaces" json:"namespaces" env:"DISCOVERY_INGRESS_NAMESPACES"`
+	Selector     string          `yaml:"selector" json:"selector" env:"DISCOVERY_INGRESS_SELECTOR"`
+	Monitor      MonitorTemplate `yaml:"monitor" json:"monitor"`
+}
+
+// DiscoveryTarget turns the pods it selects into monitors, one per domain
+// found in EnvVars (CLIENT_DOMAIN if empty). Pods are selected from
+// Namespaces, or all namespaces if empty, whose labels match
+// NamespaceSelector; within those, pods matching any of PodSelectors, or
+// every pod if empty.
+type DiscoveryTarget struct {
+	Name              string          `yaml:"name" json:"name"`
+	Namespaces        []string        `yaml:"namespaces" json:"namespaces"`
+	NamespaceSelector string          `yaml:"namespaceSelector" json:"namespaceSelector"`
+	PodSelectors      []string        `yaml:"podSelectors" json:"podSelectors"`
+	EnvVars           []string        `yaml:"envVars" json:"envVars"`
+	Monitor           MonitorTemplate `yaml:"monitor" json:"monitor"`
+}
+
+// MonitorTemplate holds the settings of discovered monitors. The group
+// defaults to the namespace of the discovered object.
+type MonitorTemplate struct {
+	Path             string        `yaml:"path" json:"path"`
+	Group            string        `yaml:"group" json:"group"`
+	Tags             []string      `yaml:"tags" json:"tags"`
+	Interval         time.Duration `yaml:"interval" json:"interval"`
+	Timeout          time.Duration `yaml:"timeout" json:"timeout"`
+	ExpectedStatus   []int         `yaml:"expectedStatus" json:"expectedStatus"`
+	SLO              float64       `yaml:"slo" json:"slo"`
+	FailureThreshold int           `yaml:"failureThreshold" json:"failureThreshold"`
+	SuccessThreshold int           `yaml:"successThreshold" json:"successThreshold"`
+}
+
+// CRDSettings configure the controller of Monitor resources in Namespace, or
+// all namespaces if empty. Their status is written back every
+// StatusInterval.
+type CRDSettings struct {
+	Enabled        bool          `yaml:"enabled" json:"enabled" env:"CRD_ENABLED" default:"true"`
+	Namespace      string        `yaml:"namespace" json:"namespace" env:"CRD_NAMESPACE"`
+	StatusInterval time.Duration `yaml:"statusInterval" json:"statusInterval" env:"CRD_STATUS_INTERVAL" default:"30s"`
+}
+
+// NotificationSettings configure how notifications are delivered. Channels
+// and routes can only be declared in the settings file.
+type NotificationSettings struct {
+	Retries      int           `yaml:"retries" json:"retries" env:"NOTIFY_RETRIES" default:"5"`
+	RetryBackoff time.Duration `yaml:"retryBackoff" json:"retryBackoff" env:"NOTIFY_RETRY_BACKOFF" default:"2s"`
+	Timeout      time.Duration `yaml:"timeout" json:"timeout" env:"NOTIFY_TIMEOUT" default:"10s"`
+	// TemplatesDir optionally holds *.tmpl files overriding the built-in
+	// message templates.
+	TemplatesDir string `yaml:"templatesDir" json:"templatesDir" env:"NOTIFY_TEMPLATES_DIR"`
+	// IncidentURL links messages to an incident; {id} is replaced with its id.
+	IncidentURL string            `yaml:"incidentUrl" json:"incidentUrl" env:"NOTIFY_INCIDENT_URL"`
+	SMTP        SMTPSettings      `yaml:"smtp" json:"smtp"`
+	Channels    []ChannelSettings `yaml:"channels" json:"channels"`
+	// Routes decide which channels an event goes to. Without routes, every
+	// channel receives the events it subscribed to.
+	Routes []RouteSettings `yaml:"routes" json:"routes"`
+	// EscalationInterval is how often pending escalations and repeats are checked.
+	EscalationInterval time.Duration `yaml:"escalationInterval" json:"escalationInterval" env:"NOTIFY_ESCALATION_INTERVAL" default:"15s"`
+}
+
+// RouteSettings send the matching events to Channels right away and, while
+// the alert isn't acknowledged or resolved, escalate it step by step and
+// repeat it every RepeatInterval. Routes are tried in order; the first match
+// wins unless it sets Continue.
+type RouteSettings struct {
+	Name           string           `yaml:"name" json:"name"`
+	Match          RouteMatch       `yaml:"match" json:"match"`
+	Channels       []string         `yaml:"channels" json:"channels"`
+	Escalation     []EscalationStep `yaml:"escalation" json:"escalation"`
+	RepeatInterval time.Duration    `yaml:"repeatInterval" json:"repeatInterval"`
+	Continue       bool             `yaml:"continue" json:"continue"`
+}
+
+// RouteMatch criteria must all match; within a list any value does. Empty
+// criteria match everything.
+type RouteMatch struct {
+	Tags       []string `yaml:"tags" json:"tags"`
+	Groups     []string `yaml:"groups" json:"groups"`
+	Severities []string `yaml:"severities" json:"severities"`
+	Events     []string `yaml:"events" json:"events"`
+}
+
+// EscalationStep notifies more channels once an alert has been unacknowledged
+// for After.
+type EscalationStep struct {
+	After    time.Duration `yaml:"after" json:"after"`
+	Channels []string      `yaml:"channels" json:"channels"`
+}
+
+// SMTP TLS modes.
+const (
+	SMTPStartTLS = "starttls"
+	SMTPTLS      = "tls"
+	SMTPNoTLS    = "none"
+)
+
+// SMTPSettings configure the server email channels send through. TLS is
+// either starttls, tls (implicit TLS, usually on port 465) or none.
+type SMTPSettings struct {
+	Host     string `yaml:"host" json:"host" env:"SMTP_HOST"`
+	Port     int    `yaml:"port" json:"port" env:"SMTP_PORT" default:"587"`
+	Username string `yaml:"username" json:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" json:"password" env:"SMTP_PASSWORD" secret:"true"`
+	From     string `yaml:"from" json:"from" env:"SMTP_FROM" default:"statuspage@localhost"`
+	TLS      string `yaml:"tls" json:"tls" env:"SMTP_TLS" default:"starttls"`
+}
+
+// Notification channel types.
+const (
+	ChannelWebhook    = "webhook"
+	ChannelEmail      = "email"
+	ChannelSlack      = "slack"
+	ChannelMattermost = "mattermost"
+	ChannelDiscord    = "discord"
+	ChannelTeams      = "teams"
+)
+
+// ChannelSettings declare a single notification channel. Events limits the
+// channel to the listed event types; it receives every event when empty.
+// Emails need the recipients in To, every other type an incoming webhook URL.
+type ChannelSettings struct {
+	Name   string   `yaml:"name" json:"name"`
+	Type   string   `yaml:"type" json:"type"`
+	URL    string   `yaml:"url" json:"url" secret:"true"`
+	Secret string   `yaml:"secret" json:"secret" secret:"true"`
+	To     []string `yaml:"to" json:"to"`
+	Events []string `yaml:"events" json:"events"`
+}
+
+// SettingsFileEnv names the variable pointing at an optional YAML/JSON settings file.
+const SettingsFileEnv = "STATUSPAGE_CONFIG"
+
+const redacted = "[redacted]"
+
+// AppSettings starts out with the defaults so packages used without
+// Bootstrap (e.g. in tests) still see sane values.
+var AppSettings = DefaultSettings()
+
+func DefaultSettings() Settings {
+	var settings Settings
+	if err := walkSettings(reflect.ValueOf(&settings).Elem(), func(field reflect.Value, tag reflect.StructTag) error {
+		if value, ok := tag.Lookup("default"); ok {
+			return setField(field, value)
+		}
+		return nil
+	}); err != nil {
+		panic(fmt.Errorf("invalid default settings: %v", err))
+	}
+	return settings
+}
+
+// LoadSettings resolves the settings from defaults, the optional settings
+// file and the environment, in that order, and validates the result.
+func LoadSettings() (Settings, error) {
+	settings := DefaultSettings()
+
+	if path := os.Getenv(SettingsFileEnv); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return settings, fmt.Errorf("reading settings file: %v", err)
+		}
+		if err := yaml.Unmarshal(data, &settings); err != nil {
+			return settings, fmt.Errorf("parsing settings file %s: %v", path, err)
+		}
+	}
+
+	err := walkSettings(reflect.ValueOf(&settings).Elem(), func(field reflect.Value, tag reflect.StructTag) error {
+		name := tag.Get("env")
+		if name == "" {
+			return nil
+		}
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			if err := setField(field, value); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return settings, err
+	}
+
+	return settings, settings.Validate()
+}
+
+func (s Settings) Validate() error {
+	var problems []string
+
+	if s.HTTP.Port < 1 || s.HTTP.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("http.port %d is out of range", s.HTTP.Port))
+	}
+	if s.HTTP.ShutdownTimeout <= 0 {
+		problems = append(problems, "http.shutdownTimeout must be positive")
+	}
+	if err := store.ValidateDriver(s.StoreDriver); err != nil {
+		problems = append(problems, "storeDriver: "+err.Error())
+	}
+	if s.Database.Host == "" {
+		problems = append(problems, "database.host is required")
+	}
+	if s.Database.Port < 1 || s.Database.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("database.port %d is out of range", s.Database.Port))
+	}
+	if s.Database.Name == "" || s.Database.User == "" {
+		problems = append(problems, "database.name and database.user are required")
+	}
+	if s.Database.MaxConnections < 2 {
+		problems = append(problems, "database.maxConnections must be at least 2")
+	}
+	if s.Database.AcquireTimeout <= 0 || s.Database.QueryTimeout <= 0 {
+		problems = append(problems, "database.acquireTimeout and database.queryTimeout must be positive")
+	}
+	if s.Database.Retries < 0 {
+		problems = append(problems, "database.retries must not be negative")
+	}
+	if s.Database.LogBuffer < 1 {
+		problems = append(problems, "database.logBuffer must be at least 1")
+	}
+	if key, ok := strings.CutPrefix(s.AppKey, "base64:"); ok {
+		if _, err := base64.StdEncoding.DecodeString(key); err != nil {
+			problems = append(problems, "appKey is not valid base64")
+		}
+	}
+	if s.MonitorsFile == "" {
+		problems = append(problems, "monitorsFile is required")
+	}
+	if s.Flapping.Window < 0 || s.Flapping.Window == 1 {
+		problems = append(problems, "flapping.window must be 0 or at least 2")
+	}
+	if s.Flapping.LowThreshold < 0 || s.Flapping.LowThreshold >= s.Flapping.HighThreshold || s.Flapping.HighThreshold > 1 {
+		problems = append(problems, "flapping thresholds must satisfy 0 <= lowThreshold < highThreshold <= 1")
+	}
+	if s.Notifications.Retries < 0 {
+		problems = append(problems, "notifications.retries must not be negative")
+	}
+	if s.Notifications.RetryBackoff <= 0 || s.Notifications.Timeout <= 0 {
+		problems = append(problems, "notifications.retryBackoff and notifications.timeout must be positive")
+	}
+	switch s.Notifications.SMTP.TLS {
+	case SMTPStartTLS, SMTPTLS, SMTPNoTLS:
+	default:
+		problems = append(problems, fmt.Sprintf("notifications.smtp.tls '%s' must be starttls, tls or none", s.Notifications.SMTP.TLS))
+	}
+	if s.Notifications.SMTP.Port < 1 || s.Notifications.SMTP.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("notifications.smtp.port %d is out of range", s.Notifications.SMTP.Port))
+	}
+	if s.Notifications.EscalationInterval <= 0 {
+		problems = append(problems, "notifications.escalationInterval must be positive")
+	}
+	if s.Metrics.CertInterval <= 0 {
+		problems = append(problems, "metrics.certInterval must be positive")
+	}
+	switch s.Tracing.Exporter {
+	case TracingNone, TracingStdout, TracingOTLP:
+	default:
+		problems = append(problems, fmt.Sprintf("tracing.exporter '%s' must be none, stdout or otlp", s.Tracing.Exporter))
+	}
+	if s.Tracing.SampleRatio < 0 || s.Tracing.SampleRatio > 1 {
+		problems = append(problems, "tracing.sampleRatio must be between 0 and 1")
+	}
+	if _, err := ParseLogLevel(s.Logging.Level); err != nil {
+		problems = append(problems, "logging.level: "+err.Error())
+	}
+	switch s.Logging.Format {
+	case LogText, LogJSON:
+	default:
+		problems = append(problems, fmt.Sprintf("logging.format '%s' must be text or json", s.Logging.Format))
+	}
+	for _, level := range s.Logging.DatabaseLevels {
+		if _, err := ParseLogLevel(level); err != nil {
+			problems = append(problems, "logging.databaseLevels: "+err.Error())
+		}
+	}
+	if s.Discovery.Resync < 0 {
+		problems = append(problems, "discovery.resync must not be negative")
+	}
+	problems = append(problems, s.Discovery.validateTargets()...)
+	if s.CRD.StatusInterval <= 0 {
+		problems = append(problems, "crd.statusInterval must be positive")
+	}
+	if _, err := labels.Parse(s.Discovery.Ingresses.Selector); err != nil {
+		problems = append(problems, fmt.Sprintf("discovery.ingresses: invalid selector '%s': %v", s.Discovery.Ingresses.Selector, err))
+	}
+	problems = append(problems, s.Discovery.Ingresses.Monitor.validate("discovery.ingresses")...)
+	problems = append(problems, s.Notifications.validateChannels()...)
+	problems = append(problems, s.Notifications.validateRoutes()...)
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid settings:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func (n NotificationSettings) validateChannels() []string {
+	var problems []string
+	seen := make(map[string]bool)
+	for i, channel := range n.Channels {
+		prefix := fmt.Sprintf("notifications.channels[%d]", i)
+		switch {
+		case channel.Name == "":
+			problems = append(problems, prefix+": name is required")
+		case seen[channel.Name]:
+			problems = append(problems, fmt.Sprintf("%s: duplicate channel '%s'", prefix, channel.Name))
+		}
+		seen[channel.Name] = true
+
+		switch channel.Type {
+		case ChannelWebhook, ChannelSlack, ChannelMattermost, ChannelDiscord, ChannelTeams:
+			if !strings.HasPrefix(channel.URL, "http://") && !strings.HasPrefix(channel.URL, "https://") {
+				problems = append(problems, prefix+": url must be an http(s) URL")
+			}
+		case ChannelEmail:
+			if len(channel.To) == 0 {
+				problems = append(problems, prefix+": at least one recipient is required")
+			}
+			if n.SMTP.Host == "" {
+				problems = append(problems, prefix+": notifications.smtp.host is required for email channels")
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("%s: unknown type '%s'", prefix, channel.Type))
+		}
+	}
+	return problems
+}
+
+func (d DiscoverySettings) validateTargets() []string {
+	var problems []string
+	seen := make(map[string]bool)
+	for i, target := range d.Targets {
+		prefix := fmt.Sprintf("discovery.targets[%d]", i)
+		if target.Name == "" {
+			problems = append(problems, prefix+": name is required")
+		} else if seen[target.Name] {
+			problems = append(problems, fmt.Sprintf("%s: duplicate target '%s'", prefix, target.Name))
+		}
+		seen[target.Name] = true
+
+		for _, selector := range append([]string{target.NamespaceSelector}, target.PodSelectors...) {
+			if _, err := labels.Parse(selector); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: invalid selector '%s': %v", prefix, selector, err))
+			}
+		}
+		problems = append(problems, target.Monitor.validate(prefix)...)
+	}
+	return problems
+}
+
+func (m MonitorTemplate) validate(prefix string) []string {
+	var problems []string
+	if m.Path != "" && !strings.HasPrefix(m.Path, "/") {
+		problems = append(problems, fmt.Sprintf("%s: path '%s' must start with '/'", prefix, m.Path))
+	}
+	if m.Interval < 0 || m.Timeout < 0 || m.FailureThreshold < 0 || m.SuccessThreshold < 0 {
+		problems = append(problems, prefix+": interval, timeout and thresholds must not be negative")
+	}
+	if m.SLO < 0 || m.SLO > 100 {
+		problems = append(problems, prefix+": slo must be between 0 and 100")
+	}
+	return problems
+}
+
+func (n NotificationSettings) validateRoutes() []string {
+	var problems []string
+	channels := make(map[string]bool)
+	for _, channel := range n.Channels {
+		channels[channel.Name] = true
+	}
+	checkChannels := func(prefix string, names []string) {
+		for _, name := range names {
+			if !channels[name] {
+				problems = append(problems, fmt.Sprintf("%s: unknown channel '%s'", prefix, name))
+			}
+		}
+	}
+
+	for i, route := range n.Routes {
+		prefix := fmt.Sprintf("notifications.routes[%d]", i)
+		if route.Name == "" {
+			problems = append(problems, prefix+": name is required")
+		}
+		if len(route.Channels) == 0 && len(route.Escalation) == 0 {
+			problems = append(problems, prefix+": at least one channel or escalation step is required")
+		}
+		checkChannels(prefix, route.Channels)
+		for _, severity := range route.Match.Severities {
+			switch severity {
+			case db.SeverityMinor, db.SeverityMajor, db.SeverityCritical:
+			default:
+				problems = append(problems, fmt.Sprintf("%s: unknown severity '%s'", prefix, severity))
+			}
+		}
+
+		var previous time.Duration
+		for j, step := range route.Escalation {
+			stepPrefix := fmt.Sprintf("%s.escalation[%d]", prefix, j)
+			if step.After <= previous {
+				problems = append(problems, stepPrefix+": after must be positive and increase with every step")
+			}
+			if len(step.Channels) == 0 {
+				problems = append(problems, stepPrefix+": at least one channel is required")
+			}
+			checkChannels(stepPrefix, step.Channels)
+			previous = step.After
+		}
+		if route.RepeatInterval < 0 {
+			problems = append(problems, prefix+": repeatInterval must not be negative")
+		}
+	}
+	return problems
+}
+
+// Redacted returns a copy of the settings with every secret masked.
+func (s Settings) Redacted() Settings {
+	s.Notifications.Channels = slices.Clone(s.Notifications.Channels)
+	_ = walkSettings(reflect.ValueOf(&s).Elem(), func(field reflect.Value, tag reflect.StructTag) error {
+		if tag.Get("secret") == "true" && !field.IsZero() {
+			field.SetString(redacted)
+		}
+		return nil
+	})
+	return s
+}
+
+func dumpSettings(settings Settings) {
+	settingsJSON, err := json.Marshal(settings.Redacted())
+	if err != nil {
+		panic(fmt.Errorf("Failed to marshal settings: %v", err))
+	}
+	slog.Info("Loaded settings", "settings", string(settingsJSON))
+}
+
+func walkSettings(v reflect.Value, visit func(reflect.Value, reflect.StructTag) error) error {
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct {
+			for j := range v.Field(i).Len() {
+				if err := walkSettings(v.Field(i).Index(j), visit); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			if err := walkSettings(v.Field(i), visit); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := visit(v.Field(i), field.Tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, value string) error {
+	switch {
+	case field.Type() == reflect.TypeOf(time.Duration(0)):
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(parsed))
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Kind() == reflect.Int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(parsed))
+	case field.Kind() == reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case field.Kind() == reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(parsed)
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported settings type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+package crd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"iammati/statuspage/maintenance"
+	"iammati/statuspage/scheduler"
+	"iammati/statuspage/status"
+	"iammati/statuspage/store"
+	"iammati/statuspage/uptime"
+)
+
+// UptimeWindow is the window the uptime of a Monitor is reported over.
+const UptimeWindow = 24 * time.Hour
+
+type Options struct {
+	// Registry schedules the monitors of valid Monitor resources.
+	Registry scheduler.Registry
+	// States returns the confirmed state of every probed monitor.
+	States func() map[string]status.MonitorState
+	// Transitions the uptime is computed from; no uptime is reported if nil.
+	Transitions store.TransitionStore
+	// Excluded returns the maintenance of a monitor, which doesn't count
+	// towards its uptime.
+	Excluded func(monitor string, from, to time.Time) []maintenance.Interval
+	Resync   time.Duration
+	// StatusInterval is how often status is written back.
+	StatusInterval time.Duration
+}
+
+// Controller schedules the monitors declared by Monitor resources and writes
+// their state, last probe and uptime back to the status subresource.
+type Controller struct {
+	client   dynamic.Interface
+	options  Options
+	factory  dynamicinformer.DynamicSharedInformerFactory
+	informer cache.SharedIndexInformer
+
+	mu sync.Mutex
+	// scheduled are the registered monitors by key, invalid the problems of
+	// the specs that couldn't be scheduled.
+	scheduled map[string]scheduler.Monitor
+	invalid   map[string]string
+	probes    map[string]ProbeStatus
+}
+
+// New creates a controller of the Monitor resources in namespace, or all
+// namespaces if empty.
+func New(client dynamic.Interface, namespace string, options Options) *Controller {
+	if options.StatusInterval <= 0 {
+		options.StatusInterval = 30 * time.Second
+	}
+	c := &Controller{
+		client:    client,
+		options:   options,
+		factory:   dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, options.Resync, namespace, nil),
+		scheduled: make(map[string]scheduler.Monitor),
+		invalid:   make(map[string]string),
+		probes:    make(map[string]ProbeStatus),
+	}
+	c.informer = c.factory.ForResource(Monitors).Informer()
+	c.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.sync,
+		UpdateFunc: func(_, obj interface{}) { c.sync(obj) },
+		DeleteFunc: func(obj interface{}) {
+			if key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
+				c.forget(key)
+			}
+		},
+	})
+	return c
+}
+
+// Served reports whether the Monitor CRD is installed.
+func Served(clientset kubernetes.Interface) bool {
+	resources, err := clientset.Discovery().ServerResourcesForGroupVersion(Monitors.GroupVersion().String())
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(resources.APIResources, func(resource metav1.APIResource) bool {
+		return resource.Name == Monitors.Resource
+	})
+}
+
+func (c *Controller) sync(obj interface{}) {
+	resource, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(resource)
+	if err != nil {
+		return
+	}
+	if resource.GetDeletionTimestamp() != nil {
+		c.forget(key)
+		return
+	}
+
+	spec, _, err := decode(resource)
+	var monitor scheduler.Monitor
+	if err == nil {
+		monitor, err = spec.monitor(resource.GetNamespace(), resource.GetName())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		if c.invalid[key] != err.Error() {
+			slog.Warn("Invalid monitor resource", "monitor", key, "error", err)
+		}
+		c.invalid[key] = err.Error()
+		delete(c.probes, key)
+		if _, ok := c.scheduled[key]; ok {
+			delete(c.scheduled, key)
+			c.options.Registry.Remove(key)
+		}
+		return
+	}
+	delete(c.invalid, key)
+	// Status writes and resyncs update the resource as well; only changed
+	// specs are registered again.
+	previous, ok := c.scheduled[key]
+	if ok && previous.Equal(monitor) {
+		return
+	}
+	if !ok {
+		slog.Info("Scheduled monitor resource", "monitor", key, "host", monitor.Host)
+	}
+	c.scheduled[key] = monitor
+	c.options.Registry.Upsert(monitor)
+}
+
+func (c *Controller) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.invalid, key)
+	delete(c.probes, key)
+	if _, ok := c.scheduled[key]; ok {
+		delete(c.scheduled, key)
+		c.options.Registry.Remove(key)
+		slog.Info("Removed monitor resource", "monitor", key)
+	}
+}
+
+// Observe records the result of a probe as the last probe of its Monitor.
+// Results of other monitors are ignored.
+func (c *Controller) Observe(result scheduler.Result) {
+	key := result.Monitor.Key()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.scheduled[key]; ok {
+		c.probes[key] = probeStatus(result)
+	}
+}
+
+func probeStatus(result scheduler.Result) ProbeStatus {
+	probe := ProbeStatus{
+		Time:         metav1.NewTime(result.Started.Truncate(time.Second)),
+		Up:           result.Up(),
+		StatusCode:   result.Metrics.StatusCode,
+		ResponseTime: result.Metrics.ResponseTime().Round(time.Millisecond).String(),
+	}
+	switch {
+	case result.Err != nil:
+		probe.Error = result.Err.Error()
+	case result.Metrics.Error != nil:
+		probe.Error = result.Metrics.Error.Error()
+	case result.Monitor.MaxResponseTime > 0 && result.Metrics.ResponseTime() > result.Monitor.MaxResponseTime:
+		probe.Error = fmt.Sprintf("response time exceeds %s", result.Monitor.MaxResponseTime)
+	}
+	return probe
+}
+
+// Run starts the informer and writes status every StatusInterval until stop
+// is closed.
+func (c *Controller) Run(stop <-chan struct{}) {
+	c.factory.Start(stop)
+	defer c.factory.Shutdown()
+	if !cache.WaitForCacheSync(stop, c.HasSynced) {
+		return
+	}
+
+	ticker := time.NewTicker(c.options.StatusInterval)
+	defer ticker.Stop()
+	for {
+		c.Reconcile()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// HasSynced reports whether the initial list has been handled.
+func (c *Controller) HasSynced() bool {
+	return c.informer.HasSynced()
+}
+
+// Reconcile writes the status of every Monitor whose status changed.
+func (c *Controller) Reconcile() {
+	var states map[string]status.MonitorState
+	if c.options.States != nil {
+		states = c.options.States()
+	}
+	now := time.Now()
+
+	for _, obj := range c.informer.GetStore().List() {
+		resource, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		key, err := cache.MetaNamespaceKeyFunc(resource)
+		if err != nil {
+			continue
+		}
+		_, current, _ := decode(resource)
+		desired := c.status(key, states, now)
+		desired.ObservedGeneration = resource.GetGeneration()
+		if err := c.write(resource, current, desired); err != nil {
+			slog.Warn("Failed to update monitor status", "monitor", key, "error", err)
+		}
+	}
+}
+
+// status returns the desired status of the Monitor key.
+func (c *Controller) status(key string, states map[string]status.MonitorState, now time.Time) Status {
+	c.mu.Lock()
+	message, invalid := c.invalid[key]
+	probe, probed := c.probes[key]
+	c.mu.Unlock()
+
+	if invalid {
+		return Status{State: StateInvalid, Message: message}
+	}
+	var desired Status
+	if probed {
+		desired.LastProbe = &probe
+	}
+	state, known := states[key]
+	switch {
+	case !known:
+		desired.State = StatePending
+	case state.Maintenance:
+		desired.State = StateMaintenance
+	case state.Flapping:
+		desired.State = StateFlapping
+	case state.Up:
+		desired.State = StateUp
+	default:
+		desired.State = StateDown
+	}
+
+	if c.options.Transitions != nil {
+		from := now.Add(-UptimeWindow)
+		transitions, err := c.options.Transitions.StateTransitions(key, from, now)
+		if err != nil {
+			slog.Debug("Failed to load monitor transitions", "monitor", key, "error", err)
+			return desired
+		}
+		var excluded []maintenance.Interval
+		if c.options.Excluded != nil {
+			excluded = c.options.Excluded(key, from, now)
+		}
+		if ratio, ok := uptime.Compute(transitions, from, now, excluded...).Ratio(); ok {
+			desired.Uptime = fmt.Sprintf("%.2f%%", ratio*100)
+		}
+	}
+	return desired
+}
+
+// write updates the status subresource unless it already matches.
+func (c *Controller) write(resource *unstructured.Unstructured, current Status, desired Status) error {
+	currentValues, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&current)
+	if err != nil {
+		return err
+	}
+	desiredValues, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&desired)
+	if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(currentValues, desiredValues) {
+		return nil
+	}
+
+	updated := resource.DeepCopy()
+	updated.Object["status"] = desiredValues
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = c.client.Resource(Monitors).Namespace(resource.GetNamespace()).UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,156 @@
+package crd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"iammati/statuspage/scheduler"
+)
+
+// Monitors is the resource Monitor objects are served under, as declared in
+// api/crds/monitors.yaml.
+var Monitors = schema.GroupVersionResource{Group: "statuspage.io", Version: "v1alpha1", Resource: "monitors"}
+
+// States a Monitor reports in its status.
+const (
+	StatePending  = "Pending"
+	StateUp       = "Up"
+	StateDown     = "Down"
+	StateFlapping = "Flapping"
+	StateInvalid  = "Invalid"
+	// StateMaintenance is reported while a maintenance window covers the
+	// monitor.
+	StateMaintenance = "Maintenance"
+)
+
+// Spec is the desired monitor of a Monitor resource.
+type Spec struct {
+	Target Target `json:"target"`
+	// Type is http (the default) or certificate.
+	Type     string          `json:"type,omitempty"`
+	Interval metav1.Duration `json:"interval,omitempty"`
+	Timeout  metav1.Duration `json:"timeout,omitempty"`
+	Group    string          `json:"group,omitempty"`
+	// Component is the status page component the monitor joins.
+	Component        string     `json:"component,omitempty"`
+	Assertions       Assertions `json:"assertions,omitempty"`
+	FailureThreshold int        `json:"failureThreshold,omitempty"`
+	SuccessThreshold int        `json:"successThreshold,omitempty"`
+}
+
+// Target is the host, and for HTTP monitors the path, to probe.
+type Target struct {
+	Host string `json:"host"`
+	Port int    `json:"port,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+// Assertions a probe has to pass for the monitor to count as up.
+type Assertions struct {
+	// StatusCodes override the default 2xx/3xx check.
+	StatusCodes     []int           `json:"statusCodes,omitempty"`
+	MaxResponseTime metav1.Duration `json:"maxResponseTime,omitempty"`
+}
+
+// Status is the observed state of a Monitor resource.
+type Status struct {
+	State string `json:"state,omitempty"`
+	// Message explains an invalid spec.
+	Message   string       `json:"message,omitempty"`
+	LastProbe *ProbeStatus `json:"lastProbe,omitempty"`
+	// Uptime is the availability over the last 24 hours, e.g. "99.95%".
+	Uptime             string `json:"uptime,omitempty"`
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+}
+
+// ProbeStatus is the result of the last probe of a monitor.
+type ProbeStatus struct {
+	Time         metav1.Time `json:"time"`
+	Up           bool        `json:"up"`
+	StatusCode   int         `json:"statusCode,omitempty"`
+	ResponseTime string      `json:"responseTime,omitempty"`
+	Error        string      `json:"error,omitempty"`
+}
+
+// decode reads the spec and status of a Monitor object.
+func decode(obj *unstructured.Unstructured) (Spec, Status, error) {
+	var spec Spec
+	var status Status
+	if values, ok := obj.Object["spec"].(map[string]interface{}); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(values, &spec); err != nil {
+			return spec, status, fmt.Errorf("invalid spec: %v", err)
+		}
+	}
+	if values, ok := obj.Object["status"].(map[string]interface{}); ok {
+		// A status that can't be read is overwritten.
+		_ = runtime.DefaultUnstructuredConverter.FromUnstructured(values, &status)
+	}
+	return spec, status, nil
+}
+
+// monitor validates the spec of the Monitor namespace/name and returns the
+// monitor it describes, keyed by namespace/name.
+func (s Spec) monitor(namespace string, name string) (scheduler.Monitor, error) {
+	var problems []string
+	host := s.Target.Host
+	switch {
+	case host == "":
+		problems = append(problems, "target.host is required")
+	case strings.Contains(host, "/"):
+		problems = append(problems, fmt.Sprintf("target.host '%s' must not contain a scheme or path", host))
+	}
+	if s.Target.Port < 0 || s.Target.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("target.port %d is out of range", s.Target.Port))
+	} else if s.Target.Port != 0 {
+		host = net.JoinHostPort(host, strconv.Itoa(s.Target.Port))
+	}
+	if s.Target.Path != "" && !strings.HasPrefix(s.Target.Path, "/") {
+		problems = append(problems, fmt.Sprintf("target.path '%s' must start with '/'", s.Target.Path))
+	}
+	switch s.Type {
+	case "", scheduler.TypeHTTP, scheduler.TypeCertificate:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown type '%s', must be %s or %s", s.Type, scheduler.TypeHTTP, scheduler.TypeCertificate))
+	}
+	if s.Interval.Duration < 0 || (s.Interval.Duration > 0 && s.Interval.Duration < time.Second) {
+		problems = append(problems, "interval must be at least 1s")
+	}
+	if s.Timeout.Duration < 0 || s.Assertions.MaxResponseTime.Duration < 0 {
+		problems = append(problems, "timeout and maxResponseTime must not be negative")
+	}
+	for _, code := range s.Assertions.StatusCodes {
+		if code < 100 || code > 599 {
+			problems = append(problems, fmt.Sprintf("status code %d is not a valid HTTP status code", code))
+		}
+	}
+	if s.FailureThreshold < 0 || s.SuccessThreshold < 0 {
+		problems = append(problems, "thresholds must not be negative")
+	}
+	if len(problems) > 0 {
+		return scheduler.Monitor{}, errors.New(strings.Join(problems, "; "))
+	}
+
+	return scheduler.Monitor{
+		Name:             namespace + "/" + name,
+		Type:             s.Type,
+		Host:             host,
+		Path:             s.Target.Path,
+		Group:            s.Group,
+		Component:        s.Component,
+		Interval:         s.Interval.Duration,
+		Timeout:          s.Timeout.Duration,
+		ExpectedStatus:   s.Assertions.StatusCodes,
+		MaxResponseTime:  s.Assertions.MaxResponseTime.Duration,
+		FailureThreshold: s.FailureThreshold,
+		SuccessThreshold: s.SuccessThreshold,
+	}, nil
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// Component is a public-facing part of the system shown on the status page.
+type Component struct {
+	Name        string
+	Description string
+	Group       string
+	Order       int
+	Monitors    []string
+}
+
+func UpsertComponent(ctx context.Context, conn Querier, component Component) error {
+	_, err := conn.ExecEx(ctx,
+		`INSERT INTO components (name, description, group_name, display_order, monitors, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (name) DO UPDATE SET
+			description = EXCLUDED.description, group_name = EXCLUDED.group_name,
+			display_order = EXCLUDED.display_order, monitors = EXCLUDED.monitors, updated_at = NOW()`, nil,
+		component.Name, component.Description, component.Group, int32(component.Order), nonNilStrings(component.Monitors),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to upsert component '%s': %w", component.Name, err)
+	}
+	return nil
+}
+
+func DeleteComponent(ctx context.Context, conn Querier, name string) error {
+	if _, err := conn.ExecEx(ctx, `DELETE FROM components WHERE name = $1`, nil, name); err != nil {
+		return fmt.Errorf("failed to delete component '%s': %w", name, err)
+	}
+	return nil
+}
+
+// Components returns every component in display order.
+func Components(ctx context.Context, conn Querier) ([]Component, error) {
+	rows, err := conn.QueryEx(ctx,
+		`SELECT name, description, group_name, display_order, monitors
+		FROM components ORDER BY display_order, name`, nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query components: %w", err)
+	}
+	defer rows.Close()
+
+	components := []Component{}
+	for rows.Next() {
+		var component Component
+		var order int32
+		if err := rows.Scan(&component.Name, &component.Description, &component.Group, &order, &component.Monitors); err != nil {
+			return nil, fmt.Errorf("failed to scan component: %w", err)
+		}
+		component.Order = int(order)
+		components = append(components, component)
+	}
+	return components, rows.Err()
+}
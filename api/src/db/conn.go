@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx"
+)
+
+// Querier is satisfied by both *pgx.Conn and *pgx.ConnPool.
+type Querier interface {
+	ExecEx(ctx context.Context, sql string, options *pgx.QueryExOptions, arguments ...interface{}) (pgx.CommandTag, error)
+	QueryEx(ctx context.Context, sql string, options *pgx.QueryExOptions, args ...interface{}) (*pgx.Rows, error)
+	QueryRowEx(ctx context.Context, sql string, options *pgx.QueryExOptions, args ...interface{}) *pgx.Row
+}
+
+// IsTransient reports whether err is worth retrying: lost or exhausted
+// connections, server shutdowns and serialization conflicts.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, pgx.ErrDeadConn) || errors.Is(err, pgx.ErrAcquireTimeout) ||
+		errors.Is(err, pgx.ErrConnBusy) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var pgErr pgx.PgError
+	if errors.As(err, &pgErr) {
+		// Class 08: connection exception, 57P0x: operator intervention,
+		// 40001/40P01: serialization failure and deadlock.
+		return strings.HasPrefix(pgErr.Code, "08") || strings.HasPrefix(pgErr.Code, "57P0") ||
+			pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+
+	return false
+}
+
+// IsUnapplied reports whether a failed statement certainly had no effect,
+// because it was never sent or its transaction was rolled back. Unlike
+// IsTransient, it excludes failures after the statement may have committed.
+func IsUnapplied(err error) bool {
+	if errors.Is(err, pgx.ErrDeadConn) || errors.Is(err, pgx.ErrAcquireTimeout) || errors.Is(err, pgx.ErrConnBusy) {
+		return true
+	}
+	var pgErr pgx.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+const (
+	IncidentOpen         = "open"
+	IncidentAcknowledged = "acknowledged"
+	IncidentResolved     = "resolved"
+)
+
+const (
+	SeverityMinor    = "minor"
+	SeverityMajor    = "major"
+	SeverityCritical = "critical"
+)
+
+var ErrNotFound = errors.New("not found")
+
+type Incident struct {
+	ID             int64
+	Title          string
+	Status         string
+	Severity       string
+	Monitors       []string
+	StartedAt      time.Time
+	AcknowledgedAt time.Time
+	AcknowledgedBy string
+	ResolvedAt     time.Time
+	Postmortem     string
+	// Automatic incidents were opened by a monitor going down.
+	Automatic bool
+}
+
+// IncidentUpdate is a single entry on the timeline of an incident.
+type IncidentUpdate struct {
+	ID         int64
+	IncidentID int64
+	CreatedAt  time.Time
+	Status     string
+	Message    string
+	Author     string
+}
+
+// IncidentFilter narrows down Incidents; zero values match everything.
+type IncidentFilter struct {
+	Status string
+	// Active only matches incidents that are not resolved yet.
+	Active  bool
+	Monitor string
+	Limit   int
+}
+
+const incidentColumns = `id, title, status, severity, monitors, started_at,
+	acknowledged_at, acknowledged_by, resolved_at, postmortem, automatic`
+
+func CreateIncident(ctx context.Context, conn Querier, incident Incident) (int64, error) {
+	var id int64
+	err := conn.QueryRowEx(ctx,
+		`INSERT INTO incidents (title, status, severity, monitors, started_at,
+			acknowledged_at, acknowledged_by, resolved_at, postmortem, automatic)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`, nil,
+		incident.Title, incident.Status, incident.Severity, nonNilStrings(incident.Monitors), incident.StartedAt,
+		nullTime(incident.AcknowledgedAt), incident.AcknowledgedBy, nullTime(incident.ResolvedAt), incident.Postmortem,
+		incident.Automatic,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create incident: %w", err)
+	}
+	return id, nil
+}
+
+func UpdateIncident(ctx context.Context, conn Querier, incident Incident) error {
+	tag, err := conn.ExecEx(ctx,
+		`UPDATE incidents SET title = $2, status = $3, severity = $4, monitors = $5, started_at = $6,
+			acknowledged_at = $7, acknowledged_by = $8, resolved_at = $9, postmortem = $10
+		WHERE id = $1`, nil,
+		incident.ID, incident.Title, incident.Status, incident.Severity, nonNilStrings(incident.Monitors), incident.StartedAt,
+		nullTime(incident.AcknowledgedAt), incident.AcknowledgedBy, nullTime(incident.ResolvedAt), incident.Postmortem,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update incident %d: %w", incident.ID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func GetIncident(ctx context.Context, conn Querier, id int64) (Incident, error) {
+	rows, err := conn.QueryEx(ctx, `SELECT `+incidentColumns+` FROM incidents WHERE id = $1`, nil, id)
+	if err != nil {
+		return Incident{}, fmt.Errorf("failed to query incident %d: %w", id, err)
+	}
+	incidents, err := scanIncidents(rows)
+	if err != nil {
+		return Incident{}, err
+	}
+	if len(incidents) == 0 {
+		return Incident{}, ErrNotFound
+	}
+	return incidents[0], nil
+}
+
+// Incidents returns the incidents matching the filter, newest first.
+func Incidents(ctx context.Context, conn Querier, filter IncidentFilter) ([]Incident, error) {
+	limit := int64(filter.Limit)
+	if limit <= 0 {
+		limit = 100
+	}
+
+	rows, err := conn.QueryEx(ctx,
+		`SELECT `+incidentColumns+` FROM incidents
+		WHERE ($1 = '' OR status = $1) AND ($2 = '' OR $2 = ANY(monitors))
+			AND (NOT $3 OR status <> 'resolved')
+		ORDER BY started_at DESC LIMIT $4`, nil,
+		filter.Status, filter.Monitor, filter.Active, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query incidents: %w", err)
+	}
+	return scanIncidents(rows)
+}
+
+func scanIncidents(rows *pgx.Rows) ([]Incident, error) {
+	defer rows.Close()
+
+	incidents := []Incident{}
+	for rows.Next() {
+		var incident Incident
+		var acknowledgedAt, resolvedAt *time.Time
+		if err := rows.Scan(&incident.ID, &incident.Title, &incident.Status, &incident.Severity,
+			&incident.Monitors, &incident.StartedAt, &acknowledgedAt, &incident.AcknowledgedBy,
+			&resolvedAt, &incident.Postmortem, &incident.Automatic); err != nil {
+			return nil, fmt.Errorf("failed to scan incident: %w", err)
+		}
+		if acknowledgedAt != nil {
+			incident.AcknowledgedAt = *acknowledgedAt
+		}
+		if resolvedAt != nil {
+			incident.ResolvedAt = *resolvedAt
+		}
+		incidents = append(incidents, incident)
+	}
+	return incidents, rows.Err()
+}
+
+func AddIncidentUpdate(ctx context.Context, conn Querier, update IncidentUpdate) (int64, error) {
+	var id int64
+	err := conn.QueryRowEx(ctx,
+		`INSERT INTO incident_updates (incident_id, created_at, status, message, author)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`, nil,
+		update.IncidentID, update.CreatedAt, update.Status, update.Message, update.Author,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add update to incident %d: %w", update.IncidentID, err)
+	}
+	return id, nil
+}
+
+// IncidentUpdates returns the timeline of an incident, oldest first.
+func IncidentUpdates(ctx context.Context, conn Querier, incidentID int64) ([]IncidentUpdate, error) {
+	rows, err := conn.QueryEx(ctx,
+		`SELECT id, incident_id, created_at, status, message, author
+		FROM incident_updates WHERE incident_id = $1 ORDER BY created_at, id`, nil,
+		incidentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query updates of incident %d: %w", incidentID, err)
+	}
+	defer rows.Close()
+
+	updates := []IncidentUpdate{}
+	for rows.Next() {
+		var update IncidentUpdate
+		if err := rows.Scan(&update.ID, &update.IncidentID, &update.CreatedAt, &update.Status,
+			&update.Message, &update.Author); err != nil {
+			return nil, fmt.Errorf("failed to scan incident update: %w", err)
+		}
+		updates = append(updates, update)
+	}
+	return updates, rows.Err()
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+type LogEntry struct {
+	ID        int64
+	Timestamp string // Assuming ISO 8601 format: "2006-01-02T15:04:05Z07:00"
+	Level     string
+	Message   string
+	// Attributes hold the structured fields of the record, e.g. the monitor
+	// or pod it is about.
+	Attributes map[string]string
+}
+
+// LogFilter selects log entries. Empty fields match everything. Search
+// matches the message case-insensitively. Before is a cursor: only entries
+// with a smaller ID match.
+type LogFilter struct {
+	Levels  []string
+	From    time.Time
+	To      time.Time
+	Search  string
+	Monitor string
+	Before  int64
+	Limit   int
+}
+
+// Matches reports whether an entry passes every criterion but the limit.
+func (f LogFilter) Matches(entry LogEntry) bool {
+	if len(f.Levels) > 0 && !slices.ContainsFunc(f.Levels, func(level string) bool { return strings.EqualFold(level, entry.Level) }) {
+		return false
+	}
+	if !f.From.IsZero() || !f.To.IsZero() {
+		timestamp, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+		if err != nil || timestamp.Before(f.From) || (!f.To.IsZero() && !timestamp.Before(f.To)) {
+			return false
+		}
+	}
+	if f.Search != "" && !strings.Contains(strings.ToLower(entry.Message), strings.ToLower(f.Search)) {
+		return false
+	}
+	if f.Monitor != "" && entry.Attributes["monitor"] != f.Monitor {
+		return false
+	}
+	return f.Before <= 0 || entry.ID < f.Before
+}
+
+func InsertLogEntry(ctx context.Context, conn Querier, entry LogEntry) (int64, error) {
+	insertSQL := `INSERT INTO logs (timestamp, level, message, attributes) VALUES ($1, $2, $3, $4) RETURNING id`
+
+	attributes, err := marshalAttributes(entry.Attributes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal log attributes: %w", err)
+	}
+	var id int64
+	err = conn.QueryRowEx(ctx, insertSQL, nil, entry.Timestamp, entry.Level, entry.Message, attributes).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert log entry: %w", err)
+	}
+	return id, nil
+}
+
+// LogEntries returns the matching entries, newest first.
+func LogEntries(ctx context.Context, conn Querier, filter LogFilter) ([]LogEntry, error) {
+	limit := int64(filter.Limit)
+	if limit <= 0 {
+		limit = 100
+	}
+	levels := make([]string, len(filter.Levels))
+	for i, level := range filter.Levels {
+		levels[i] = strings.ToUpper(level)
+	}
+	var from, to *time.Time
+	if !filter.From.IsZero() {
+		from = &filter.From
+	}
+	if !filter.To.IsZero() {
+		to = &filter.To
+	}
+
+	rows, err := conn.QueryEx(ctx,
+		`SELECT id, timestamp, level, message, attributes::text FROM logs
+		WHERE (cardinality($1::text[]) = 0 OR upper(level) = ANY($1))
+			AND ($2::timestamptz IS NULL OR timestamp >= $2)
+			AND ($3::timestamptz IS NULL OR timestamp < $3)
+			AND ($4 = '' OR message ILIKE '%' || $4 || '%')
+			AND ($5 = '' OR attributes->>'monitor' = $5)
+			AND ($6 = 0 OR id < $6)
+		ORDER BY id DESC LIMIT $7`, nil,
+		levels, from, to, escapeLike(filter.Search), filter.Monitor, filter.Before, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query log entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []LogEntry{}
+	for rows.Next() {
+		var entry LogEntry
+		var level, message *string
+		var timestamp time.Time
+		var attributes string
+		if err := rows.Scan(&entry.ID, &timestamp, &level, &message, &attributes); err != nil {
+			return nil, fmt.Errorf("failed to scan log entry: %w", err)
+		}
+		entry.Timestamp = timestamp.UTC().Format(time.RFC3339Nano)
+		if level != nil {
+			entry.Level = *level
+		}
+		if message != nil {
+			entry.Message = *message
+		}
+		if err := json.Unmarshal([]byte(attributes), &entry.Attributes); err != nil {
+			return nil, fmt.Errorf("failed to decode attributes of log entry %d: %w", entry.ID, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func marshalAttributes(attributes map[string]string) (string, error) {
+	if len(attributes) == 0 {
+		return "{}", nil
+	}
+	encoded, err := json.Marshal(attributes)
+	return string(encoded), err
+}
+
+// escapeLike makes LIKE treat wildcards in a search term literally.
+func escapeLike(term string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+}
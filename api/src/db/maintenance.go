@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MaintenanceWindow is a planned period during which the affected monitors
+// and components are under maintenance. Windows without a Schedule happen
+// once, from StartsAt to EndsAt; recurring ones last Duration on every
+// occurrence of their cron expression or RRULE.
+type MaintenanceWindow struct {
+	ID          int64
+	Title       string
+	Description string
+	Monitors    []string
+	Components  []string
+	StartsAt    time.Time
+	EndsAt      time.Time
+	Schedule    string
+	Duration    time.Duration
+	CreatedAt   time.Time
+}
+
+const maintenanceWindowColumns = `id, title, description, monitors, components,
+	starts_at, ends_at, schedule, duration_ms, created_at`
+
+func CreateMaintenanceWindow(ctx context.Context, conn Querier, window MaintenanceWindow) (int64, error) {
+	var id int64
+	err := conn.QueryRowEx(ctx,
+		`INSERT INTO maintenance_windows (title, description, monitors, components,
+			starts_at, ends_at, schedule, duration_ms, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`, nil,
+		window.Title, window.Description, nonNilStrings(window.Monitors), nonNilStrings(window.Components),
+		window.StartsAt, nullTime(window.EndsAt), window.Schedule, window.Duration.Milliseconds(), window.CreatedAt,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create maintenance window: %w", err)
+	}
+	return id, nil
+}
+
+func UpdateMaintenanceWindow(ctx context.Context, conn Querier, window MaintenanceWindow) error {
+	tag, err := conn.ExecEx(ctx,
+		`UPDATE maintenance_windows SET title = $2, description = $3, monitors = $4, components = $5,
+			starts_at = $6, ends_at = $7, schedule = $8, duration_ms = $9
+		WHERE id = $1`, nil,
+		window.ID, window.Title, window.Description, nonNilStrings(window.Monitors), nonNilStrings(window.Components),
+		window.StartsAt, nullTime(window.EndsAt), window.Schedule, window.Duration.Milliseconds(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update maintenance window %d: %w", window.ID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func DeleteMaintenanceWindow(ctx context.Context, conn Querier, id int64) error {
+	tag, err := conn.ExecEx(ctx, `DELETE FROM maintenance_windows WHERE id = $1`, nil, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete maintenance window %d: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MaintenanceWindows returns every maintenance window, oldest first.
+func MaintenanceWindows(ctx context.Context, conn Querier) ([]MaintenanceWindow, error) {
+	rows, err := conn.QueryEx(ctx,
+		`SELECT `+maintenanceWindowColumns+` FROM maintenance_windows ORDER BY starts_at, id`, nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query maintenance windows: %w", err)
+	}
+	defer rows.Close()
+
+	windows := []MaintenanceWindow{}
+	for rows.Next() {
+		var window MaintenanceWindow
+		var endsAt *time.Time
+		var durationMs int64
+		if err := rows.Scan(&window.ID, &window.Title, &window.Description, &window.Monitors, &window.Components,
+			&window.StartsAt, &endsAt, &window.Schedule, &durationMs, &window.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan maintenance window: %w", err)
+		}
+		if endsAt != nil {
+			window.EndsAt = *endsAt
+		}
+		window.Duration = time.Duration(durationMs) * time.Millisecond
+		windows = append(windows, window)
+	}
+	return windows, rows.Err()
+}
@@ -1,10 +1,215 @@
 package db
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
 	"github.com/jackc/pgx"
 	db_migrations "iammati/statuspage/db/migrations"
 )
 
+// migrationsLockID is the advisory lock key held while migrating, so two
+// replicas booting at the same time don't apply the same steps twice.
+const migrationsLockID = 0x73746174757370
+
+type MigrationStatus struct {
+	db_migrations.Migration
+	Applied          bool
+	AppliedAt        time.Time
+	ChecksumMismatch bool
+}
+
+func Checksum(migration db_migrations.Migration) string {
+	sum := sha256.Sum256([]byte(migration.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidateMigrations makes sure versions are unique, ascending and every
+// step can be reverted.
+func ValidateMigrations(migrations []db_migrations.Migration) error {
+	previous := 0
+	for _, migration := range migrations {
+		if migration.Version <= previous {
+			return fmt.Errorf("migration %d (%s) is out of order", migration.Version, migration.Name)
+		}
+		if migration.Up == "" || migration.Down == "" {
+			return fmt.Errorf("migration %d (%s) needs both an up and a down step", migration.Version, migration.Name)
+		}
+		previous = migration.Version
+	}
+	return nil
+}
+
+// Migrations applies all pending migrations on boot and exits on failure.
 func Migrations(conn *pgx.Conn) {
-	db_migrations.Logs(conn)
+	if err := MigrateUp(conn); err != nil {
+		slog.Error("Failed to migrate database", "error", err)
+		os.Exit(1)
+	}
+}
+
+func MigrateUp(conn *pgx.Conn) error {
+	return withMigrationLock(conn, func() error {
+		statuses, err := verifiedMigrationStatuses(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			if status.Applied {
+				continue
+			}
+			if err := apply(conn, status.Migration); err != nil {
+				return err
+			}
+			slog.Info("Applied migration", "version", status.Version, "name", status.Name)
+		}
+		return nil
+	})
+}
+
+// MigrateDown reverts the given number of most recently applied migrations.
+func MigrateDown(conn *pgx.Conn, steps int) error {
+	return withMigrationLock(conn, func() error {
+		statuses, err := verifiedMigrationStatuses(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(statuses) - 1; i >= 0 && steps > 0; i-- {
+			if !statuses[i].Applied {
+				continue
+			}
+			if err := revert(conn, statuses[i].Migration); err != nil {
+				return err
+			}
+			slog.Info("Reverted migration", "version", statuses[i].Version, "name", statuses[i].Name)
+			steps--
+		}
+		return nil
+	})
+}
+
+func withMigrationLock(conn *pgx.Conn, fn func() error) error {
+	if _, err := conn.Exec(`SELECT pg_advisory_lock($1)`, int64(migrationsLockID)); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %v", err)
+	}
+	defer conn.Exec(`SELECT pg_advisory_unlock($1)`, int64(migrationsLockID))
+
+	return fn()
+}
+
+func ensureMigrationsTable(conn *pgx.Conn) error {
+	_, err := conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+	return nil
+}
+
+// verifiedMigrationStatuses returns the status of every known migration and
+// fails if an applied migration was changed after the fact.
+func verifiedMigrationStatuses(conn *pgx.Conn) ([]MigrationStatus, error) {
+	statuses, err := MigrationStatuses(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range statuses {
+		if status.ChecksumMismatch {
+			return nil, fmt.Errorf("checksum of applied migration %d (%s) does not match its definition", status.Version, status.Name)
+		}
+	}
+	return statuses, nil
+}
+
+// MigrationStatuses reports which of the known migrations have been applied.
+func MigrationStatuses(conn *pgx.Conn) ([]MigrationStatus, error) {
+	if err := ValidateMigrations(db_migrations.All); err != nil {
+		return nil, err
+	}
+	if err := ensureMigrationsTable(conn); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(`SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	type applied struct {
+		checksum  string
+		appliedAt time.Time
+	}
+	appliedVersions := map[int]applied{}
+	for rows.Next() {
+		var version int32
+		var record applied
+		if err := rows.Scan(&version, &record.checksum, &record.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %v", err)
+		}
+		appliedVersions[int(version)] = record
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(db_migrations.All))
+	for _, migration := range db_migrations.All {
+		status := MigrationStatus{Migration: migration}
+		if record, ok := appliedVersions[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = record.appliedAt
+			status.ChecksumMismatch = record.checksum != Checksum(migration)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func apply(conn *pgx.Conn, migration db_migrations.Migration) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(migration.Up); err != nil {
+		return fmt.Errorf("migration %d (%s) failed: %v", migration.Version, migration.Name, err)
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+		int32(migration.Version), migration.Name, Checksum(migration),
+	); err != nil {
+		return fmt.Errorf("failed to record migration %d: %v", migration.Version, err)
+	}
+
+	return tx.Commit()
+}
+
+func revert(conn *pgx.Conn, migration db_migrations.Migration) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(migration.Down); err != nil {
+		return fmt.Errorf("reverting migration %d (%s) failed: %v", migration.Version, migration.Name, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, int32(migration.Version)); err != nil {
+		return fmt.Errorf("failed to unrecord migration %d: %v", migration.Version, err)
+	}
+
+	return tx.Commit()
 }
@@ -0,0 +1,14 @@
+package db_migrations
+
+// automaticIncidents marks the incidents opened by a monitor going down, told
+// apart from manual ones by their first update.
+var automaticIncidents = Migration{
+	Version: 15,
+	Name:    "automatic_incidents",
+	Up: `ALTER TABLE incidents
+		ADD COLUMN IF NOT EXISTS automatic BOOLEAN NOT NULL DEFAULT FALSE;
+	UPDATE incidents SET automatic = TRUE
+	WHERE id IN (SELECT incident_id FROM incident_updates WHERE author = '' AND message LIKE 'Monitor ''%'' went down.');`,
+	Down: `ALTER TABLE incidents
+		DROP COLUMN IF EXISTS automatic;`,
+}
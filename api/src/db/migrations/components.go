@@ -0,0 +1,15 @@
+package db_migrations
+
+var createComponents = Migration{
+	Version: 8,
+	Name:    "create_components",
+	Up: `CREATE TABLE IF NOT EXISTS components (
+		name TEXT PRIMARY KEY,
+		description TEXT NOT NULL DEFAULT '',
+		group_name TEXT NOT NULL DEFAULT '',
+		display_order INTEGER NOT NULL DEFAULT 0,
+		monitors TEXT[] NOT NULL DEFAULT '{}',
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	Down: `DROP TABLE IF EXISTS components;`,
+}
@@ -0,0 +1,18 @@
+package db_migrations
+
+var flapping = Migration{
+	Version: 7,
+	Name:    "flapping",
+	Up: `ALTER TABLE state_transitions
+		ADD COLUMN IF NOT EXISTS flapping BOOLEAN NOT NULL DEFAULT false,
+		ADD COLUMN IF NOT EXISTS probe_ids BIGINT[] NOT NULL DEFAULT '{}';
+	ALTER TABLE monitors
+		ADD COLUMN IF NOT EXISTS failure_threshold INTEGER NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS success_threshold INTEGER NOT NULL DEFAULT 0;`,
+	Down: `ALTER TABLE monitors
+		DROP COLUMN IF EXISTS failure_threshold,
+		DROP COLUMN IF EXISTS success_threshold;
+	ALTER TABLE state_transitions
+		DROP COLUMN IF EXISTS flapping,
+		DROP COLUMN IF EXISTS probe_ids;`,
+}
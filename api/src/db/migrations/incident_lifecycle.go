@@ -0,0 +1,28 @@
+package db_migrations
+
+var incidentLifecycle = Migration{
+	Version: 5,
+	Name:    "incident_lifecycle",
+	Up: `ALTER TABLE incidents
+		ADD COLUMN IF NOT EXISTS severity TEXT NOT NULL DEFAULT 'major',
+		ADD COLUMN IF NOT EXISTS acknowledged_at TIMESTAMPTZ,
+		ADD COLUMN IF NOT EXISTS acknowledged_by TEXT NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS postmortem TEXT NOT NULL DEFAULT '';
+	CREATE INDEX IF NOT EXISTS incidents_status_idx ON incidents (status);
+	CREATE TABLE IF NOT EXISTS incident_updates (
+		id BIGSERIAL PRIMARY KEY,
+		incident_id BIGINT NOT NULL REFERENCES incidents (id) ON DELETE CASCADE,
+		created_at TIMESTAMPTZ NOT NULL,
+		status TEXT NOT NULL,
+		message TEXT NOT NULL,
+		author TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS incident_updates_incident_id_idx ON incident_updates (incident_id, created_at);`,
+	Down: `DROP TABLE IF EXISTS incident_updates;
+	DROP INDEX IF EXISTS incidents_status_idx;
+	ALTER TABLE incidents
+		DROP COLUMN IF EXISTS severity,
+		DROP COLUMN IF EXISTS acknowledged_at,
+		DROP COLUMN IF EXISTS acknowledged_by,
+		DROP COLUMN IF EXISTS postmortem;`,
+}
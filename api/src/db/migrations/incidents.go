@@ -0,0 +1,16 @@
+package db_migrations
+
+var createIncidents = Migration{
+	Version: 4,
+	Name:    "create_incidents",
+	Up: `CREATE TABLE IF NOT EXISTS incidents (
+		id BIGSERIAL PRIMARY KEY,
+		title TEXT NOT NULL,
+		status TEXT NOT NULL,
+		monitors TEXT[] NOT NULL DEFAULT '{}',
+		started_at TIMESTAMPTZ NOT NULL,
+		resolved_at TIMESTAMPTZ
+	);
+	CREATE INDEX IF NOT EXISTS incidents_started_at_idx ON incidents (started_at DESC);`,
+	Down: `DROP TABLE IF EXISTS incidents;`,
+}
@@ -0,0 +1,12 @@
+package db_migrations
+
+var logAttributes = Migration{
+	Version: 11,
+	Name:    "log_attributes",
+	Up: `ALTER TABLE logs
+		ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+	CREATE INDEX IF NOT EXISTS logs_timestamp_idx ON logs (timestamp DESC);`,
+	Down: `DROP INDEX IF EXISTS logs_timestamp_idx;
+	ALTER TABLE logs
+		DROP COLUMN IF EXISTS attributes;`,
+}
@@ -0,0 +1,19 @@
+package db_migrations
+
+var createMaintenanceWindows = Migration{
+	Version: 9,
+	Name:    "create_maintenance_windows",
+	Up: `CREATE TABLE IF NOT EXISTS maintenance_windows (
+		id BIGSERIAL PRIMARY KEY,
+		title TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		monitors TEXT[] NOT NULL DEFAULT '{}',
+		components TEXT[] NOT NULL DEFAULT '{}',
+		starts_at TIMESTAMPTZ NOT NULL,
+		ends_at TIMESTAMPTZ,
+		schedule TEXT NOT NULL DEFAULT '',
+		duration_ms BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	Down: `DROP TABLE IF EXISTS maintenance_windows;`,
+}
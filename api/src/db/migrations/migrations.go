@@ -0,0 +1,28 @@
+package db_migrations
+
+// Migration is a single reversible schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// All lists every schema migration in the order it is applied.
+var All = []Migration{
+	createLogs,
+	createProbeResults,
+	createMonitors,
+	createIncidents,
+	incidentLifecycle,
+	createStateTransitions,
+	flapping,
+	createComponents,
+	createMaintenanceWindows,
+	createNotificationDeliveries,
+	logAttributes,
+	monitorTypes,
+	monitorComponents,
+	monitorResponseTime,
+	automaticIncidents,
+}
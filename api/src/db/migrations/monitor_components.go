@@ -0,0 +1,10 @@
+package db_migrations
+
+var monitorComponents = Migration{
+	Version: 13,
+	Name:    "monitor_components",
+	Up: `ALTER TABLE monitors
+		ADD COLUMN IF NOT EXISTS component TEXT NOT NULL DEFAULT '';`,
+	Down: `ALTER TABLE monitors
+		DROP COLUMN IF EXISTS component;`,
+}
@@ -0,0 +1,10 @@
+package db_migrations
+
+var monitorResponseTime = Migration{
+	Version: 14,
+	Name:    "monitor_response_time",
+	Up: `ALTER TABLE monitors
+		ADD COLUMN IF NOT EXISTS max_response_time_ms BIGINT NOT NULL DEFAULT 0;`,
+	Down: `ALTER TABLE monitors
+		DROP COLUMN IF EXISTS max_response_time_ms;`,
+}
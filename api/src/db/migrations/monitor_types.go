@@ -0,0 +1,10 @@
+package db_migrations
+
+var monitorTypes = Migration{
+	Version: 12,
+	Name:    "monitor_types",
+	Up: `ALTER TABLE monitors
+		ADD COLUMN IF NOT EXISTS type TEXT NOT NULL DEFAULT 'http';`,
+	Down: `ALTER TABLE monitors
+		DROP COLUMN IF EXISTS type;`,
+}
@@ -0,0 +1,18 @@
+package db_migrations
+
+var createMonitors = Migration{
+	Version: 3,
+	Name:    "create_monitors",
+	Up: `CREATE TABLE IF NOT EXISTS monitors (
+		name TEXT PRIMARY KEY,
+		host TEXT NOT NULL,
+		path TEXT NOT NULL DEFAULT '',
+		group_name TEXT NOT NULL DEFAULT '',
+		tags TEXT[] NOT NULL DEFAULT '{}',
+		interval_ms BIGINT NOT NULL,
+		timeout_ms BIGINT NOT NULL,
+		expected_status INTEGER[] NOT NULL DEFAULT '{}',
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	Down: `DROP TABLE IF EXISTS monitors;`,
+}
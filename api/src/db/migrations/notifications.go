@@ -0,0 +1,21 @@
+package db_migrations
+
+var createNotificationDeliveries = Migration{
+	Version: 10,
+	Name:    "create_notification_deliveries",
+	Up: `CREATE TABLE IF NOT EXISTS notification_deliveries (
+		id BIGSERIAL PRIMARY KEY,
+		channel TEXT NOT NULL,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		response_code INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS notification_deliveries_channel_created_at_idx
+		ON notification_deliveries (channel, created_at DESC);`,
+	Down: `DROP TABLE IF EXISTS notification_deliveries;`,
+}
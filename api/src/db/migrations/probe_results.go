@@ -0,0 +1,21 @@
+package db_migrations
+
+var createProbeResults = Migration{
+	Version: 2,
+	Name:    "create_probe_results",
+	Up: `CREATE TABLE IF NOT EXISTS probe_results (
+		id BIGSERIAL PRIMARY KEY,
+		monitor TEXT NOT NULL,
+		host TEXT NOT NULL,
+		started_at TIMESTAMPTZ NOT NULL,
+		up BOOLEAN NOT NULL,
+		status_code INTEGER NOT NULL DEFAULT 0,
+		dns_resolution_us BIGINT NOT NULL DEFAULT 0,
+		tcp_connection_us BIGINT NOT NULL DEFAULT 0,
+		tls_connection_us BIGINT NOT NULL DEFAULT 0,
+		http_us BIGINT NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS probe_results_monitor_started_at_idx ON probe_results (monitor, started_at DESC);`,
+	Down: `DROP TABLE IF EXISTS probe_results;`,
+}
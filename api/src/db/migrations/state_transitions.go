@@ -0,0 +1,16 @@
+package db_migrations
+
+var createStateTransitions = Migration{
+	Version: 6,
+	Name:    "create_state_transitions",
+	Up: `CREATE TABLE IF NOT EXISTS state_transitions (
+		id BIGSERIAL PRIMARY KEY,
+		monitor TEXT NOT NULL,
+		at TIMESTAMPTZ NOT NULL,
+		up BOOLEAN NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS state_transitions_monitor_at_idx ON state_transitions (monitor, at);
+	ALTER TABLE monitors ADD COLUMN IF NOT EXISTS slo_target DOUBLE PRECISION NOT NULL DEFAULT 0;`,
+	Down: `ALTER TABLE monitors DROP COLUMN IF EXISTS slo_target;
+	DROP TABLE IF EXISTS state_transitions;`,
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Monitor is the stored definition of a scheduled monitor.
+type Monitor struct {
+	Name           string
+	Type           string
+	Host           string
+	Path           string
+	Group          string
+	Tags           []string
+	Interval       time.Duration
+	Timeout        time.Duration
+	ExpectedStatus []int
+	// MaxResponseTime is 0 unless slower probes fail.
+	MaxResponseTime time.Duration
+	// SLOTarget is the availability objective in percent, 0 if unset.
+	SLOTarget        float64
+	FailureThreshold int
+	SuccessThreshold int
+	// Component is the component the monitor joins, besides those
+	// listing it.
+	Component string
+}
+
+func UpsertMonitor(ctx context.Context, conn Querier, monitor Monitor) error {
+	expectedStatus := make([]int32, 0, len(monitor.ExpectedStatus))
+	for _, code := range monitor.ExpectedStatus {
+		expectedStatus = append(expectedStatus, int32(code))
+	}
+
+	_, err := conn.ExecEx(ctx,
+		`INSERT INTO monitors (name, host, path, group_name, tags, interval_ms, timeout_ms, expected_status, slo_target,
+			failure_threshold, success_threshold, type, component, max_response_time_ms, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NOW())
+		ON CONFLICT (name) DO UPDATE SET
+			type = EXCLUDED.type, host = EXCLUDED.host, path = EXCLUDED.path, group_name = EXCLUDED.group_name,
+			tags = EXCLUDED.tags, interval_ms = EXCLUDED.interval_ms, timeout_ms = EXCLUDED.timeout_ms,
+			expected_status = EXCLUDED.expected_status, slo_target = EXCLUDED.slo_target,
+			failure_threshold = EXCLUDED.failure_threshold, success_threshold = EXCLUDED.success_threshold,
+			component = EXCLUDED.component, max_response_time_ms = EXCLUDED.max_response_time_ms, updated_at = NOW()`, nil,
+		monitor.Name, monitor.Host, monitor.Path, monitor.Group, nonNilStrings(monitor.Tags),
+		monitor.Interval.Milliseconds(), monitor.Timeout.Milliseconds(), expectedStatus, monitor.SLOTarget,
+		int32(monitor.FailureThreshold), int32(monitor.SuccessThreshold), monitor.Type, monitor.Component,
+		monitor.MaxResponseTime.Milliseconds(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to upsert monitor '%s': %w", monitor.Name, err)
+	}
+	return nil
+}
+
+func DeleteMonitor(ctx context.Context, conn Querier, name string) error {
+	if _, err := conn.ExecEx(ctx, `DELETE FROM monitors WHERE name = $1`, nil, name); err != nil {
+		return fmt.Errorf("failed to delete monitor '%s': %w", name, err)
+	}
+	return nil
+}
+
+func Monitors(ctx context.Context, conn Querier) ([]Monitor, error) {
+	rows, err := conn.QueryEx(ctx,
+		`SELECT name, host, path, group_name, tags, interval_ms, timeout_ms, expected_status, slo_target,
+			failure_threshold, success_threshold, type, component, max_response_time_ms
+		FROM monitors ORDER BY name`, nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query monitors: %w", err)
+	}
+	defer rows.Close()
+
+	monitors := []Monitor{}
+	for rows.Next() {
+		var monitor Monitor
+		var intervalMs, timeoutMs, maxResponseTimeMs int64
+		var expectedStatus []int32
+		var failureThreshold, successThreshold int32
+		if err := rows.Scan(&monitor.Name, &monitor.Host, &monitor.Path, &monitor.Group, &monitor.Tags,
+			&intervalMs, &timeoutMs, &expectedStatus, &monitor.SLOTarget,
+			&failureThreshold, &successThreshold, &monitor.Type, &monitor.Component, &maxResponseTimeMs); err != nil {
+			return nil, fmt.Errorf("failed to scan monitor: %w", err)
+		}
+		monitor.Interval = time.Duration(intervalMs) * time.Millisecond
+		monitor.Timeout = time.Duration(timeoutMs) * time.Millisecond
+		monitor.MaxResponseTime = time.Duration(maxResponseTimeMs) * time.Millisecond
+		monitor.FailureThreshold = int(failureThreshold)
+		monitor.SuccessThreshold = int(successThreshold)
+		for _, code := range expectedStatus {
+			monitor.ExpectedStatus = append(monitor.ExpectedStatus, int(code))
+		}
+		monitors = append(monitors, monitor)
+	}
+	return monitors, rows.Err()
+}
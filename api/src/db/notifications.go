@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Delivery statuses of a notification.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// NotificationDelivery records the delivery of a single event to a single
+// notification channel, including every retry.
+type NotificationDelivery struct {
+	ID           int64
+	Channel      string
+	Event        string
+	Payload      string
+	Status       string
+	Attempts     int
+	ResponseCode int
+	LastError    string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type DeliveryFilter struct {
+	Channel string
+	Status  string
+	Limit   int
+}
+
+const notificationDeliveryColumns = `id, channel, event, payload, status, attempts,
+	response_code, last_error, created_at, updated_at`
+
+func CreateNotificationDelivery(ctx context.Context, conn Querier, delivery NotificationDelivery) (int64, error) {
+	var id int64
+	err := conn.QueryRowEx(ctx,
+		`INSERT INTO notification_deliveries (channel, event, payload, status, attempts,
+			response_code, last_error, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`, nil,
+		delivery.Channel, delivery.Event, delivery.Payload, delivery.Status, int32(delivery.Attempts),
+		int32(delivery.ResponseCode), delivery.LastError, delivery.CreatedAt, delivery.UpdatedAt,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create notification delivery: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateNotificationDelivery stores the outcome of the latest attempt.
+func UpdateNotificationDelivery(ctx context.Context, conn Querier, delivery NotificationDelivery) error {
+	tag, err := conn.ExecEx(ctx,
+		`UPDATE notification_deliveries SET status = $2, attempts = $3, response_code = $4,
+			last_error = $5, updated_at = $6
+		WHERE id = $1`, nil,
+		delivery.ID, delivery.Status, int32(delivery.Attempts), int32(delivery.ResponseCode),
+		delivery.LastError, delivery.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update notification delivery %d: %w", delivery.ID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// NotificationDeliveries returns the matching deliveries, newest first.
+func NotificationDeliveries(ctx context.Context, conn Querier, filter DeliveryFilter) ([]NotificationDelivery, error) {
+	limit := int64(filter.Limit)
+	if limit <= 0 {
+		limit = 100
+	}
+
+	rows, err := conn.QueryEx(ctx,
+		`SELECT `+notificationDeliveryColumns+` FROM notification_deliveries
+		WHERE ($1 = '' OR channel = $1) AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, id DESC LIMIT $3`, nil,
+		filter.Channel, filter.Status, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notification deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []NotificationDelivery{}
+	for rows.Next() {
+		var delivery NotificationDelivery
+		var attempts, responseCode int32
+		if err := rows.Scan(&delivery.ID, &delivery.Channel, &delivery.Event, &delivery.Payload,
+			&delivery.Status, &attempts, &responseCode, &delivery.LastError,
+			&delivery.CreatedAt, &delivery.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification delivery: %w", err)
+		}
+		delivery.Attempts = int(attempts)
+		delivery.ResponseCode = int(responseCode)
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ProbeResult is a single stored run of utils.HostMetrics for a monitor.
+type ProbeResult struct {
+	ID                int64
+	Monitor           string
+	Host              string
+	StartedAt         time.Time
+	Up                bool
+	StatusCode        int
+	DnsResolutionTime time.Duration
+	TcpConnectionTime time.Duration
+	TlsConnectionTime time.Duration
+	HttpTime          time.Duration
+	Error             string
+}
+
+// ProbeBucket aggregates the probe results of a monitor within one step.
+type ProbeBucket struct {
+	Start             time.Time
+	Count             int
+	UpCount           int
+	DnsResolutionTime time.Duration
+	TcpConnectionTime time.Duration
+	TlsConnectionTime time.Duration
+	HttpTime          time.Duration
+}
+
+func InsertProbeResult(ctx context.Context, conn Querier, result ProbeResult) (int64, error) {
+	var id int64
+	err := conn.QueryRowEx(ctx,
+		`INSERT INTO probe_results (monitor, host, started_at, up, status_code,
+			dns_resolution_us, tcp_connection_us, tls_connection_us, http_us, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`, nil,
+		result.Monitor, result.Host, result.StartedAt, result.Up, int32(result.StatusCode),
+		result.DnsResolutionTime.Microseconds(), result.TcpConnectionTime.Microseconds(),
+		result.TlsConnectionTime.Microseconds(), result.HttpTime.Microseconds(), result.Error,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert probe result: %w", err)
+	}
+	return id, nil
+}
+
+// ProbeResults returns the results of a monitor in [from, to), newest first.
+func ProbeResults(ctx context.Context, conn Querier, monitor string, from, to time.Time, limit int) ([]ProbeResult, error) {
+	rows, err := conn.QueryEx(ctx,
+		`SELECT id, monitor, host, started_at, up, status_code,
+			dns_resolution_us, tcp_connection_us, tls_connection_us, http_us, error
+		FROM probe_results
+		WHERE monitor = $1 AND started_at >= $2 AND started_at < $3
+		ORDER BY started_at DESC
+		LIMIT $4`, nil,
+		monitor, from, to, int64(limit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query probe results: %w", err)
+	}
+	defer rows.Close()
+
+	results := []ProbeResult{}
+	for rows.Next() {
+		var result ProbeResult
+		var statusCode int32
+		var dns, tcp, tls, http int64
+		if err := rows.Scan(&result.ID, &result.Monitor, &result.Host, &result.StartedAt, &result.Up,
+			&statusCode, &dns, &tcp, &tls, &http, &result.Error); err != nil {
+			return nil, fmt.Errorf("failed to scan probe result: %w", err)
+		}
+		result.StatusCode = int(statusCode)
+		result.DnsResolutionTime = time.Duration(dns) * time.Microsecond
+		result.TcpConnectionTime = time.Duration(tcp) * time.Microsecond
+		result.TlsConnectionTime = time.Duration(tls) * time.Microsecond
+		result.HttpTime = time.Duration(http) * time.Microsecond
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// ProbeResultBuckets aggregates the results of a monitor in [from, to) into
+// buckets of the given step, oldest first. Empty buckets are omitted.
+func ProbeResultBuckets(ctx context.Context, conn Querier, monitor string, from, to time.Time, step time.Duration) ([]ProbeBucket, error) {
+	rows, err := conn.QueryEx(ctx,
+		`SELECT to_timestamp(floor(extract(epoch FROM started_at) / $4::float8) * $4::float8) AS bucket,
+			count(*), count(*) FILTER (WHERE up),
+			avg(dns_resolution_us)::float8, avg(tcp_connection_us)::float8,
+			avg(tls_connection_us)::float8, avg(http_us)::float8
+		FROM probe_results
+		WHERE monitor = $1 AND started_at >= $2 AND started_at < $3
+		GROUP BY bucket
+		ORDER BY bucket`, nil,
+		monitor, from, to, step.Seconds(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query probe buckets: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := []ProbeBucket{}
+	for rows.Next() {
+		var bucket ProbeBucket
+		var count, upCount int64
+		var dns, tcp, tls, http float64
+		if err := rows.Scan(&bucket.Start, &count, &upCount, &dns, &tcp, &tls, &http); err != nil {
+			return nil, fmt.Errorf("failed to scan probe bucket: %w", err)
+		}
+		bucket.Count = int(count)
+		bucket.UpCount = int(upCount)
+		bucket.DnsResolutionTime = time.Duration(dns) * time.Microsecond
+		bucket.TcpConnectionTime = time.Duration(tcp) * time.Microsecond
+		bucket.TlsConnectionTime = time.Duration(tls) * time.Microsecond
+		bucket.HttpTime = time.Duration(http) * time.Microsecond
+		buckets = append(buckets, bucket)
+	}
+	return buckets, rows.Err()
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// StateTransition records a monitor changing between up and down, or
+// starting or stopping to flap.
+type StateTransition struct {
+	ID       int64
+	Monitor  string
+	At       time.Time
+	Up       bool
+	Flapping bool
+	// ProbeIDs reference the probe results that triggered the transition.
+	ProbeIDs []int64
+}
+
+func InsertStateTransition(ctx context.Context, conn Querier, transition StateTransition) (int64, error) {
+	var id int64
+	err := conn.QueryRowEx(ctx,
+		`INSERT INTO state_transitions (monitor, at, up, flapping, probe_ids)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`, nil,
+		transition.Monitor, transition.At, transition.Up, transition.Flapping, nonNilInt64s(transition.ProbeIDs),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert state transition: %w", err)
+	}
+	return id, nil
+}
+
+// StateTransitions returns the transitions of a monitor in [from, to), oldest
+// first. The last transition before from is included as well, since it
+// holds the state the monitor was in when the range starts.
+func StateTransitions(ctx context.Context, conn Querier, monitor string, from, to time.Time) ([]StateTransition, error) {
+	rows, err := conn.QueryEx(ctx,
+		`(SELECT id, monitor, at, up, flapping, probe_ids FROM state_transitions
+			WHERE monitor = $1 AND at < $2
+			ORDER BY at DESC, id DESC LIMIT 1)
+		UNION ALL
+		(SELECT id, monitor, at, up, flapping, probe_ids FROM state_transitions
+			WHERE monitor = $1 AND at >= $2 AND at < $3)
+		ORDER BY at, id`, nil,
+		monitor, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query state transitions: %w", err)
+	}
+	defer rows.Close()
+
+	transitions := []StateTransition{}
+	for rows.Next() {
+		var transition StateTransition
+		if err := rows.Scan(&transition.ID, &transition.Monitor, &transition.At, &transition.Up,
+			&transition.Flapping, &transition.ProbeIDs); err != nil {
+			return nil, fmt.Errorf("failed to scan state transition: %w", err)
+		}
+		transitions = append(transitions, transition)
+	}
+	return transitions, rows.Err()
+}
+
+func nonNilInt64s(values []int64) []int64 {
+	if values == nil {
+		return []int64{}
+	}
+	return values
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("iammati/statuspage/db")
+
+// Traced wraps a Querier so every write (INSERT, UPDATE or DELETE) is
+// recorded as a span. Reads pass through untouched.
+func Traced(conn Querier) Querier {
+	return tracedQuerier{conn}
+}
+
+type tracedQuerier struct {
+	conn Querier
+}
+
+// start begins a span named after the operation and table if sql is a
+// write. The span is nil otherwise.
+func (t tracedQuerier) start(ctx context.Context, sql string) (context.Context, trace.Span) {
+	words := strings.Fields(sql)
+	if len(words) < 3 {
+		return ctx, nil
+	}
+	operation, table := strings.ToUpper(words[0]), words[1]
+	switch operation {
+	case "INSERT", "DELETE":
+		// INSERT INTO table, DELETE FROM table
+		table = words[2]
+	case "UPDATE":
+	default:
+		return ctx, nil
+	}
+	return tracer.Start(ctx, operation+" "+table, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemPostgreSQL,
+		semconv.DBOperationName(operation),
+		semconv.DBCollectionName(table),
+		semconv.DBQueryText(sql),
+	))
+}
+
+func end(span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t tracedQuerier) ExecEx(ctx context.Context, sql string, options *pgx.QueryExOptions, arguments ...interface{}) (pgx.CommandTag, error) {
+	ctx, span := t.start(ctx, sql)
+	tag, err := t.conn.ExecEx(ctx, sql, options, arguments...)
+	end(span, err)
+	return tag, err
+}
+
+func (t tracedQuerier) QueryEx(ctx context.Context, sql string, options *pgx.QueryExOptions, args ...interface{}) (*pgx.Rows, error) {
+	ctx, span := t.start(ctx, sql)
+	rows, err := t.conn.QueryEx(ctx, sql, options, args...)
+	end(span, err)
+	return rows, err
+}
+
+// QueryRowEx can't see errors, which only surface when the row is scanned.
+func (t tracedQuerier) QueryRowEx(ctx context.Context, sql string, options *pgx.QueryExOptions, args ...interface{}) *pgx.Row {
+	ctx, span := t.start(ctx, sql)
+	row := t.conn.QueryRowEx(ctx, sql, options, args...)
+	end(span, nil)
+	return row
+}
@@ -0,0 +1,130 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"iammati/statuspage/scheduler"
+)
+
+// Annotations app teams tune the monitors of their pods, Services, Ingresses
+// and HTTPRoutes with.
+const (
+	// AnnotationEnabled opts an object out with "false", or in with "true"
+	// when discovery is opt-in.
+	AnnotationEnabled = "statuspage/enabled"
+	// AnnotationHost lists further comma-separated hosts to monitor. It is
+	// the only source of hosts for Services.
+	AnnotationHost     = "statuspage/host"
+	AnnotationPath     = "statuspage/path"
+	AnnotationInterval = "statuspage/interval"
+	// AnnotationExpectedStatus is a comma-separated list of status codes.
+	AnnotationExpectedStatus = "statuspage/expected-status"
+	// AnnotationComponent is the status page component the monitors join.
+	AnnotationComponent = "statuspage/component"
+)
+
+// annotations are the monitor settings of an annotated object.
+type annotations struct {
+	enabled        *bool
+	hosts          []string
+	path           string
+	interval       time.Duration
+	expectedStatus []int
+	component      string
+}
+
+// parseAnnotations reads the monitor settings of an object. Invalid values
+// are logged and ignored, leaving the rest in effect.
+func parseAnnotations(kind string, obj metav1.Object) annotations {
+	values := obj.GetAnnotations()
+	invalid := func(annotation string, err error) {
+		slog.Warn("Ignoring invalid annotation", kind, obj.GetNamespace()+"/"+obj.GetName(),
+			"annotation", annotation, "value", values[annotation], "error", err)
+	}
+
+	var a annotations
+	if value, ok := values[AnnotationEnabled]; ok {
+		if enabled, err := strconv.ParseBool(value); err != nil {
+			invalid(AnnotationEnabled, err)
+		} else {
+			a.enabled = &enabled
+		}
+	}
+	for _, host := range strings.Split(values[AnnotationHost], ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			a.hosts = append(a.hosts, host)
+		}
+	}
+	if value := values[AnnotationPath]; value != "" {
+		if strings.HasPrefix(value, "/") {
+			a.path = value
+		} else {
+			invalid(AnnotationPath, errors.New("must start with '/'"))
+		}
+	}
+	if value := values[AnnotationInterval]; value != "" {
+		interval, err := time.ParseDuration(value)
+		if err == nil && interval < time.Second {
+			err = errors.New("must be at least 1s")
+		}
+		if err != nil {
+			invalid(AnnotationInterval, err)
+		} else {
+			a.interval = interval
+		}
+	}
+	if value := values[AnnotationExpectedStatus]; value != "" {
+		for _, item := range strings.Split(value, ",") {
+			code, err := strconv.Atoi(strings.TrimSpace(item))
+			if err == nil && (code < 100 || code > 599) {
+				err = fmt.Errorf("%d is not a valid HTTP status code", code)
+			}
+			if err != nil {
+				invalid(AnnotationExpectedStatus, err)
+				a.expectedStatus = nil
+				break
+			}
+			a.expectedStatus = append(a.expectedStatus, code)
+		}
+	}
+	a.component = strings.TrimSpace(values[AnnotationComponent])
+	return a
+}
+
+// discovered reports whether the object is monitored. Objects opt out with
+// statuspage/enabled=false and, if optIn is set, in with "true".
+func (a annotations) discovered(optIn bool) bool {
+	if a.enabled != nil {
+		return *a.enabled
+	}
+	return !optIn
+}
+
+// apply overrides the settings of a monitor with the annotated ones. The
+// path and expected status only apply to HTTP monitors.
+func (a annotations) apply(monitor scheduler.Monitor) scheduler.Monitor {
+	if a.interval > 0 {
+		monitor.Interval = a.interval
+	}
+	if a.component != "" {
+		monitor.Component = a.component
+	}
+	if monitor.Type == scheduler.TypeCertificate {
+		return monitor
+	}
+	if a.path != "" {
+		monitor.Path = a.path
+	}
+	if len(a.expectedStatus) > 0 {
+		monitor.ExpectedStatus = slices.Clone(a.expectedStatus)
+	}
+	return monitor
+}
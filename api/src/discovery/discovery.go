@@ -0,0 +1,120 @@
+package discovery
+
+import (
+	"log/slog"
+	"slices"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/scheduler"
+)
+
+// factory is a typed or dynamic shared informer factory.
+type factory interface {
+	Start(stop <-chan struct{})
+	Shutdown()
+}
+
+// watcher runs informers whose objects each expose their own monitors.
+type watcher struct {
+	ledger    *ledger
+	factories []factory
+	informers []cache.SharedIndexInformer
+}
+
+// watch syncs the objects of an informer and forgets the monitors of those
+// deleted.
+func (w *watcher) watch(factory factory, informer cache.SharedIndexInformer, kind string, sync func(obj interface{})) {
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    sync,
+		UpdateFunc: func(_, obj interface{}) { sync(obj) },
+		DeleteFunc: func(obj interface{}) {
+			if key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
+				w.ledger.apply(object{kind, key}, "", nil)
+			}
+		},
+	})
+	w.factories = append(w.factories, factory)
+	w.informers = append(w.informers, informer)
+}
+
+// HasSynced reports whether the initial lists have been handled.
+func (w *watcher) HasSynced() bool {
+	for _, informer := range w.informers {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// Controller discovers monitors from pods and, if enabled, from Ingresses,
+// HTTPRoutes and Services. A monitor is removed once no object exposes it
+// anymore.
+type Controller struct {
+	ledger    *ledger
+	pods      *podController
+	ingresses *ingressController
+	services  *serviceController
+}
+
+// FromSettings creates the controllers of the discovery settings. The
+// dynamic client is only used for HTTPRoutes and may be nil.
+func FromSettings(clientset kubernetes.Interface, dynamicClient dynamic.Interface, registry scheduler.Registry, settings config.DiscoverySettings) (*Controller, error) {
+	c := &Controller{ledger: newLedger(registry)}
+
+	var err error
+	if c.pods, err = newPodController(clientset, c.ledger, settings); err != nil {
+		return nil, err
+	}
+	if settings.Ingresses.Enabled {
+		if c.ingresses, err = newIngressController(clientset, dynamicClient, c.ledger, settings); err != nil {
+			return nil, err
+		}
+	}
+	if settings.Services.Enabled {
+		c.services = newServiceController(clientset, c.ledger, settings)
+	}
+	return c, nil
+}
+
+func (c *Controller) factories() []factory {
+	factories := c.pods.factories
+	if c.ingresses != nil {
+		factories = slices.Concat(factories, c.ingresses.factories)
+	}
+	if c.services != nil {
+		factories = slices.Concat(factories, c.services.factories)
+	}
+	return factories
+}
+
+// Run starts the informers and blocks until stop is closed.
+func (c *Controller) Run(stop <-chan struct{}) {
+	for _, factory := range c.factories() {
+		factory.Start(stop)
+	}
+	if cache.WaitForCacheSync(stop, c.HasSynced) {
+		slog.Info("Discovered monitors", "targets", len(c.pods.targets),
+			"ingresses", c.ingresses != nil, "services", c.services != nil, "monitors", len(c.Monitors()))
+	}
+	<-stop
+	for _, factory := range c.factories() {
+		factory.Shutdown()
+	}
+}
+
+// HasSynced reports whether the initial lists have been handled.
+func (c *Controller) HasSynced() bool {
+	return c.pods.HasSynced() &&
+		(c.ingresses == nil || c.ingresses.HasSynced()) &&
+		(c.services == nil || c.services.HasSynced())
+}
+
+// Monitors returns the keys of the discovered monitors.
+func (c *Controller) Monitors() []string {
+	return c.ledger.keys()
+}
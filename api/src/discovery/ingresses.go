@@ -0,0 +1,167 @@
+package discovery
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/scheduler"
+)
+
+// HTTPRoutes is the Gateway API resource HTTPRoutes are read from.
+var HTTPRoutes = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}
+
+// CertificatePrefix prefixes the keys of certificate monitors, which would
+// otherwise clash with the HTTP monitor of the same host.
+const CertificatePrefix = "certificate:"
+
+// ingressController turns the hosts of Ingresses and HTTPRoutes into HTTP
+// monitors and the TLS hosts of Ingresses into certificate monitors.
+type ingressController struct {
+	watcher
+	settings config.IngressDiscovery
+	optIn    bool
+}
+
+// newIngressController watches the Ingresses of the configured namespaces,
+// and their HTTPRoutes if enabled and served by the cluster.
+func newIngressController(clientset kubernetes.Interface, dynamicClient dynamic.Interface, ledger *ledger, settings config.DiscoverySettings) (*ingressController, error) {
+	ingresses := settings.Ingresses
+	if _, err := labels.Parse(ingresses.Selector); err != nil {
+		return nil, fmt.Errorf("ingresses: invalid selector: %v", err)
+	}
+	tweak := func(list *metav1.ListOptions) {
+		list.LabelSelector = ingresses.Selector
+	}
+
+	routes := ingresses.HTTPRoutes && dynamicClient != nil && servesHTTPRoutes(clientset)
+	if ingresses.HTTPRoutes && !routes {
+		slog.Info("HTTPRoutes are not served, only watching Ingresses")
+	}
+
+	c := &ingressController{watcher: watcher{ledger: ledger}, settings: ingresses, optIn: settings.OptIn}
+	namespaces := ingresses.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	for i, namespace := range namespaces {
+		if slices.Contains(namespaces[:i], namespace) {
+			continue
+		}
+		factory := informers.NewSharedInformerFactoryWithOptions(clientset, settings.Resync,
+			informers.WithNamespace(namespace),
+			informers.WithTweakListOptions(tweak),
+		)
+		c.watch(factory, factory.Networking().V1().Ingresses().Informer(), "ingress", c.syncIngress)
+
+		if routes {
+			factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, settings.Resync, namespace, tweak)
+			c.watch(factory, factory.ForResource(HTTPRoutes).Informer(), "httproute", c.syncRoute)
+		}
+	}
+	return c, nil
+}
+
+// servesHTTPRoutes reports whether the Gateway API CRDs are installed.
+func servesHTTPRoutes(clientset kubernetes.Interface) bool {
+	resources, err := clientset.Discovery().ServerResourcesForGroupVersion(HTTPRoutes.GroupVersion().String())
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(resources.APIResources, func(resource metav1.APIResource) bool {
+		return resource.Name == HTTPRoutes.Resource
+	})
+}
+
+func (c *ingressController) syncIngress(obj interface{}) {
+	ingress, ok := obj.(*networkingv1.Ingress)
+	if !ok {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(ingress)
+	if err != nil {
+		return
+	}
+
+	annotations := parseAnnotations("ingress", ingress)
+	hosts := slices.Clone(annotations.hosts)
+	var tlsHosts []string
+	for _, rule := range ingress.Spec.Rules {
+		hosts = append(hosts, rule.Host)
+	}
+	for _, tls := range ingress.Spec.TLS {
+		tlsHosts = append(tlsHosts, tls.Hosts...)
+	}
+	if ingress.DeletionTimestamp != nil || !annotations.discovered(c.optIn) {
+		hosts, tlsHosts = nil, nil
+	}
+	c.ledger.apply(object{"ingress", key}, ingress.Namespace, c.monitors(ingress.Namespace, hosts, tlsHosts, annotations))
+}
+
+// syncRoute monitors the hostnames of an HTTPRoute. Its certificates belong
+// to the listeners of the Gateway and aren't discovered.
+func (c *ingressController) syncRoute(obj interface{}) {
+	route, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(route)
+	if err != nil {
+		return
+	}
+
+	annotations := parseAnnotations("httproute", route)
+	hosts, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	hosts = slices.Concat(annotations.hosts, hosts)
+	if route.GetDeletionTimestamp() != nil || !annotations.discovered(c.optIn) {
+		hosts = nil
+	}
+	c.ledger.apply(object{"httproute", key}, route.GetNamespace(), c.monitors(route.GetNamespace(), hosts, nil, annotations))
+}
+
+// monitors returns an HTTP monitor per host and, if enabled, a certificate
+// monitor per TLS host, tuned by the annotations of their object. Wildcard
+// hosts can't be probed and are skipped.
+func (c *ingressController) monitors(namespace string, hosts []string, tlsHosts []string, annotations annotations) []scheduler.Monitor {
+	var monitors []scheduler.Monitor
+	for _, host := range hosts {
+		if probeable(host) {
+			monitors = appendMonitor(monitors, annotations.apply(newMonitor(c.settings.Monitor, host, namespace)))
+		}
+	}
+	if !c.settings.Certificates {
+		return monitors
+	}
+	for _, host := range tlsHosts {
+		if probeable(host) {
+			monitors = appendMonitor(monitors, annotations.apply(certificateMonitor(c.settings.Monitor, host, namespace)))
+		}
+	}
+	return monitors
+}
+
+func certificateMonitor(template config.MonitorTemplate, host string, namespace string) scheduler.Monitor {
+	monitor := newMonitor(template, host, namespace)
+	monitor.Name = CertificatePrefix + host
+	monitor.Type = scheduler.TypeCertificate
+	monitor.Path = ""
+	monitor.ExpectedStatus = nil
+	return monitor
+}
+
+func probeable(host string) bool {
+	return host != "" && !strings.Contains(host, "*")
+}
@@ -0,0 +1,96 @@
+package discovery
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+
+	"iammati/statuspage/scheduler"
+)
+
+// object identifies a Kubernetes object exposing monitors by its kind and
+// namespace/name key.
+type object struct {
+	kind string
+	key  string
+}
+
+// ledger tracks which objects expose each discovered monitor, so a monitor
+// exposed by pods and Ingresses alike stays until the last of them is gone.
+type ledger struct {
+	registry scheduler.Registry
+
+	mu sync.Mutex
+	// monitors are the monitors each object exposes, owners the objects
+	// exposing each monitor key; replicas usually share a domain.
+	monitors map[object][]scheduler.Monitor
+	owners   map[string]map[object]bool
+}
+
+func newLedger(registry scheduler.Registry) *ledger {
+	return &ledger{
+		registry: registry,
+		monitors: make(map[object][]scheduler.Monitor),
+		owners:   make(map[string]map[object]bool),
+	}
+}
+
+// keys returns the keys of the discovered monitors.
+func (l *ledger) keys() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	keys := make([]string, 0, len(l.owners))
+	for key := range l.owners {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// apply registers the monitors an object exposes and removes those no object
+// exposes anymore. Only new and changed definitions are upserted, since
+// every resync and status update of the object applies it again.
+func (l *ledger) apply(owner object, namespace string, monitors []scheduler.Monitor) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, previous := range l.monitors[owner] {
+		key := previous.Key()
+		if slices.ContainsFunc(monitors, func(m scheduler.Monitor) bool { return m.Key() == key }) {
+			continue
+		}
+		delete(l.owners[key], owner)
+		if len(l.owners[key]) == 0 {
+			delete(l.owners, key)
+			l.registry.Remove(key)
+			slog.Info("Removed discovered monitor", "monitor", key, owner.kind, owner.key)
+		}
+	}
+
+	for _, monitor := range monitors {
+		key := monitor.Key()
+		if l.owners[key] == nil {
+			l.owners[key] = make(map[object]bool)
+			slog.Info("Discovered monitor", "monitor", key, "host", monitor.Host, "namespace", namespace, owner.kind, owner.key)
+		}
+		l.owners[key][owner] = true
+		if !slices.ContainsFunc(l.monitors[owner], monitor.Equal) {
+			l.registry.Upsert(monitor)
+		}
+	}
+
+	if len(monitors) == 0 {
+		delete(l.monitors, owner)
+	} else {
+		l.monitors[owner] = monitors
+	}
+}
+
+// appendMonitor appends monitor unless one with the same key is listed.
+func appendMonitor(monitors []scheduler.Monitor, monitor scheduler.Monitor) []scheduler.Monitor {
+	if slices.ContainsFunc(monitors, func(m scheduler.Monitor) bool { return m.Key() == monitor.Key() }) {
+		return monitors
+	}
+	return append(monitors, monitor)
+}
@@ -0,0 +1,177 @@
+package discovery
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/scheduler"
+)
+
+// podController turns running pods selected by the discovery targets into
+// monitors and removes a monitor once no pod exposes it anymore. It is
+// backed by shared informers, which relist and rewatch after the API server
+// closes the stream.
+type podController struct {
+	ledger  *ledger
+	targets []target
+	optIn   bool
+
+	factories []factory
+	// pods has an informer per watched namespace and pod selector.
+	pods []cache.SharedIndexInformer
+	// namespaces is only watched if a target selects namespaces by label.
+	namespaces cache.SharedIndexInformer
+}
+
+// newPodController watches the pods of the configured targets, or
+// DefaultTarget if there are none. Every resync, all cached pods are synced
+// again, recreating monitors that went missing.
+func newPodController(clientset kubernetes.Interface, ledger *ledger, settings config.DiscoverySettings) (*podController, error) {
+	resync := settings.Resync
+	targets := settings.Targets
+	if len(targets) == 0 {
+		targets = []config.DiscoveryTarget{DefaultTarget}
+	}
+
+	c := &podController{ledger: ledger, optIn: settings.OptIn}
+	watched := make(map[[2]string]bool)
+	for _, targetSettings := range targets {
+		t, err := newTarget(targetSettings)
+		if err != nil {
+			return nil, err
+		}
+		c.targets = append(c.targets, t)
+
+		for _, namespace := range t.scopes() {
+			for _, selector := range t.selectors() {
+				if watched[[2]string{namespace, selector}] {
+					continue
+				}
+				watched[[2]string{namespace, selector}] = true
+				c.watchPods(clientset, namespace, selector, resync)
+			}
+		}
+		if !t.namespaceSelector.Empty() && c.namespaces == nil {
+			factory := informers.NewSharedInformerFactory(clientset, resync)
+			c.factories = append(c.factories, factory)
+			c.namespaces = factory.Core().V1().Namespaces().Informer()
+			c.namespaces.AddEventHandler(cache.ResourceEventHandlerFuncs{
+				AddFunc:    c.syncNamespace,
+				UpdateFunc: func(_, obj interface{}) { c.syncNamespace(obj) },
+				DeleteFunc: c.syncNamespace,
+			})
+		}
+	}
+	return c, nil
+}
+
+func (c *podController) watchPods(clientset kubernetes.Interface, namespace string, selector string, resync time.Duration) {
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, resync,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(list *metav1.ListOptions) {
+			list.LabelSelector = selector
+		}),
+	)
+	informer := factory.Core().V1().Pods().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.syncPod,
+		UpdateFunc: func(_, obj interface{}) { c.syncPod(obj) },
+		DeleteFunc: c.deletePod,
+	})
+	c.factories = append(c.factories, factory)
+	c.pods = append(c.pods, informer)
+}
+
+// HasSynced reports whether the initial lists have been handled.
+func (c *podController) HasSynced() bool {
+	if c.namespaces != nil && !c.namespaces.HasSynced() {
+		return false
+	}
+	for _, informer := range c.pods {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *podController) namespaceLabels(namespace string) labels.Set {
+	if c.namespaces == nil {
+		return nil
+	}
+	obj, exists, err := c.namespaces.GetStore().GetByKey(namespace)
+	if err != nil || !exists {
+		return nil
+	}
+	return obj.(*v1.Namespace).Labels
+}
+
+func (c *podController) syncPod(obj interface{}) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(pod)
+	if err != nil {
+		return
+	}
+
+	// The first target exposing a monitor decides its settings, unless the
+	// pod overrides them.
+	annotations := parseAnnotations("pod", pod)
+	var monitors []scheduler.Monitor
+	namespaceLabels := c.namespaceLabels(pod.Namespace)
+	for _, t := range c.targets {
+		if !annotations.discovered(c.optIn) || !t.matches(pod, namespaceLabels) {
+			continue
+		}
+		for _, monitor := range t.monitors(pod, annotations.hosts) {
+			monitors = appendMonitor(monitors, annotations.apply(monitor))
+		}
+	}
+	c.ledger.apply(object{"pod", key}, pod.Namespace, monitors)
+}
+
+// deletePod forgets a pod once it is gone from every informer. It may only
+// have left the selection of one, e.g. after its labels changed.
+func (c *podController) deletePod(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	for _, informer := range c.pods {
+		if pod, exists, err := informer.GetStore().GetByKey(key); err == nil && exists {
+			c.syncPod(pod)
+			return
+		}
+	}
+	c.ledger.apply(object{"pod", key}, "", nil)
+}
+
+// syncNamespace re-evaluates the pods of a namespace whose labels changed.
+func (c *podController) syncNamespace(obj interface{}) {
+	name, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	seen := make(map[string]bool)
+	for _, informer := range c.pods {
+		pods, err := informer.GetIndexer().ByIndex(cache.NamespaceIndex, name)
+		if err != nil {
+			continue
+		}
+		for _, pod := range pods {
+			if key, err := cache.MetaNamespaceKeyFunc(pod); err == nil && !seen[key] {
+				seen[key] = true
+				c.syncPod(pod)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package discovery
+
+import (
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/scheduler"
+)
+
+// serviceController monitors the hosts Services list in their
+// statuspage/host annotation. Services have no public host of their own, so
+// the annotation is how they opt in.
+type serviceController struct {
+	watcher
+}
+
+func newServiceController(clientset kubernetes.Interface, ledger *ledger, settings config.DiscoverySettings) *serviceController {
+	c := &serviceController{watcher{ledger: ledger}}
+	namespaces := settings.Services.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	for i, namespace := range namespaces {
+		if slices.Contains(namespaces[:i], namespace) {
+			continue
+		}
+		factory := informers.NewSharedInformerFactoryWithOptions(clientset, settings.Resync, informers.WithNamespace(namespace))
+		c.watch(factory, factory.Core().V1().Services().Informer(), "service", c.syncService)
+	}
+	return c
+}
+
+func (c *serviceController) syncService(obj interface{}) {
+	service, ok := obj.(*v1.Service)
+	if !ok {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(service)
+	if err != nil {
+		return
+	}
+
+	annotations := parseAnnotations("service", service)
+	var monitors []scheduler.Monitor
+	if service.DeletionTimestamp == nil && annotations.discovered(false) {
+		for _, host := range annotations.hosts {
+			if probeable(host) {
+				monitors = appendMonitor(monitors, annotations.apply(newMonitor(config.MonitorTemplate{}, host, service.Namespace)))
+			}
+		}
+	}
+	c.ledger.apply(object{"service", key}, service.Namespace, monitors)
+}
@@ -0,0 +1,132 @@
+package discovery
+
+import (
+	"fmt"
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/scheduler"
+)
+
+// DomainEnv is the container variable pods expose their public domain in,
+// unless a target names others.
+const DomainEnv = "CLIENT_DOMAIN"
+
+// Tag marks every discovered monitor.
+const Tag = "kubernetes"
+
+// DefaultTarget is discovered when no targets are configured.
+var DefaultTarget = config.DiscoveryTarget{
+	Name:         "default",
+	Namespaces:   []string{"sh-jenniferwalker"},
+	PodSelectors: []string{"workload-class=webstack-php"},
+}
+
+// target is a DiscoveryTarget with its selectors parsed.
+type target struct {
+	config.DiscoveryTarget
+	namespaceSelector labels.Selector
+	podSelectors      []labels.Selector
+}
+
+func newTarget(settings config.DiscoveryTarget) (target, error) {
+	t := target{DiscoveryTarget: settings}
+	if len(t.EnvVars) == 0 {
+		t.EnvVars = []string{DomainEnv}
+	}
+
+	var err error
+	if t.namespaceSelector, err = labels.Parse(settings.NamespaceSelector); err != nil {
+		return t, fmt.Errorf("target '%s': invalid namespace selector: %v", settings.Name, err)
+	}
+	for _, value := range settings.PodSelectors {
+		selector, err := labels.Parse(value)
+		if err != nil {
+			return t, fmt.Errorf("target '%s': invalid pod selector: %v", settings.Name, err)
+		}
+		t.podSelectors = append(t.podSelectors, selector)
+	}
+	return t, nil
+}
+
+// scopes returns the namespaces to watch, "" standing for all of them.
+func (t target) scopes() []string {
+	if len(t.Namespaces) == 0 {
+		return []string{""}
+	}
+	return t.Namespaces
+}
+
+// selectors returns the pod selectors to watch with, "" selecting every pod.
+func (t target) selectors() []string {
+	if len(t.PodSelectors) == 0 {
+		return []string{""}
+	}
+	return t.PodSelectors
+}
+
+// matches reports whether the target selects a pod in a namespace with the
+// given labels.
+func (t target) matches(pod *v1.Pod, namespaceLabels labels.Set) bool {
+	if len(t.Namespaces) > 0 && !slices.Contains(t.Namespaces, pod.Namespace) {
+		return false
+	}
+	if !t.namespaceSelector.Empty() && !t.namespaceSelector.Matches(namespaceLabels) {
+		return false
+	}
+	if len(t.podSelectors) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(t.podSelectors, func(selector labels.Selector) bool {
+		return selector.Matches(labels.Set(pod.Labels))
+	})
+}
+
+// monitors returns a monitor for every domain a running pod exposes, along
+// with the annotated hosts.
+func (t target) monitors(pod *v1.Pod, hosts []string) []scheduler.Monitor {
+	if pod.Status.Phase != v1.PodRunning || pod.DeletionTimestamp != nil {
+		return nil
+	}
+	domains := slices.Clone(hosts)
+	for _, container := range pod.Spec.Containers {
+		for _, env := range container.Env {
+			if slices.Contains(t.EnvVars, env.Name) && env.Value != "" && !slices.Contains(domains, env.Value) {
+				domains = append(domains, env.Value)
+			}
+		}
+	}
+
+	monitors := make([]scheduler.Monitor, 0, len(domains))
+	for _, domain := range domains {
+		monitors = append(monitors, newMonitor(t.Monitor, domain, pod.Namespace))
+	}
+	return monitors
+}
+
+// newMonitor applies a template to the monitor of a host found in a
+// namespace.
+func newMonitor(template config.MonitorTemplate, host string, namespace string) scheduler.Monitor {
+	monitor := scheduler.Monitor{
+		Host:             host,
+		Path:             template.Path,
+		Group:            template.Group,
+		Tags:             slices.Clone(template.Tags),
+		Interval:         template.Interval,
+		Timeout:          template.Timeout,
+		ExpectedStatus:   slices.Clone(template.ExpectedStatus),
+		SLOTarget:        template.SLO,
+		FailureThreshold: template.FailureThreshold,
+		SuccessThreshold: template.SuccessThreshold,
+	}
+	if monitor.Group == "" {
+		monitor.Group = namespace
+	}
+	if !slices.Contains(monitor.Tags, Tag) {
+		monitor.Tags = append(monitor.Tags, Tag)
+	}
+	return monitor
+}
@@ -2,7 +2,7 @@ package handlers
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"slices"
 	"strings"
@@ -10,7 +10,8 @@ import (
 	"time"
 
 	"iammati/statuspage/config"
-	db_migrations "iammati/statuspage/db/migrations"
+	"iammati/statuspage/health"
+	"iammati/statuspage/scheduler"
 	"iammati/statuspage/utils"
 )
 
@@ -22,6 +23,11 @@ type ServiceState struct {
 	LastChange        time.Time
 	UpdatetimeStart   time.Time
 	LastRequestTime   time.Time
+	// Flapping is set while the raw probe results change too often for
+	// IsUp to be meaningful.
+	Flapping bool
+
+	tracker health.Tracker
 }
 
 type ServiceStates struct {
@@ -31,58 +37,177 @@ type ServiceStates struct {
 
 var serviceStates = ServiceStates{states: make(map[string]*ServiceState)}
 
-type UpdatetimeEvent struct {
-	Time   time.Time
-	Reason string
+// Scheduler probes the registered monitors, each on its own interval.
+var Scheduler *scheduler.Scheduler
+
+// Transition is emitted whenever a monitor is confirmed up or down, or
+// starts or stops flapping.
+type Transition struct {
+	Monitor  string
+	IsUp     bool
+	Flapping bool
+	At       time.Time
+	// Initial is set when the first probe of a monitor establishes its state.
+	Initial bool
+	// ProbeIDs are the stored probes that triggered the transition.
+	ProbeIDs []int64
+	// Metrics of the probe that completed the transition.
+	Metrics utils.Metrics
+}
+
+var (
+	transitionListeners   []func(Transition)
+	transitionListenersMu sync.RWMutex
+
+	probeListeners   []func(scheduler.Result)
+	probeListenersMu sync.RWMutex
+)
+
+// OnTransition registers a listener that is called, outside of the service
+// state lock, for every up/down transition of a monitor.
+func OnTransition(listener func(Transition)) {
+	transitionListenersMu.Lock()
+	defer transitionListenersMu.Unlock()
+
+	transitionListeners = append(transitionListeners, listener)
 }
 
-func LogUpdatetimeEvent(event UpdatetimeEvent, createNotification bool) {
-	db_migrations.InsertLogEntry(config.DbConn, db_migrations.LogEntry{
-		Timestamp: event.Time.Local().Format(time.RFC3339),
-		Level:     "INFO",
-		Message:   event.Reason,
-	})
+// OnProbe registers a listener that is called with the result of every
+// scheduled probe once it has been recorded.
+func OnProbe(listener func(scheduler.Result)) {
+	probeListenersMu.Lock()
+	defer probeListenersMu.Unlock()
+
+	probeListeners = append(probeListeners, listener)
 }
 
-func (ss *ServiceStates) UpdateServiceState(host string, isCurrentlyUp bool) {
+func notifyProbe(result scheduler.Result) {
+	probeListenersMu.RLock()
+	listeners := slices.Clone(probeListeners)
+	probeListenersMu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(result)
+	}
+}
+
+func notifyTransition(transition Transition) {
+	transitionListenersMu.RLock()
+	listeners := slices.Clone(transitionListeners)
+	transitionListenersMu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(transition)
+	}
+}
+
+// Forget drops the state of a monitor that is no longer probed.
+func (ss *ServiceStates) Forget(host string) {
 	ss.mu.Lock()
 	defer ss.mu.Unlock()
 
+	delete(ss.states, host)
+}
+
+// UpdateServiceState feeds a probe result and its metrics into the state of
+// a host. The state only changes once the thresholds are met, and listeners
+// are told about every resulting transition.
+func (ss *ServiceStates) UpdateServiceState(host string, thresholds health.Thresholds, probe health.Probe, metrics utils.Metrics) {
+	if transition, changed := ss.updateServiceState(host, thresholds, probe); changed {
+		transition.Metrics = metrics
+		notifyTransition(transition)
+	}
+}
+
+func flapDetection() health.FlapDetection {
+	return health.FlapDetection{
+		Window: config.AppSettings.Flapping.Window,
+		High:   config.AppSettings.Flapping.HighThreshold,
+		Low:    config.AppSettings.Flapping.LowThreshold,
+	}
+}
+
+func (ss *ServiceStates) updateServiceState(host string, thresholds health.Thresholds, probe health.Probe) (Transition, bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	now := time.Now()
 	currentState, exists := ss.states[host]
 	if !exists {
-		log.Printf("Added host '%s' to the list of monitored hosts", host)
-		now := time.Now()
-		ss.states[host] = &ServiceState{
+		slog.Debug("Added monitor to the monitored hosts", "monitor", host)
+		currentState = &ServiceState{
 			Host:            host,
-			IsUp:            isCurrentlyUp,
+			IsUp:            probe.Up,
 			LastChange:      now,
 			UpdatetimeStart: now,
-			LastRequestTime: now,
 		}
-		return
+		ss.states[host] = currentState
 	}
 
-	currentState.LastRequestTime = time.Now()
+	currentState.LastRequestTime = now
+
+	// The initial state counts as a transition as well, so uptime is known
+	// from the first probe on and a host that starts out down gets an incident.
+	change, changed := currentState.tracker.Observe(probe, thresholds, flapDetection())
+	if !changed {
+		return Transition{}, false
+	}
+
+	if change.Flapping != currentState.Flapping {
+		currentState.Flapping = change.Flapping
+		if change.Flapping {
+			slog.Warn("Monitor started flapping", "monitor", host)
+		} else {
+			slog.Info("Monitor stopped flapping", "monitor", host)
+		}
+	}
 
-	if currentState.IsUp != isCurrentlyUp {
-		currentState.IsUp = isCurrentlyUp
-		currentState.LastChange = time.Now()
+	if exists && currentState.IsUp != change.Up {
+		currentState.IsUp = change.Up
+		currentState.LastChange = now
 
-		if !isCurrentlyUp {
-			currentState.UpdatetimeStart = time.Now()
+		if !change.Up {
+			currentState.UpdatetimeStart = now
 		} else {
 			currentState.UpdatetimeStart = time.Time{}
 		}
 
-		status := "down"
-		if isCurrentlyUp {
-			status = "up"
+		if change.Up {
+			slog.Info("Monitor is up", "monitor", host)
+		} else {
+			slog.Warn("Monitor is down", "monitor", host)
 		}
-		LogUpdatetimeEvent(UpdatetimeEvent{
-			Time:   time.Now(),
-			Reason: fmt.Sprintf("aHost '%s' status changed to %s", host, status),
-		}, true)
 	}
+
+	return Transition{
+		Monitor:  host,
+		IsUp:     change.Up,
+		Flapping: change.Flapping,
+		At:       change.At,
+		Initial:  change.Initial,
+		ProbeIDs: change.ProbeIDs,
+	}, true
+}
+
+func thresholds(monitor scheduler.Monitor) health.Thresholds {
+	monitor = monitor.WithDefaults()
+	return health.Thresholds{Failures: monitor.FailureThreshold, Successes: monitor.SuccessThreshold}
+}
+
+// RecordProbe feeds the result of a scheduled probe into the service states.
+func RecordProbe(result scheduler.Result) {
+	id := storeProbeResult(result.Monitor.Key(), result.Monitor.Host, result.Started, result.Up(), result.Metrics, result.Err)
+	observeProbe(result.Monitor.Key(), result.Up(), result.Metrics)
+	metrics := result.Metrics
+	if result.Err != nil {
+		metrics.Error = result.Err
+	}
+	serviceStates.UpdateServiceState(result.Monitor.Key(), thresholds(result.Monitor), health.Probe{
+		ID: id,
+		Up: result.Up(),
+		At: result.Started,
+	}, metrics)
+	notifyProbe(result)
 }
 
 var hosts = []string{}
@@ -91,16 +216,7 @@ func MonitorHostChanges(timeout time.Duration) {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
-	logAndUpdate := func(reason string, currentTime time.Time) {
-		log.Println(reason)
-		LogUpdatetimeEvent(UpdatetimeEvent{
-			Time:   currentTime,
-			Reason: reason,
-		}, true)
-	}
-
 	for range ticker.C {
-		currentTime := time.Now()
 		serviceStates.mu.Lock()
 		for host, state := range serviceStates.states {
 			updatedState := state
@@ -108,18 +224,15 @@ func MonitorHostChanges(timeout time.Duration) {
 			if !updatedState.IsPreviousIsUpSet {
 				updatedState.IsPreviousIsUpSet = true
 				updatedState.PreviousIsUp = updatedState.IsUp
-				reason := fmt.Sprintf("Initialized Host %s status and set it to %t", host, updatedState.IsUp)
-				logAndUpdate(reason, currentTime)
+				slog.Debug("Initialized monitor state", "monitor", host, "up", updatedState.IsUp)
 				serviceStates.states[host] = updatedState
 			} else if updatedState.IsUp != updatedState.PreviousIsUp {
 				updatedState.PreviousIsUp = updatedState.IsUp
-				reason := fmt.Sprintf("Updating Host %s status and changing it to %t", host, updatedState.IsUp)
-				logAndUpdate(reason, currentTime)
+				slog.Debug("Updated monitor state", "monitor", host, "up", updatedState.IsUp)
 				serviceStates.states[host] = updatedState
 			} else {
 				if !slices.Contains(hosts, host) {
-					reason := fmt.Sprintf("Added Host %s to monitored hosts-list with an initialization-state of %t", host, updatedState.IsUp)
-					logAndUpdate(reason, currentTime)
+					slog.Debug("Added monitor to the hosts list", "monitor", host, "up", updatedState.IsUp)
 					hosts = append(hosts, host)
 					serviceStates.states[host] = updatedState
 				}
@@ -143,16 +256,17 @@ func HandleUp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// An ad-hoc probe: the host isn't scheduled and its result isn't
+	// recorded, monitors are declared in the monitors file, as Monitor
+	// resources or discovered.
 	hostWithPort := ensurePort(host)
 	path := r.URL.Query().Get("path")
-	metrics, err := utils.HostMetrics(hostWithPort, path)
+	metrics, err := utils.HostMetricsContext(r.Context(), hostWithPort, path, scheduler.DefaultTimeout)
 	if err != nil {
 		utils.HttpError(w, "Failed to metrics info: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	serviceStates.UpdateServiceState(host, metrics.Reachable)
-
 	responseData := map[string]interface{}{
 		"reachable":         metrics.Reachable,
 		"dnsResolutionTime": metrics.DnsResolutionTime.String(),
@@ -174,7 +288,7 @@ func HandleCertInfo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	hostWithPort := ensurePort(host)
-	metrics, err := utils.HostMetrics(hostWithPort, "")
+	metrics, err := utils.HostMetricsContext(r.Context(), hostWithPort, "", scheduler.DefaultTimeout)
 
 	if err != nil {
 		utils.HttpError(w, "Failed to fetch metrics info: "+err.Error(), http.StatusInternalServerError)
@@ -186,7 +300,7 @@ func HandleCertInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	certInfo, err := utils.FetchCertInfo(hostWithPort)
+	certInfo, err := utils.FetchCertInfoContext(r.Context(), hostWithPort)
 	if err != nil {
 		utils.HttpError(w, "Failed to fetch cert info: "+err.Error(), http.StatusInternalServerError)
 		return
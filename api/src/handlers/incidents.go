@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"iammati/statuspage/db"
+	"iammati/statuspage/incidents"
+	"iammati/statuspage/utils"
+)
+
+// Incidents opens incidents from state transitions and backs the incident API.
+var Incidents *incidents.Manager
+
+type createIncidentRequest struct {
+	Title    string   `json:"title"`
+	Severity string   `json:"severity"`
+	Monitors []string `json:"monitors"`
+	Message  string   `json:"message"`
+	Author   string   `json:"author"`
+}
+
+type incidentUpdateRequest struct {
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+	Author   string `json:"author"`
+}
+
+type postmortemRequest struct {
+	Postmortem string `json:"postmortem"`
+}
+
+// HandleMonitorTransition handles the incidents of a transition and
+// notifies about it along with the incidents it belongs to.
+func HandleMonitorTransition(transition Transition) {
+	NotifyTransition(transition, HandleIncidentTransition(transition)...)
+}
+
+// HandleIncidentTransition opens and resolves incidents as monitors go down
+// and up, and returns the incidents the transition belongs to. Flapping
+// monitors keep their incidents as they are until they settle, and monitors
+// under maintenance don't open any.
+func HandleIncidentTransition(transition Transition) []incidents.Incident {
+	if Incidents == nil {
+		return nil
+	}
+	if transition.Flapping {
+		active, err := Incidents.List(db.IncidentFilter{Monitor: transition.Monitor, Active: true})
+		if err != nil {
+			slog.Error("Failed to look up incidents", "monitor", transition.Monitor, "error", err)
+		}
+		return active
+	}
+	if !transition.IsUp && underMaintenance(transition.Monitor, transition.At) {
+		slog.Info("Not opening an incident, monitor is under maintenance", "monitor", transition.Monitor)
+		return nil
+	}
+	return Incidents.HandleTransition(transition.Monitor, transition.IsUp, transition.At)
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, target interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(target); err != nil {
+		utils.HttpError(w, "Invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func incidentID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		utils.HttpError(w, "Invalid incident id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func incidentResponse(w http.ResponseWriter, incident incidents.Incident, err error) {
+	switch {
+	case errors.Is(err, incidents.ErrNotFound):
+		utils.HttpError(w, "Incident not found", http.StatusNotFound)
+	case errors.Is(err, incidents.ErrInvalidTransition):
+		utils.HttpError(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, incidents.ErrInvalidSeverity):
+		utils.HttpError(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		utils.HttpError(w, "Failed to process incident: "+err.Error(), http.StatusInternalServerError)
+	default:
+		utils.JsonResponse(w, incident)
+	}
+}
+
+// HandleListIncidents lists incidents, optionally filtered by `status`,
+// `monitor`, `active` and `limit`.
+func HandleListIncidents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := db.IncidentFilter{
+		Status:  query.Get("status"),
+		Monitor: query.Get("monitor"),
+		Active:  query.Get("active") == "true",
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			utils.HttpError(w, "Invalid 'limit' parameter", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	list, err := Incidents.List(filter)
+	if err != nil {
+		utils.HttpError(w, "Failed to fetch incidents: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.JsonResponse(w, map[string]interface{}{"incidents": list})
+}
+
+func HandleCreateIncident(w http.ResponseWriter, r *http.Request) {
+	var request createIncidentRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if request.Title == "" {
+		utils.HttpError(w, "Field 'title' is required", http.StatusBadRequest)
+		return
+	}
+
+	incident, err := Incidents.Create(request.Title, request.Severity, request.Monitors, request.Message, request.Author)
+	if err == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+	}
+	incidentResponse(w, incident, err)
+}
+
+func HandleGetIncident(w http.ResponseWriter, r *http.Request) {
+	id, ok := incidentID(w, r)
+	if !ok {
+		return
+	}
+	incident, err := Incidents.Get(id)
+	incidentResponse(w, incident, err)
+}
+
+func HandleAddIncidentUpdate(w http.ResponseWriter, r *http.Request) {
+	id, ok := incidentID(w, r)
+	if !ok {
+		return
+	}
+	var request incidentUpdateRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if request.Message == "" {
+		utils.HttpError(w, "Field 'message' is required", http.StatusBadRequest)
+		return
+	}
+
+	incident, err := Incidents.AddUpdate(id, request.Message, request.Severity, request.Author)
+	incidentResponse(w, incident, err)
+}
+
+func HandleAcknowledgeIncident(w http.ResponseWriter, r *http.Request) {
+	id, ok := incidentID(w, r)
+	if !ok {
+		return
+	}
+	var request incidentUpdateRequest
+	if r.ContentLength != 0 && !decodeJSON(w, r, &request) {
+		return
+	}
+
+	incident, err := Incidents.Acknowledge(id, request.Author)
+	incidentResponse(w, incident, err)
+}
+
+func HandleResolveIncident(w http.ResponseWriter, r *http.Request) {
+	id, ok := incidentID(w, r)
+	if !ok {
+		return
+	}
+	var request incidentUpdateRequest
+	if r.ContentLength != 0 && !decodeJSON(w, r, &request) {
+		return
+	}
+
+	incident, err := Incidents.Resolve(id, request.Message, request.Author)
+	incidentResponse(w, incident, err)
+}
+
+func HandleIncidentPostmortem(w http.ResponseWriter, r *http.Request) {
+	id, ok := incidentID(w, r)
+	if !ok {
+		return
+	}
+	var request postmortemRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	incident, err := Incidents.SetPostmortem(id, request.Postmortem)
+	incidentResponse(w, incident, err)
+}
@@ -3,16 +3,22 @@ package k8s
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"iammati/statuspage/config"
+	"iammati/statuspage/tracing"
+	"log/slog"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func ListNamespaces() string {
-	namespaces, err := fetchNamespaces()
+// errUnavailable is returned when there's no cluster to connect to.
+var errUnavailable = errors.New("Kubernetes is not available")
+
+func ListNamespaces(ctx context.Context) string {
+	namespaces, err := fetchNamespaces(ctx)
 	if err != nil {
-		fmt.Printf("Error fetching namespaces: %v\n", err)
+		slog.Error("Failed to fetch namespaces", "error", err)
 		// Return an error response as a JSON string
 		errorResponse := map[string]string{"error": "Failed to fetch namespaces"}
 		errorJSON, _ := json.Marshal(errorResponse) // Ignoring error since it's simple JSON
@@ -27,7 +33,7 @@ func ListNamespaces() string {
 	// Convert the response data to JSON
 	responseJSON, err := json.Marshal(responseData)
 	if err != nil {
-		fmt.Printf("Error marshalling namespaces response: %v\n", err)
+		slog.Error("Failed to marshal namespaces response", "error", err)
 		// Return an error response as a JSON string
 		errorResponse := map[string]string{"error": "Failed to generate response"}
 		errorJSON, _ := json.Marshal(errorResponse) // Ignoring error since it's simple JSON
@@ -37,9 +43,16 @@ func ListNamespaces() string {
 	return string(responseJSON)
 }
 
-func fetchNamespaces() (string, error) {
-	namespaces, err := config.Clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+func fetchNamespaces(ctx context.Context) (string, error) {
+	ctx, span := tracer.Start(ctx, "k8s.namespaces.list")
+	defer span.End()
+
+	if config.Clientset == nil {
+		return "", errUnavailable
+	}
+	namespaces, err := config.Clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
 	if err != nil {
+		tracing.RecordError(span, err)
 		return "", fmt.Errorf("failed to fetch namespaces: %v", err)
 	}
 
@@ -5,22 +5,26 @@ import (
 	"encoding/json"
 	"fmt"
 	"iammati/statuspage/config"
+	"iammati/statuspage/tracing"
+	"log/slog"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func ListPods(namespace string) string {
+func ListPods(ctx context.Context, namespace string) string {
 	if namespace == "" {
-		fmt.Println("Namespace is required")
+		slog.Warn("Listing pods requires a namespace")
 		// Return an error response as a JSON string
 		errorResponse := map[string]string{"error": "Namespace is mandatory"}
 		errorJSON, _ := json.Marshal(errorResponse) // Ignoring error since it's simple JSON
 		return string(errorJSON)
 	}
 
-	pods, err := fetchPods(namespace)
+	pods, err := fetchPods(ctx, namespace)
 	if err != nil {
-		fmt.Printf("Error fetching pods in namespace '%s': %v\n", namespace, err)
+		slog.Error("Failed to fetch pods", "namespace", namespace, "error", err)
 		// Return an error response as a JSON string
 		errorResponse := map[string]string{"error": fmt.Sprintf("Failed to fetch pods in namespace '%s'", namespace)}
 		errorJSON, _ := json.Marshal(errorResponse) // Ignoring error since it's simple JSON
@@ -36,7 +40,7 @@ func ListPods(namespace string) string {
 	// Convert the response data to JSON
 	responseJSON, err := json.Marshal(responseData)
 	if err != nil {
-		fmt.Printf("Error marshalling pods response: %v\n", err)
+		slog.Error("Failed to marshal pods response", "namespace", namespace, "error", err)
 		// Return an error response as a JSON string
 		errorResponse := map[string]string{"error": "Failed to generate response"}
 		errorJSON, _ := json.Marshal(errorResponse) // Ignoring error since it's simple JSON
@@ -46,9 +50,16 @@ func ListPods(namespace string) string {
 	return string(responseJSON)
 }
 
-func fetchPods(namespace string) (string, error) {
-	pods, err := config.Clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+func fetchPods(ctx context.Context, namespace string) (string, error) {
+	ctx, span := tracer.Start(ctx, "k8s.pods.list", trace.WithAttributes(attribute.String("k8s.namespace.name", namespace)))
+	defer span.End()
+
+	if config.Clientset == nil {
+		return "", errUnavailable
+	}
+	pods, err := config.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
+		tracing.RecordError(span, err)
 		return "", fmt.Errorf("failed to fetch pods in namespace '%s': %v", namespace, err)
 	}
 
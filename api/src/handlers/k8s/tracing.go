@@ -0,0 +1,5 @@
+package k8s
+
+import "go.opentelemetry.io/otel"
+
+var tracer = otel.Tracer("iammati/statuspage/handlers/k8s")
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/db"
+	"iammati/statuspage/utils"
+)
+
+const (
+	defaultLogLimit = 100
+	maxLogLimit     = 1000
+	// logExportBatch is how many entries an export fetches at a time.
+	logExportBatch = 500
+)
+
+// LogResponse is a log entry as served by the API and the live tail.
+type LogResponse struct {
+	ID         int64             `json:"id"`
+	Timestamp  string            `json:"timestamp"`
+	Level      string            `json:"level"`
+	Message    string            `json:"message"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+func LogView(entry db.LogEntry) LogResponse {
+	attributes := entry.Attributes
+	if attributes == nil {
+		attributes = map[string]string{}
+	}
+	return LogResponse{
+		ID:         entry.ID,
+		Timestamp:  entry.Timestamp,
+		Level:      entry.Level,
+		Message:    entry.Message,
+		Attributes: attributes,
+	}
+}
+
+// ParseLogFilter reads a log filter from query parameters: a comma-separated
+// `level` list, an RFC3339 `from` and `to`, a `q` search term, a `monitor`,
+// the `cursor` of a previous page and a `limit`.
+func ParseLogFilter(query url.Values) (db.LogFilter, error) {
+	filter := db.LogFilter{
+		Search:  query.Get("q"),
+		Monitor: query.Get("monitor"),
+		Limit:   defaultLogLimit,
+	}
+	if value := query.Get("level"); value != "" {
+		for _, level := range strings.Split(value, ",") {
+			if _, err := config.ParseLogLevel(strings.TrimSpace(level)); err != nil {
+				return filter, fmt.Errorf("Invalid 'level' parameter: %v", err)
+			}
+			filter.Levels = append(filter.Levels, strings.ToUpper(strings.TrimSpace(level)))
+		}
+	}
+
+	var err error
+	if value := query.Get("from"); value != "" {
+		if filter.From, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, errors.New("Invalid 'from' parameter, expected RFC3339")
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if filter.To, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, errors.New("Invalid 'to' parameter, expected RFC3339")
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, errors.New("'from' must be before 'to'")
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		if filter.Before, err = strconv.ParseInt(value, 10, 64); err != nil || filter.Before < 1 {
+			return filter, errors.New("Invalid 'cursor' parameter")
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil || filter.Limit < 1 || filter.Limit > maxLogLimit {
+			return filter, fmt.Errorf("Invalid 'limit' parameter, expected 1 to %d", maxLogLimit)
+		}
+	}
+	return filter, nil
+}
+
+// HandleListLogs serves a page of log entries, newest first, along with the
+// cursor of the next page. With `format=ndjson` or `format=csv` every
+// matching entry is exported instead, up to `limit` if given.
+func HandleListLogs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter, err := ParseLogFilter(query)
+	if err != nil {
+		utils.HttpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch format := query.Get("format"); format {
+	case "", "json":
+	case "ndjson", "csv":
+		if query.Get("limit") == "" {
+			filter.Limit = 0
+		}
+		exportLogs(w, filter, format)
+		return
+	default:
+		utils.HttpError(w, "Invalid 'format' parameter, expected json, ndjson or csv", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := config.Store.LogEntries(filter)
+	if err != nil {
+		utils.HttpError(w, "Failed to fetch logs: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := make([]LogResponse, 0, len(entries))
+	for _, entry := range entries {
+		response = append(response, LogView(entry))
+	}
+	var nextCursor *string
+	if len(entries) == filter.Limit {
+		cursor := strconv.FormatInt(entries[len(entries)-1].ID, 10)
+		nextCursor = &cursor
+	}
+	utils.JsonResponse(w, map[string]interface{}{"logs": response, "nextCursor": nextCursor})
+}
+
+// exportLogs streams the matching entries page by page. A limit of 0
+// exports all of them.
+func exportLogs(w http.ResponseWriter, filter db.LogFilter, format string) {
+	total := filter.Limit
+	batch := filter
+	batch.Limit = logExportBatch
+	if total > 0 && total < batch.Limit {
+		batch.Limit = total
+	}
+	entries, err := config.Store.LogEntries(batch)
+	if err != nil {
+		utils.HttpError(w, "Failed to fetch logs: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var write func(LogResponse) error
+	var flush func() error
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="logs.csv"`)
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"id", "timestamp", "level", "message", "attributes"})
+		write = func(entry LogResponse) error {
+			attributes, _ := json.Marshal(entry.Attributes)
+			return writer.Write([]string{strconv.FormatInt(entry.ID, 10), entry.Timestamp, entry.Level, entry.Message, string(attributes)})
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="logs.ndjson"`)
+		encoder := json.NewEncoder(w)
+		write = func(entry LogResponse) error { return encoder.Encode(entry) }
+		flush = func() error { return nil }
+	}
+	controller := http.NewResponseController(w)
+
+	exported := 0
+	for {
+		for _, entry := range entries {
+			if err := write(LogView(entry)); err != nil {
+				slog.Debug("Stopped exporting logs", "error", err)
+				return
+			}
+		}
+		exported += len(entries)
+		if err := flush(); err != nil {
+			slog.Debug("Stopped exporting logs", "error", err)
+			return
+		}
+		controller.Flush()
+
+		if len(entries) < batch.Limit || (total > 0 && exported >= total) {
+			return
+		}
+		batch.Before = entries[len(entries)-1].ID
+		if total > 0 {
+			batch.Limit = min(logExportBatch, total-exported)
+		}
+		if entries, err = config.Store.LogEntries(batch); err != nil {
+			slog.Error("Failed to export logs", "error", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"iammati/statuspage/db"
+	"iammati/statuspage/maintenance"
+	"iammati/statuspage/utils"
+)
+
+// upcomingMaintenance is how far ahead the status page lists maintenance.
+const upcomingMaintenance = 7 * 24 * time.Hour
+
+// Maintenance holds the maintenance windows; monitors covered by an active
+// window neither open incidents nor count towards downtime.
+var Maintenance *maintenance.Manager
+
+type maintenanceWindowRequest struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Monitors    []string  `json:"monitors"`
+	Components  []string  `json:"components"`
+	StartsAt    time.Time `json:"startsAt"`
+	EndsAt      time.Time `json:"endsAt"`
+	Schedule    string    `json:"schedule"`
+	Duration    string    `json:"duration"`
+}
+
+type maintenanceOccurrenceResponse struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type maintenanceWindowResponse struct {
+	ID          int64                           `json:"id"`
+	Title       string                          `json:"title"`
+	Description string                          `json:"description,omitempty"`
+	Monitors    []string                        `json:"monitors"`
+	Components  []string                        `json:"components"`
+	StartsAt    time.Time                       `json:"startsAt"`
+	EndsAt      *time.Time                      `json:"endsAt,omitempty"`
+	Schedule    string                          `json:"schedule,omitempty"`
+	Duration    string                          `json:"duration,omitempty"`
+	Active      bool                            `json:"active"`
+	Upcoming    []maintenanceOccurrenceResponse `json:"upcoming"`
+}
+
+func maintenanceWindowView(window db.MaintenanceWindow, now time.Time) maintenanceWindowResponse {
+	view := maintenanceWindowResponse{
+		ID:          window.ID,
+		Title:       window.Title,
+		Description: window.Description,
+		Monitors:    window.Monitors,
+		Components:  window.Components,
+		StartsAt:    window.StartsAt,
+		Schedule:    window.Schedule,
+		Upcoming:    []maintenanceOccurrenceResponse{},
+	}
+	if view.Monitors == nil {
+		view.Monitors = []string{}
+	}
+	if view.Components == nil {
+		view.Components = []string{}
+	}
+	if !window.EndsAt.IsZero() {
+		view.EndsAt = &window.EndsAt
+	}
+	if window.Duration > 0 {
+		view.Duration = window.Duration.String()
+	}
+
+	occurrences, _ := maintenance.Occurrences(window, now, now.Add(upcomingMaintenance))
+	for _, occurrence := range occurrences {
+		if occurrence.Contains(now) {
+			view.Active = true
+		}
+		view.Upcoming = append(view.Upcoming, maintenanceOccurrenceResponse{Start: occurrence.Start, End: occurrence.End})
+	}
+	return view
+}
+
+func maintenanceWindowID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		utils.HttpError(w, "Invalid maintenance window id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func decodeMaintenanceWindow(w http.ResponseWriter, r *http.Request) (db.MaintenanceWindow, bool) {
+	var request maintenanceWindowRequest
+	if !decodeJSON(w, r, &request) {
+		return db.MaintenanceWindow{}, false
+	}
+
+	window := db.MaintenanceWindow{
+		Title:       request.Title,
+		Description: request.Description,
+		Monitors:    request.Monitors,
+		Components:  request.Components,
+		StartsAt:    request.StartsAt,
+		EndsAt:      request.EndsAt,
+		Schedule:    request.Schedule,
+	}
+	if request.Duration != "" {
+		duration, err := time.ParseDuration(request.Duration)
+		if err != nil {
+			utils.HttpError(w, "Invalid 'duration', expected a duration like 2h", http.StatusBadRequest)
+			return db.MaintenanceWindow{}, false
+		}
+		window.Duration = duration
+	}
+	return window, true
+}
+
+func maintenanceResponse(w http.ResponseWriter, window db.MaintenanceWindow, err error) {
+	switch {
+	case errors.Is(err, maintenance.ErrNotFound):
+		utils.HttpError(w, "Maintenance window not found", http.StatusNotFound)
+	case errors.Is(err, maintenance.ErrInvalidWindow):
+		utils.HttpError(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		utils.HttpError(w, "Failed to process maintenance window: "+err.Error(), http.StatusInternalServerError)
+	default:
+		utils.JsonResponse(w, maintenanceWindowView(window, time.Now()))
+	}
+}
+
+// HandleListMaintenance lists every maintenance window with its occurrences
+// within the next week.
+func HandleListMaintenance(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	windows := Maintenance.List()
+
+	response := make([]maintenanceWindowResponse, 0, len(windows))
+	for _, window := range windows {
+		response = append(response, maintenanceWindowView(window, now))
+	}
+	utils.JsonResponse(w, map[string]interface{}{"maintenance": response})
+}
+
+func HandleCreateMaintenance(w http.ResponseWriter, r *http.Request) {
+	window, ok := decodeMaintenanceWindow(w, r)
+	if !ok {
+		return
+	}
+
+	window, err := Maintenance.Create(window)
+	if err == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+	}
+	maintenanceResponse(w, window, err)
+}
+
+func HandleGetMaintenance(w http.ResponseWriter, r *http.Request) {
+	id, ok := maintenanceWindowID(w, r)
+	if !ok {
+		return
+	}
+	window, err := Maintenance.Get(id)
+	maintenanceResponse(w, window, err)
+}
+
+func HandleUpdateMaintenance(w http.ResponseWriter, r *http.Request) {
+	id, ok := maintenanceWindowID(w, r)
+	if !ok {
+		return
+	}
+	window, ok := decodeMaintenanceWindow(w, r)
+	if !ok {
+		return
+	}
+
+	window.ID = id
+	window, err := Maintenance.Update(window)
+	maintenanceResponse(w, window, err)
+}
+
+func HandleDeleteMaintenance(w http.ResponseWriter, r *http.Request) {
+	id, ok := maintenanceWindowID(w, r)
+	if !ok {
+		return
+	}
+	if err := Maintenance.Delete(id); err != nil {
+		maintenanceResponse(w, db.MaintenanceWindow{}, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// underMaintenance reports whether a monitor is covered by an active window.
+func underMaintenance(monitor string, at time.Time) bool {
+	return Maintenance != nil && Maintenance.UnderMaintenance(monitor, at)
+}
+
+// suppressed holds the last transition of each monitor that happened under
+// maintenance, and so neither opened an incident nor notified anyone.
+var suppressed = struct {
+	mu          sync.Mutex
+	transitions map[string]Transition
+}{transitions: make(map[string]Transition)}
+
+// TrackMaintenanceTransition remembers the transitions maintenance
+// suppresses, so ReplayMaintenanceTransitions can catch up on monitors still
+// down once their window ends. It must be registered before the listeners
+// that open incidents and notify.
+func TrackMaintenanceTransition(transition Transition) {
+	suppressed.mu.Lock()
+	defer suppressed.mu.Unlock()
+
+	if underMaintenance(transition.Monitor, transition.At) {
+		suppressed.transitions[transition.Monitor] = transition
+	} else {
+		delete(suppressed.transitions, transition.Monitor)
+	}
+}
+
+// ReplayMaintenanceTransitions checks every interval for monitors whose
+// maintenance ended while they were down, and opens incidents and notifies
+// for them as if they had just gone down.
+func ReplayMaintenanceTransitions(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			replayMaintenanceTransitions(time.Now())
+		case <-stop:
+			return
+		}
+	}
+}
+
+func replayMaintenanceTransitions(now time.Time) {
+	states := serviceStates.Snapshot()
+
+	suppressed.mu.Lock()
+	var replayed []Transition
+	for monitor, transition := range suppressed.transitions {
+		if underMaintenance(monitor, now) {
+			continue
+		}
+		delete(suppressed.transitions, monitor)
+		if state, ok := states[monitor]; ok && !state.Up && !transition.IsUp {
+			transition.At = now
+			transition.Initial = false
+			transition.Flapping = state.Flapping
+			replayed = append(replayed, transition)
+		}
+	}
+	suppressed.mu.Unlock()
+
+	for _, transition := range replayed {
+		slog.Info("Maintenance ended while monitor is down", "monitor", transition.Monitor)
+		HandleMonitorTransition(transition)
+	}
+}
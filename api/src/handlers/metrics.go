@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"iammati/statuspage/scheduler"
+	"iammati/statuspage/utils"
+)
+
+var (
+	probeDNSResolution = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "statuspage_probe_dns_resolution_seconds",
+		Help:    "Time it took to resolve the host of a monitor.",
+		Buckets: probeBuckets,
+	}, []string{"monitor"})
+	probeTCPConnection = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "statuspage_probe_tcp_connection_seconds",
+		Help:    "Time it took to open a TCP connection to a monitor.",
+		Buckets: probeBuckets,
+	}, []string{"monitor"})
+	probeTLSConnection = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "statuspage_probe_tls_connection_seconds",
+		Help:    "Time the TLS handshake with a monitor took.",
+		Buckets: probeBuckets,
+	}, []string{"monitor"})
+	probeHTTP = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "statuspage_probe_http_seconds",
+		Help:    "Time the HTTP request to a monitor took.",
+		Buckets: probeBuckets,
+	}, []string{"monitor"})
+	probeStatusCode = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "statuspage_probe_status_code",
+		Help: "HTTP status code of the latest probe, 0 if no response was received.",
+	}, []string{"monitor"})
+	probesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "statuspage_probes_total",
+		Help: "Number of probes by result (up or down).",
+	}, []string{"monitor", "result"})
+)
+
+// probeBuckets range from 5ms to 10s.
+var probeBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// observeProbe records the timings of a probe. Phases that weren't reached
+// because an earlier one failed aren't observed.
+func observeProbe(monitor string, up bool, probe utils.Metrics) {
+	for _, phase := range []struct {
+		histogram *prometheus.HistogramVec
+		duration  time.Duration
+	}{
+		{probeDNSResolution, probe.DnsResolutionTime},
+		{probeTCPConnection, probe.TcpConnectionTime},
+		{probeTLSConnection, probe.TlsConnectionTime},
+		{probeHTTP, probe.HttpTime},
+	} {
+		if phase.duration > 0 {
+			phase.histogram.WithLabelValues(monitor).Observe(phase.duration.Seconds())
+		}
+	}
+	probeStatusCode.WithLabelValues(monitor).Set(float64(probe.StatusCode))
+
+	result := "down"
+	if up {
+		result = "up"
+	}
+	probesTotal.WithLabelValues(monitor, result).Inc()
+}
+
+// forgetProbeMetrics drops the series of a monitor that is no longer probed.
+func forgetProbeMetrics(monitor string) {
+	for _, histogram := range []*prometheus.HistogramVec{probeDNSResolution, probeTCPConnection, probeTLSConnection, probeHTTP} {
+		histogram.DeleteLabelValues(monitor)
+	}
+	probeStatusCode.DeleteLabelValues(monitor)
+	probesTotal.DeletePartialMatch(prometheus.Labels{"monitor": monitor})
+	certificates.forget(monitor)
+}
+
+// certificateExpiries caches when the leaf certificate of each monitor
+// expires, so scrapes don't open TLS connections.
+type certificateExpiries struct {
+	mu        sync.Mutex
+	expiry    map[string]time.Time
+	checkedAt map[string]time.Time
+}
+
+var certificates = certificateExpiries{expiry: make(map[string]time.Time), checkedAt: make(map[string]time.Time)}
+
+func (c *certificateExpiries) due(monitor string, interval time.Duration, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	checkedAt, ok := c.checkedAt[monitor]
+	return !ok || now.Sub(checkedAt) >= interval
+}
+
+// set records a check of a monitor's certificate. A failed check keeps the
+// last known expiry, which still tells when the certificate expires.
+func (c *certificateExpiries) set(monitor string, expiry time.Time, ok bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checkedAt[monitor] = now
+	if ok {
+		c.expiry[monitor] = expiry
+	}
+}
+
+func (c *certificateExpiries) forget(monitor string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.expiry, monitor)
+	delete(c.checkedAt, monitor)
+}
+
+// CheckCertificate fetches the certificate of a monitor's host, giving up
+// after timeout, and caches when it expires.
+func CheckCertificate(monitor string, host string, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	now := time.Now()
+	expiry, err := utils.CertificateExpiryContext(ctx, ensurePort(host))
+	if err != nil {
+		slog.Warn("Failed to fetch the certificate", "monitor", monitor, "host", host, "error", err)
+	}
+	certificates.set(monitor, expiry, err == nil, now)
+}
+
+// servesTLS reports whether a monitor's host is reached over TLS, i.e. it
+// isn't an HTTP monitor of port 80.
+func servesTLS(monitor scheduler.Monitor) bool {
+	if monitor.Type == scheduler.TypeCertificate {
+		return true
+	}
+	_, port, err := net.SplitHostPort(monitor.Host)
+	return err != nil || port != "80"
+}
+
+// MonitorCertificates checks the certificate of every scheduled monitor
+// served over TLS once per interval. New monitors are picked up within a
+// minute.
+func MonitorCertificates(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if Scheduler != nil {
+			for _, monitor := range Scheduler.Monitors() {
+				if servesTLS(monitor) && certificates.due(monitor.Key(), interval, time.Now()) {
+					CheckCertificate(monitor.Key(), monitor.Host, monitor.WithDefaults().Timeout)
+				}
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+var (
+	monitorUpDesc = prometheus.NewDesc("statuspage_monitor_up",
+		"Whether a monitor is up (1) or down (0).", []string{"monitor"}, nil)
+	monitorFlappingDesc = prometheus.NewDesc("statuspage_monitor_flapping",
+		"Whether a monitor is flapping.", []string{"monitor"}, nil)
+	monitorInfoDesc = prometheus.NewDesc("statuspage_monitor_info",
+		"Host and group of a scheduled monitor.", []string{"monitor", "host", "group"}, nil)
+	certificateExpiryDesc = prometheus.NewDesc("statuspage_certificate_expiry_seconds",
+		"Seconds until the certificate of a monitor expires, negative once it has.", []string{"monitor"}, nil)
+)
+
+// stateCollector reads the monitor states and certificate expiries at
+// scrape time.
+type stateCollector struct{}
+
+func (stateCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- monitorUpDesc
+	descs <- monitorFlappingDesc
+	descs <- monitorInfoDesc
+	descs <- certificateExpiryDesc
+}
+
+func (stateCollector) Collect(collected chan<- prometheus.Metric) {
+	for monitor, state := range serviceStates.Snapshot() {
+		collected <- prometheus.MustNewConstMetric(monitorUpDesc, prometheus.GaugeValue, boolValue(state.Up), monitor)
+		collected <- prometheus.MustNewConstMetric(monitorFlappingDesc, prometheus.GaugeValue, boolValue(state.Flapping), monitor)
+	}
+	if Scheduler != nil {
+		for _, monitor := range Scheduler.Monitors() {
+			collected <- prometheus.MustNewConstMetric(monitorInfoDesc, prometheus.GaugeValue, 1, monitor.Key(), monitor.Host, monitor.Group)
+		}
+	}
+
+	certificates.mu.Lock()
+	defer certificates.mu.Unlock()
+	for monitor, expiry := range certificates.expiry {
+		collected <- prometheus.MustNewConstMetric(certificateExpiryDesc, prometheus.GaugeValue, time.Until(expiry).Seconds(), monitor)
+	}
+}
+
+// RegisterMetrics adds the probe timings, the monitor states and the
+// certificate expiries to a registry.
+func RegisterMetrics(registerer prometheus.Registerer) {
+	registerer.MustRegister(probeDNSResolution, probeTCPConnection, probeTLSConnection, probeHTTP, probeStatusCode, probesTotal, stateCollector{})
+}
+
+func boolValue(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
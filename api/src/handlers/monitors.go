@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/db"
+	"iammati/statuspage/scheduler"
+)
+
+// Sources monitors are declared by, in order of precedence: a key declared
+// by several sources is scheduled with the definition of the first one.
+const (
+	SourceFile      = "file"
+	SourceResource  = "resource"
+	SourceDiscovery = "discovery"
+)
+
+var monitorSources = []string{SourceFile, SourceResource, SourceDiscovery}
+
+// declared holds the definition every source declares for a monitor key.
+var declared = struct {
+	mu       sync.Mutex
+	monitors map[string]map[string]scheduler.Monitor
+}{monitors: make(map[string]map[string]scheduler.Monitor)}
+
+// MonitorRegistry schedules the monitors of a source and keeps their
+// definitions in the store. A monitor declared by several sources stays
+// scheduled until the last of them removes it.
+type MonitorRegistry struct {
+	Source string
+}
+
+func (r MonitorRegistry) Upsert(monitor scheduler.Monitor) {
+	declared.mu.Lock()
+	defer declared.mu.Unlock()
+
+	key := monitor.Key()
+	if declared.monitors[key] == nil {
+		declared.monitors[key] = make(map[string]scheduler.Monitor)
+	}
+	declared.monitors[key][r.Source] = monitor
+	if source, _ := declaredMonitor(key); source == r.Source {
+		schedule(monitor)
+	}
+}
+
+func (r MonitorRegistry) Remove(key string) {
+	declared.mu.Lock()
+	defer declared.mu.Unlock()
+
+	previous, _ := declaredMonitor(key)
+	delete(declared.monitors[key], r.Source)
+	source, monitor := declaredMonitor(key)
+	switch {
+	case len(declared.monitors[key]) == 0:
+		delete(declared.monitors, key)
+		unschedule(key)
+	case source != previous:
+		slog.Info("Monitor still declared elsewhere", "monitor", key, "source", source)
+		schedule(monitor)
+	}
+}
+
+// declaredMonitor returns the source whose definition of key takes
+// precedence, and that definition.
+func declaredMonitor(key string) (string, scheduler.Monitor) {
+	rank := func(source string) int {
+		if i := slices.Index(monitorSources, source); i >= 0 {
+			return i
+		}
+		return len(monitorSources)
+	}
+
+	var first string
+	var monitor scheduler.Monitor
+	found := false
+	for source, definition := range declared.monitors[key] {
+		if !found || rank(source) < rank(first) {
+			first, monitor, found = source, definition, true
+		}
+	}
+	return first, monitor
+}
+
+func schedule(monitor scheduler.Monitor) {
+	monitor = monitor.WithDefaults()
+	previous := scheduledComponent(monitor.Key())
+	Scheduler.Upsert(monitor)
+
+	err := config.Store.UpsertMonitor(db.Monitor{
+		Name:             monitor.Key(),
+		Type:             monitor.Type,
+		Host:             monitor.Host,
+		Path:             monitor.Path,
+		Group:            monitor.Group,
+		Tags:             monitor.Tags,
+		Interval:         monitor.Interval,
+		Timeout:          monitor.Timeout,
+		ExpectedStatus:   monitor.ExpectedStatus,
+		MaxResponseTime:  monitor.MaxResponseTime,
+		SLOTarget:        monitor.SLOTarget,
+		FailureThreshold: monitor.FailureThreshold,
+		SuccessThreshold: monitor.SuccessThreshold,
+		Component:        monitor.Component,
+	})
+	if err != nil {
+		slog.Error("Failed to store monitor", "monitor", monitor.Key(), "error", err)
+	}
+	if monitor.Component != previous {
+		syncMonitorComponents()
+	}
+}
+
+func unschedule(key string) {
+	component := scheduledComponent(key)
+	Scheduler.Remove(key)
+	serviceStates.Forget(key)
+	forgetProbeMetrics(key)
+
+	if err := config.Store.DeleteMonitor(key); err != nil {
+		slog.Error("Failed to delete monitor", "monitor", key, "error", err)
+	}
+	if component != "" {
+		syncMonitorComponents()
+	}
+}
+
+// scheduledComponent returns the component a scheduled monitor joins.
+func scheduledComponent(key string) string {
+	for _, monitor := range Scheduler.Monitors() {
+		if monitor.Key() == key {
+			return monitor.Component
+		}
+	}
+	return ""
+}
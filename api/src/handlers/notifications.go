@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/db"
+	"iammati/statuspage/incidents"
+	"iammati/statuspage/notify"
+	"iammati/statuspage/scheduler"
+	"iammati/statuspage/utils"
+)
+
+// Notifier delivers notifications to the configured channels.
+var Notifier *notify.Dispatcher
+
+// Alerts routes state changes and incident events to the channels of
+// Notifier, escalating and deduplicating them.
+var Alerts *notify.Router
+
+type channelResponse struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`
+	Events []string `json:"events"`
+}
+
+type deliveryResponse struct {
+	ID           int64     `json:"id"`
+	Channel      string    `json:"channel"`
+	Event        string    `json:"event"`
+	Status       string    `json:"status"`
+	Attempts     int       `json:"attempts"`
+	ResponseCode int       `json:"responseCode,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+func deliveryView(delivery db.NotificationDelivery) deliveryResponse {
+	return deliveryResponse{
+		ID:           delivery.ID,
+		Channel:      delivery.Channel,
+		Event:        delivery.Event,
+		Status:       delivery.Status,
+		Attempts:     delivery.Attempts,
+		ResponseCode: delivery.ResponseCode,
+		Error:        delivery.LastError,
+		CreatedAt:    delivery.CreatedAt,
+		UpdatedAt:    delivery.UpdatedAt,
+	}
+}
+
+// NotifyTransition tells the notification channels about a monitor going
+// down, recovering or flapping, along with the incidents the transition
+// belongs to. Monitors starting out up and monitors under maintenance aren't
+// worth a notification.
+func NotifyTransition(transition Transition, related ...incidents.Incident) {
+	if Alerts == nil || (transition.Initial && transition.IsUp) {
+		return
+	}
+	if underMaintenance(transition.Monitor, transition.At) {
+		return
+	}
+
+	monitor := notify.Monitor{
+		Name:     transition.Monitor,
+		Up:       transition.IsUp,
+		Flapping: transition.Flapping,
+		ProbeIDs: transition.ProbeIDs,
+		Probe:    notify.ProbeFromMetrics(transition.Metrics),
+	}
+	if definition, ok := scheduledMonitor(transition.Monitor); ok {
+		monitor.Host = definition.Host
+		monitor.Group = definition.Group
+		monitor.Tags = definition.Tags
+	}
+	event := notify.Event{
+		Type:     notify.MonitorEventType(transition.IsUp, transition.Flapping),
+		At:       transition.At,
+		Monitor:  &monitor,
+		Severity: db.SeverityMajor,
+	}
+	if len(related) > 0 {
+		incident := related[0]
+		event.Severity = incident.Severity
+		event.URL = notify.IncidentURL(config.AppSettings.Notifications.IncidentURL, incident.ID)
+		event.IncidentID = incident.ID
+	}
+	Alerts.Handle(event)
+}
+
+// NotifyIncident is an incidents.Publisher forwarding incident events to
+// the notification channels.
+func NotifyIncident(event string, incident incidents.Incident) {
+	if Alerts == nil {
+		return
+	}
+	Alerts.Handle(notify.Event{
+		Type:     notify.IncidentEventType(event),
+		At:       time.Now(),
+		Incident: &incident,
+		URL:      notify.IncidentURL(config.AppSettings.Notifications.IncidentURL, incident.ID),
+	})
+}
+
+// MonitorLabels returns the group and tags of a scheduled monitor.
+func MonitorLabels(monitor string) (string, []string) {
+	definition, _ := scheduledMonitor(monitor)
+	return definition.Group, definition.Tags
+}
+
+func scheduledMonitor(key string) (scheduler.Monitor, bool) {
+	if Scheduler == nil {
+		return scheduler.Monitor{}, false
+	}
+	monitors := Scheduler.Monitors()
+	index := slices.IndexFunc(monitors, func(monitor scheduler.Monitor) bool {
+		return monitor.Key() == key
+	})
+	if index < 0 {
+		return scheduler.Monitor{}, false
+	}
+	return monitors[index], true
+}
+
+func HandleListChannels(w http.ResponseWriter, r *http.Request) {
+	response := []channelResponse{}
+	for _, channel := range Notifier.Channels() {
+		events := Notifier.Events(channel.Name())
+		if events == nil {
+			events = []string{}
+		}
+		response = append(response, channelResponse{Name: channel.Name(), Type: channel.Type(), Events: events})
+	}
+	utils.JsonResponse(w, map[string]interface{}{"channels": response})
+}
+
+// HandleTestChannel sends a test event to a channel right away, without
+// retries, and responds with the resulting delivery.
+func HandleTestChannel(w http.ResponseWriter, r *http.Request) {
+	channel, ok := Notifier.Channel(r.PathValue("channel"))
+	if !ok {
+		utils.HttpError(w, "Notification channel not found", http.StatusNotFound)
+		return
+	}
+
+	delivery, err := Notifier.Deliver(channel, notify.Event{
+		Type:    notify.EventTest,
+		At:      time.Now(),
+		Message: "Test notification from the status page",
+	}, 0)
+	if err != nil && delivery.Channel == "" {
+		utils.HttpError(w, "Failed to send test notification: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	utils.JsonResponse(w, deliveryView(delivery))
+}
+
+// HandleListDeliveries lists the delivery log, optionally filtered by
+// `channel`, `status` and `limit`.
+func HandleListDeliveries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := db.DeliveryFilter{
+		Channel: query.Get("channel"),
+		Status:  query.Get("status"),
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			utils.HttpError(w, "Invalid 'limit' parameter", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	deliveries, err := config.Store.NotificationDeliveries(filter)
+	if err != nil {
+		utils.HttpError(w, "Failed to fetch deliveries: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := make([]deliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		response = append(response, deliveryView(delivery))
+	}
+	utils.JsonResponse(w, map[string]interface{}{"deliveries": response})
+}
+
+type alertResponse struct {
+	ID             int64      `json:"id"`
+	Key            string     `json:"key"`
+	Route          string     `json:"route"`
+	Event          string     `json:"event"`
+	OpenedAt       time.Time  `json:"openedAt"`
+	AcknowledgedAt *time.Time `json:"acknowledgedAt,omitempty"`
+	NotifiedAt     time.Time  `json:"notifiedAt"`
+	Channels       []string   `json:"channels"`
+	Escalations    int        `json:"escalations"`
+	Repeats        int        `json:"repeats"`
+	Suppressed     int        `json:"suppressed"`
+}
+
+func alertView(alert notify.Alert) alertResponse {
+	view := alertResponse{
+		ID:          alert.ID,
+		Key:         alert.Key,
+		Route:       alert.Route,
+		Event:       alert.Event.Type,
+		OpenedAt:    alert.OpenedAt,
+		NotifiedAt:  alert.NotifiedAt,
+		Channels:    alert.Channels,
+		Escalations: alert.Escalations,
+		Repeats:     alert.Repeats,
+		Suppressed:  alert.Suppressed,
+	}
+	if view.Channels == nil {
+		view.Channels = []string{}
+	}
+	if alert.Acknowledged() {
+		view.AcknowledgedAt = &alert.AcknowledgedAt
+	}
+	return view
+}
+
+// HandleListAlerts lists the open alerts of the notification routes.
+func HandleListAlerts(w http.ResponseWriter, r *http.Request) {
+	alerts := Alerts.Alerts()
+	response := make([]alertResponse, 0, len(alerts))
+	for _, alert := range alerts {
+		response = append(response, alertView(alert))
+	}
+	utils.JsonResponse(w, map[string]interface{}{"alerts": response})
+}
+
+// HandleAcknowledgeAlert stops escalating and repeating an alert.
+func HandleAcknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		utils.HttpError(w, "Invalid alert id", http.StatusBadRequest)
+		return
+	}
+	alert, err := Alerts.Acknowledge(id)
+	if errors.Is(err, notify.ErrAlertNotFound) {
+		utils.HttpError(w, "Alert not found", http.StatusNotFound)
+		return
+	}
+	utils.JsonResponse(w, alertView(alert))
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/db"
+	"iammati/statuspage/utils"
+)
+
+const (
+	defaultProbeRange = 24 * time.Hour
+	defaultProbeLimit = 500
+	maxProbeBuckets   = 2000
+)
+
+type probeResultResponse struct {
+	StartedAt         time.Time `json:"startedAt"`
+	Up                bool      `json:"up"`
+	StatusCode        int       `json:"statusCode"`
+	DnsResolutionTime float64   `json:"dnsResolutionTime"`
+	TcpConnectionTime float64   `json:"tcpConnectionTime"`
+	TlsConnectionTime float64   `json:"tlsConnectionTime"`
+	HttpTime          float64   `json:"httpTime"`
+	Error             string    `json:"error,omitempty"`
+}
+
+type probeBucketResponse struct {
+	Start             time.Time `json:"start"`
+	Count             int       `json:"count"`
+	Availability      float64   `json:"availability"`
+	DnsResolutionTime float64   `json:"dnsResolutionTime"`
+	TcpConnectionTime float64   `json:"tcpConnectionTime"`
+	TlsConnectionTime float64   `json:"tlsConnectionTime"`
+	HttpTime          float64   `json:"httpTime"`
+}
+
+// storeProbeResult persists a HostMetrics run of the given monitor and
+// returns its ID, or 0 if it couldn't be stored.
+func storeProbeResult(monitor string, host string, startedAt time.Time, up bool, metrics utils.Metrics, probeErr error) int64 {
+	result := db.ProbeResult{
+		Monitor:           monitor,
+		Host:              host,
+		StartedAt:         startedAt,
+		Up:                up,
+		StatusCode:        metrics.StatusCode,
+		DnsResolutionTime: metrics.DnsResolutionTime,
+		TcpConnectionTime: metrics.TcpConnectionTime,
+		TlsConnectionTime: metrics.TlsConnectionTime,
+		HttpTime:          metrics.HttpTime,
+	}
+	if probeErr != nil {
+		result.Error = probeErr.Error()
+	} else if metrics.Error != nil {
+		result.Error = metrics.Error.Error()
+	}
+
+	id, err := config.Store.InsertProbeResult(result)
+	if err != nil {
+		slog.Error("Failed to store probe result", "monitor", monitor, "error", err)
+	}
+	return id
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// HandleProbeResults serves the stored probe results of a monitor. Passing
+// a `step` aggregates them into buckets for charting.
+func HandleProbeResults(w http.ResponseWriter, r *http.Request) {
+	monitor := r.PathValue("monitor")
+	query := r.URL.Query()
+
+	to := time.Now()
+	from := to.Add(-defaultProbeRange)
+	var err error
+	if value := query.Get("from"); value != "" {
+		if from, err = time.Parse(time.RFC3339, value); err != nil {
+			utils.HttpError(w, "Invalid 'from' parameter, expected RFC3339", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			utils.HttpError(w, "Invalid 'to' parameter, expected RFC3339", http.StatusBadRequest)
+			return
+		}
+	}
+	if !from.Before(to) {
+		utils.HttpError(w, "'from' must be before 'to'", http.StatusBadRequest)
+		return
+	}
+
+	if value := query.Get("step"); value != "" {
+		step, err := time.ParseDuration(value)
+		if err != nil || step < time.Second {
+			utils.HttpError(w, "Invalid 'step' parameter, expected a duration of at least 1s", http.StatusBadRequest)
+			return
+		}
+		if to.Sub(from)/step > maxProbeBuckets {
+			utils.HttpError(w, "Range too large for the given 'step'", http.StatusBadRequest)
+			return
+		}
+
+		buckets, err := config.Store.ProbeResultBuckets(monitor, from, to, step)
+		if err != nil {
+			utils.HttpError(w, "Failed to fetch probe results: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := make([]probeBucketResponse, 0, len(buckets))
+		for _, bucket := range buckets {
+			response = append(response, probeBucketResponse{
+				Start:             bucket.Start,
+				Count:             bucket.Count,
+				Availability:      float64(bucket.UpCount) / float64(bucket.Count),
+				DnsResolutionTime: milliseconds(bucket.DnsResolutionTime),
+				TcpConnectionTime: milliseconds(bucket.TcpConnectionTime),
+				TlsConnectionTime: milliseconds(bucket.TlsConnectionTime),
+				HttpTime:          milliseconds(bucket.HttpTime),
+			})
+		}
+
+		utils.JsonResponse(w, map[string]interface{}{
+			"monitor": monitor,
+			"from":    from,
+			"to":      to,
+			"step":    step.String(),
+			"buckets": response,
+		})
+		return
+	}
+
+	limit := defaultProbeLimit
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			utils.HttpError(w, "Invalid 'limit' parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := config.Store.ProbeResults(monitor, from, to, limit)
+	if err != nil {
+		utils.HttpError(w, "Failed to fetch probe results: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]probeResultResponse, 0, len(results))
+	for _, result := range results {
+		response = append(response, probeResultResponse{
+			StartedAt:         result.StartedAt,
+			Up:                result.Up,
+			StatusCode:        result.StatusCode,
+			DnsResolutionTime: milliseconds(result.DnsResolutionTime),
+			TcpConnectionTime: milliseconds(result.TcpConnectionTime),
+			TlsConnectionTime: milliseconds(result.TlsConnectionTime),
+			HttpTime:          milliseconds(result.HttpTime),
+			Error:             result.Error,
+		})
+	}
+
+	utils.JsonResponse(w, map[string]interface{}{
+		"monitor": monitor,
+		"from":    from,
+		"to":      to,
+		"results": response,
+	})
+}
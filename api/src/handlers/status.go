@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/db"
+	"iammati/statuspage/incidents"
+	"iammati/statuspage/monitors"
+	"iammati/statuspage/status"
+	"iammati/statuspage/utils"
+)
+
+type componentMonitorResponse struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+type componentResponse struct {
+	Name        string                     `json:"name"`
+	Description string                     `json:"description,omitempty"`
+	Group       string                     `json:"group,omitempty"`
+	Order       int                        `json:"order"`
+	Status      string                     `json:"status"`
+	Monitors    []componentMonitorResponse `json:"monitors"`
+}
+
+type componentGroupResponse struct {
+	Name       string   `json:"name"`
+	Status     string   `json:"status"`
+	Components []string `json:"components"`
+}
+
+type statusMaintenanceResponse struct {
+	ID          int64     `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Monitors    []string  `json:"monitors"`
+	Components  []string  `json:"components"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Active      bool      `json:"active"`
+}
+
+type statusResponse struct {
+	Status      string                      `json:"status"`
+	UpdatedAt   time.Time                   `json:"updatedAt"`
+	Components  []componentResponse         `json:"components"`
+	Groups      []componentGroupResponse    `json:"groups"`
+	Incidents   []incidents.Incident        `json:"incidents"`
+	Maintenance []statusMaintenanceResponse `json:"maintenance"`
+}
+
+// Snapshot returns the current state of every known monitor.
+func (ss *ServiceStates) Snapshot() map[string]status.MonitorState {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	states := make(map[string]status.MonitorState, len(ss.states))
+	for host, state := range ss.states {
+		states[host] = status.MonitorState{Up: state.IsUp, Flapping: state.Flapping}
+	}
+	return states
+}
+
+// MonitorStates returns the confirmed state of every probed monitor,
+// marking those covered by an active maintenance window.
+func MonitorStates() map[string]status.MonitorState {
+	states := serviceStates.Snapshot()
+	if Maintenance == nil {
+		return states
+	}
+	for _, monitor := range Maintenance.Active(time.Now()) {
+		if state, ok := states[monitor]; ok {
+			state.Maintenance = true
+			states[monitor] = state
+		}
+	}
+	return states
+}
+
+// components holds the components of the last loaded monitors file. Its
+// lock serializes syncing them with the monitors joining a component.
+var components struct {
+	mu       sync.Mutex
+	declared []db.Component
+	loaded   bool
+}
+
+// SyncComponents stores the components of a freshly loaded monitors file and
+// removes the ones that are no longer defined.
+func SyncComponents(file *monitors.File) {
+	components.mu.Lock()
+	defer components.mu.Unlock()
+
+	components.declared = make([]db.Component, 0, len(file.Components))
+	for _, component := range file.Components {
+		components.declared = append(components.declared, db.Component{
+			Name:        component.Name,
+			Description: component.Description,
+			Group:       component.Group,
+			Order:       component.Order,
+			Monitors:    component.Monitors,
+		})
+	}
+	components.loaded = true
+	syncComponents()
+}
+
+// syncMonitorComponents updates the components after a monitor joined or
+// left one.
+func syncMonitorComponents() {
+	components.mu.Lock()
+	defer components.mu.Unlock()
+
+	syncComponents()
+}
+
+// syncComponents stores the declared components along with the monitors that
+// join a component themselves, e.g. through a Kubernetes annotation. A
+// component only monitors name is created in the group of its first monitor.
+// Other components are removed, unless no monitors file was loaded yet.
+func syncComponents() {
+	existing, err := config.Store.Components()
+	if err != nil {
+		slog.Error("Failed to fetch components", "error", err)
+		return
+	}
+	definitions, err := config.Store.Monitors()
+	if err != nil {
+		slog.Error("Failed to fetch monitors", "error", err)
+		return
+	}
+
+	base := components.declared
+	if !components.loaded {
+		base = existing
+	}
+	desired := make([]db.Component, 0, len(base))
+	for _, component := range base {
+		component.Monitors = slices.Clone(component.Monitors)
+		desired = append(desired, component)
+	}
+	for _, monitor := range definitions {
+		if monitor.Component == "" {
+			continue
+		}
+		i := slices.IndexFunc(desired, func(component db.Component) bool { return component.Name == monitor.Component })
+		if i < 0 {
+			desired = append(desired, db.Component{Name: monitor.Component, Group: monitor.Group})
+			i = len(desired) - 1
+		}
+		if !slices.Contains(desired[i].Monitors, monitor.Name) {
+			desired[i].Monitors = append(desired[i].Monitors, monitor.Name)
+		}
+	}
+
+	for _, component := range desired {
+		i := slices.IndexFunc(existing, func(stored db.Component) bool { return stored.Name == component.Name })
+		if i >= 0 && sameComponent(existing[i], component) {
+			continue
+		}
+		if err := config.Store.UpsertComponent(component); err != nil {
+			slog.Error("Failed to store component", "component", component.Name, "error", err)
+		}
+	}
+
+	for _, component := range existing {
+		if slices.ContainsFunc(desired, func(wanted db.Component) bool { return wanted.Name == component.Name }) {
+			continue
+		}
+		if err := config.Store.DeleteComponent(component.Name); err != nil {
+			slog.Error("Failed to delete component", "component", component.Name, "error", err)
+		}
+	}
+}
+
+func sameComponent(a db.Component, b db.Component) bool {
+	return a.Name == b.Name &&
+		a.Description == b.Description &&
+		a.Group == b.Group &&
+		a.Order == b.Order &&
+		slices.Equal(a.Monitors, b.Monitors)
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// HandleStatus serves the public status page: the status of every component
+// and group, the overall system status, the active incidents and the active
+// and upcoming maintenance.
+func HandleStatus(w http.ResponseWriter, r *http.Request) {
+	components, err := config.Store.Components()
+	if err != nil {
+		utils.HttpError(w, "Failed to fetch components: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	active, err := config.Store.Incidents(db.IncidentFilter{Active: true})
+	if err != nil {
+		utils.HttpError(w, "Failed to fetch incidents: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	states := serviceStates.Snapshot()
+	response := statusResponse{
+		UpdatedAt:   now,
+		Components:  make([]componentResponse, 0, len(components)),
+		Groups:      []componentGroupResponse{},
+		Incidents:   []incidents.Incident{},
+		Maintenance: []statusMaintenanceResponse{},
+	}
+
+	inMaintenance := map[string]bool{}
+	if Maintenance != nil {
+		for _, occurrence := range Maintenance.Occurrences(now, now.Add(upcomingMaintenance)) {
+			active := occurrence.Contains(now)
+			window := occurrence.Window
+			response.Maintenance = append(response.Maintenance, statusMaintenanceResponse{
+				ID:          window.ID,
+				Title:       window.Title,
+				Description: window.Description,
+				Monitors:    nonNil(window.Monitors),
+				Components:  nonNil(window.Components),
+				Start:       occurrence.Start,
+				End:         occurrence.End,
+				Active:      active,
+			})
+			if !active {
+				continue
+			}
+			for _, monitor := range Maintenance.Monitors(window) {
+				state := states[monitor]
+				state.Maintenance = true
+				states[monitor] = state
+			}
+			for _, component := range window.Components {
+				inMaintenance[component] = true
+			}
+		}
+	}
+
+	var statuses []string
+	groups := map[string]int{}
+	for _, component := range components {
+		componentStatus := status.Component(component.Monitors, states, active)
+		if inMaintenance[component.Name] {
+			componentStatus = status.UnderMaintenance
+		}
+		statuses = append(statuses, componentStatus)
+
+		entry := componentResponse{
+			Name:        component.Name,
+			Description: component.Description,
+			Group:       component.Group,
+			Order:       component.Order,
+			Status:      componentStatus,
+			Monitors:    make([]componentMonitorResponse, 0, len(component.Monitors)),
+		}
+		for _, monitor := range component.Monitors {
+			monitorStatus := status.Unknown
+			if state, ok := states[monitor]; ok {
+				monitorStatus = state.Status()
+			}
+			entry.Monitors = append(entry.Monitors, componentMonitorResponse{Name: monitor, Status: monitorStatus})
+		}
+		response.Components = append(response.Components, entry)
+
+		if component.Group == "" {
+			continue
+		}
+		index, ok := groups[component.Group]
+		if !ok {
+			index = len(response.Groups)
+			groups[component.Group] = index
+			response.Groups = append(response.Groups, componentGroupResponse{Name: component.Group, Status: status.Unknown})
+		}
+		group := &response.Groups[index]
+		group.Status = status.Worse(group.Status, componentStatus)
+		group.Components = append(group.Components, component.Name)
+	}
+
+	for _, incident := range active {
+		statuses = append(statuses, status.IncidentStatus(incident.Severity))
+		if Incidents == nil {
+			continue
+		}
+		view, err := Incidents.Get(incident.ID)
+		if err != nil {
+			slog.Error("Failed to fetch incident", "incident", incident.ID, "error", err)
+			continue
+		}
+		response.Incidents = append(response.Incidents, view)
+	}
+
+	response.Status = status.Overall(statuses)
+	if response.Status == status.Unknown && len(components) == 0 && len(active) == 0 {
+		response.Status = status.Operational
+	}
+
+	utils.JsonResponse(w, response)
+}
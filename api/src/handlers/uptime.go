@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/db"
+	"iammati/statuspage/uptime"
+	"iammati/statuspage/utils"
+)
+
+const (
+	defaultUptimeMonths = 3
+	maxUptimeMonths     = 24
+)
+
+type uptimeWindowResponse struct {
+	Name string    `json:"name"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Availability is in percent and null while nothing is known yet.
+	Availability *float64 `json:"availability"`
+	Uptime       float64  `json:"uptime"`
+	Downtime     float64  `json:"downtime"`
+	ErrorBudget  struct {
+		Allowed   float64 `json:"allowed"`
+		Consumed  float64 `json:"consumed"`
+		Remaining float64 `json:"remaining"`
+		BurnRate  float64 `json:"burnRate"`
+	} `json:"errorBudget"`
+}
+
+// RecordTransition keeps the history the uptime reports are computed from.
+func RecordTransition(transition Transition) {
+	_, err := config.Store.InsertStateTransition(db.StateTransition{
+		Monitor:  transition.Monitor,
+		At:       transition.At,
+		Up:       transition.IsUp,
+		Flapping: transition.Flapping,
+		ProbeIDs: transition.ProbeIDs,
+	})
+	if err != nil {
+		slog.Error("Failed to store state transition", "monitor", transition.Monitor, "error", err)
+	}
+}
+
+func uptimeWindows(reports []uptime.WindowReport) []uptimeWindowResponse {
+	response := make([]uptimeWindowResponse, 0, len(reports))
+	for _, report := range reports {
+		window := uptimeWindowResponse{
+			Name:     report.Name,
+			From:     report.From,
+			To:       report.To,
+			Uptime:   report.Up.Seconds(),
+			Downtime: report.Down.Seconds(),
+		}
+		if ratio, ok := report.Ratio(); ok {
+			percent := ratio * 100
+			window.Availability = &percent
+		}
+		window.ErrorBudget.Allowed = report.Budget.Allowed.Seconds()
+		window.ErrorBudget.Consumed = report.Budget.Consumed.Seconds()
+		window.ErrorBudget.Remaining = report.Budget.Remaining
+		window.ErrorBudget.BurnRate = report.Budget.BurnRate
+		response = append(response, window)
+	}
+	return response
+}
+
+func uptimeMonths(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("months")
+	if value == "" {
+		return defaultUptimeMonths, true
+	}
+	months, err := strconv.Atoi(value)
+	if err != nil || months < 0 || months > maxUptimeMonths {
+		utils.HttpError(w, "Invalid 'months' parameter, expected 0 to "+strconv.Itoa(maxUptimeMonths), http.StatusBadRequest)
+		return 0, false
+	}
+	return months, true
+}
+
+func uptimeResponse(w http.ResponseWriter, monitors []string, target float64, months int, extra map[string]interface{}) {
+	calculator := uptime.Calculator{Store: config.Store}
+	if Maintenance != nil {
+		calculator.Excluded = Maintenance.Intervals
+	}
+	report, err := calculator.Report(monitors, target, months)
+	if err != nil {
+		utils.HttpError(w, "Failed to compute uptime: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if target <= 0 {
+		target = uptime.DefaultSLOTarget
+	}
+
+	response := map[string]interface{}{
+		"sloTarget": target,
+		"windows":   uptimeWindows(report.Windows),
+		"months":    uptimeWindows(report.Months),
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+	utils.JsonResponse(w, response)
+}
+
+// HandleMonitorUptime serves the availability and error budget of a monitor
+// over the rolling windows and the last `months` calendar months.
+func HandleMonitorUptime(w http.ResponseWriter, r *http.Request) {
+	monitor := r.PathValue("monitor")
+	months, ok := uptimeMonths(w, r)
+	if !ok {
+		return
+	}
+
+	monitors, err := config.Store.Monitors()
+	if err != nil {
+		utils.HttpError(w, "Failed to fetch monitors: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var target float64
+	for _, definition := range monitors {
+		if definition.Name == monitor {
+			target = definition.SLOTarget
+		}
+	}
+
+	uptimeResponse(w, []string{monitor}, target, months, map[string]interface{}{"monitor": monitor})
+}
+
+// HandleGroupUptime combines the availability of every monitor in a group.
+// The strictest SLO target of its monitors applies to the group.
+func HandleGroupUptime(w http.ResponseWriter, r *http.Request) {
+	group := r.PathValue("group")
+	months, ok := uptimeMonths(w, r)
+	if !ok {
+		return
+	}
+
+	monitors, err := config.Store.Monitors()
+	if err != nil {
+		utils.HttpError(w, "Failed to fetch monitors: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var names []string
+	var target float64
+	for _, definition := range monitors {
+		if definition.Group != group {
+			continue
+		}
+		names = append(names, definition.Name)
+		target = max(target, definition.SLOTarget)
+	}
+	if len(names) == 0 {
+		utils.HttpError(w, "Unknown group '"+group+"'", http.StatusNotFound)
+		return
+	}
+
+	uptimeResponse(w, names, target, months, map[string]interface{}{"group": group, "monitors": names})
+}
@@ -0,0 +1,155 @@
+package health
+
+import "time"
+
+// Thresholds is how many consecutive probes must agree before a monitor
+// changes between up and down.
+type Thresholds struct {
+	Failures  int
+	Successes int
+}
+
+// FlapDetection marks a monitor as flapping when its raw probe results
+// change too often within a sliding window. High and Low are fractions of
+// changes between consecutive probes; the gap between them keeps the
+// flapping state from toggling itself.
+type FlapDetection struct {
+	Window int
+	High   float64
+	Low    float64
+}
+
+func (f FlapDetection) Enabled() bool {
+	return f.Window > 1 && f.High > 0
+}
+
+// Probe is a single raw probe result fed into a Tracker.
+type Probe struct {
+	// ID of the stored probe result, 0 if it wasn't stored.
+	ID int64
+	Up bool
+	At time.Time
+}
+
+// Transition is emitted when the confirmed state or the flapping state of a
+// monitor changes.
+type Transition struct {
+	Up       bool
+	Flapping bool
+	At       time.Time
+	// Initial is set on the transition reporting the very first state.
+	Initial bool
+	// ProbeIDs are the stored probes that triggered the transition.
+	ProbeIDs []int64
+}
+
+// Tracker turns raw probe results of a single monitor into confirmed state
+// transitions. It is not safe for concurrent use.
+type Tracker struct {
+	initialized bool
+	up          bool
+	flapping    bool
+
+	streak []Probe
+	window []Probe
+}
+
+func (t *Tracker) Up() bool {
+	return t.up
+}
+
+func (t *Tracker) Flapping() bool {
+	return t.flapping
+}
+
+// Observe records a probe and reports whether it caused a transition. The
+// first probe always does, so the initial state is known right away. While
+// a monitor is flapping, up/down changes are tracked but only reported once
+// it settles down again.
+func (t *Tracker) Observe(probe Probe, thresholds Thresholds, flap FlapDetection) (Transition, bool) {
+	if len(t.streak) > 0 && t.streak[0].Up != probe.Up {
+		t.streak = t.streak[:0]
+	}
+	// No threshold looks further back than the longest one.
+	t.streak = appendBounded(t.streak, probe, max(thresholds.Failures, thresholds.Successes, 1))
+
+	if flap.Enabled() {
+		t.window = appendBounded(t.window, probe, flap.Window)
+	} else {
+		t.window = t.window[:0]
+	}
+
+	if !t.initialized {
+		t.initialized = true
+		t.up = probe.Up
+		transition := t.transition(probe.At, probeIDs(t.streak))
+		transition.Initial = true
+		return transition, true
+	}
+
+	required := thresholds.Successes
+	if !probe.Up {
+		required = thresholds.Failures
+	}
+	stateChanged := probe.Up != t.up && len(t.streak) >= max(required, 1)
+	if stateChanged {
+		t.up = probe.Up
+	}
+
+	flappingChanged := false
+	if flap.Enabled() && len(t.window) >= flap.Window {
+		ratio := t.changeRatio()
+		if !t.flapping && ratio >= flap.High {
+			t.flapping, flappingChanged = true, true
+		} else if t.flapping && ratio <= flap.Low {
+			t.flapping, flappingChanged = false, true
+		}
+	}
+
+	switch {
+	case flappingChanged:
+		return t.transition(probe.At, probeIDs(t.window)), true
+	case stateChanged && !t.flapping:
+		return t.transition(probe.At, probeIDs(t.streak)), true
+	default:
+		return Transition{}, false
+	}
+}
+
+func (t *Tracker) transition(at time.Time, ids []int64) Transition {
+	return Transition{Up: t.up, Flapping: t.flapping, At: at, ProbeIDs: ids}
+}
+
+// changeRatio is the fraction of consecutive probes in the window whose
+// result differs.
+func (t *Tracker) changeRatio() float64 {
+	if len(t.window) < 2 {
+		return 0
+	}
+	changes := 0
+	for i := 1; i < len(t.window); i++ {
+		if t.window[i].Up != t.window[i-1].Up {
+			changes++
+		}
+	}
+	return float64(changes) / float64(len(t.window)-1)
+}
+
+// appendBounded appends probe and drops the oldest probes beyond limit.
+func appendBounded(probes []Probe, probe Probe, limit int) []Probe {
+	probes = append(probes, probe)
+	if len(probes) > limit {
+		probes = append(probes[:0], probes[len(probes)-limit:]...)
+	}
+	return probes
+}
+
+func probeIDs(probes []Probe) []int64 {
+	ids := make([]int64, 0, len(probes))
+	for _, probe := range probes {
+		if probe.ID != 0 {
+			ids = append(ids, probe.ID)
+		}
+	}
+	return ids
+}
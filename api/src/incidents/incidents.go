@@ -0,0 +1,395 @@
+package incidents
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"iammati/statuspage/db"
+	"iammati/statuspage/store"
+)
+
+// Events published whenever an incident changes.
+const (
+	EventOpened   = "incidents/opened"
+	EventUpdated  = "incidents/updated"
+	EventResolved = "incidents/resolved"
+)
+
+var (
+	ErrNotFound          = db.ErrNotFound
+	ErrInvalidTransition = errors.New("invalid incident status transition")
+	ErrInvalidSeverity   = errors.New("invalid incident severity")
+)
+
+// Update is a single timeline entry of an incident.
+type Update struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	Status    string    `json:"status"`
+	Message   string    `json:"message"`
+	Author    string    `json:"author,omitempty"`
+}
+
+// Incident is an incident together with its timeline.
+type Incident struct {
+	ID             int64      `json:"id"`
+	Title          string     `json:"title"`
+	Status         string     `json:"status"`
+	Severity       string     `json:"severity"`
+	Monitors       []string   `json:"monitors"`
+	StartedAt      time.Time  `json:"startedAt"`
+	AcknowledgedAt *time.Time `json:"acknowledgedAt,omitempty"`
+	AcknowledgedBy string     `json:"acknowledgedBy,omitempty"`
+	ResolvedAt     *time.Time `json:"resolvedAt,omitempty"`
+	Postmortem     string     `json:"postmortem,omitempty"`
+	// Automatic incidents were opened by a monitor going down and are
+	// resolved once it recovers.
+	Automatic bool     `json:"automatic"`
+	Updates   []Update `json:"updates"`
+}
+
+// Publisher is notified with one of the Event* names and the changed incident.
+type Publisher func(event string, incident Incident)
+
+// Manager opens and resolves incidents from monitor state transitions and
+// implements the manual lifecycle (acknowledge, updates, resolve, postmortem).
+type Manager struct {
+	store   store.IncidentStore
+	publish Publisher
+
+	mu sync.Mutex
+	// up are the monitors confirmed up since the start. Monitors that
+	// haven't been probed yet may still be down.
+	up map[string]bool
+}
+
+func NewManager(s store.IncidentStore, publish Publisher) *Manager {
+	if publish == nil {
+		publish = func(string, Incident) {}
+	}
+	return &Manager{store: s, publish: publish, up: make(map[string]bool)}
+}
+
+func ValidSeverity(severity string) bool {
+	return severity == db.SeverityMinor || severity == db.SeverityMajor || severity == db.SeverityCritical
+}
+
+// HandleTransition opens an incident when a monitor goes down and resolves
+// the incidents it opened once all of their affected monitors are confirmed
+// up. Incidents opened manually are only resolved manually. It returns the
+// incidents the transition belongs to: the ones covering a monitor going
+// down, and the ones updated or resolved by it recovering.
+func (m *Manager) HandleTransition(monitor string, up bool, at time.Time) []Incident {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if up {
+		m.up[monitor] = true
+	} else {
+		delete(m.up, monitor)
+	}
+
+	active, err := m.store.Incidents(db.IncidentFilter{Active: true, Monitor: monitor})
+	if err != nil {
+		slog.Error("Failed to look up incidents", "monitor", monitor, "error", err)
+		return nil
+	}
+
+	var affected []Incident
+	if !up {
+		for _, incident := range active {
+			view, err := m.view(incident)
+			if err != nil {
+				slog.Error("Failed to look up incident", "incident", incident.ID, "error", err)
+				continue
+			}
+			affected = append(affected, view)
+		}
+		if len(active) > 0 {
+			return affected
+		}
+		opened, err := m.create(db.Incident{
+			Title:     fmt.Sprintf("%s is down", monitor),
+			Status:    db.IncidentOpen,
+			Severity:  db.SeverityMajor,
+			Monitors:  []string{monitor},
+			StartedAt: at,
+			Automatic: true,
+		}, fmt.Sprintf("Monitor '%s' went down.", monitor), "")
+		if err != nil {
+			slog.Error("Failed to open incident", "monitor", monitor, "error", err)
+			return nil
+		}
+		return []Incident{opened}
+	}
+
+	for _, incident := range active {
+		recovered := !slices.ContainsFunc(incident.Monitors, func(name string) bool { return !m.up[name] })
+		if !incident.Automatic || !recovered {
+			updated, err := m.addUpdate(incident, incident.Status, fmt.Sprintf("Monitor '%s' recovered.", monitor), "", at)
+			if err != nil {
+				slog.Error("Failed to update incident", "incident", incident.ID, "monitor", monitor, "error", err)
+				continue
+			}
+			affected = append(affected, updated)
+			continue
+		}
+		resolved, err := m.resolve(incident, fmt.Sprintf("Monitor '%s' recovered.", monitor), "", at)
+		if err != nil {
+			slog.Error("Failed to resolve incident", "incident", incident.ID, "monitor", monitor, "error", err)
+			continue
+		}
+		affected = append(affected, resolved)
+	}
+	return affected
+}
+
+// Create opens an incident manually.
+func (m *Manager) Create(title string, severity string, monitors []string, message string, author string) (Incident, error) {
+	if severity == "" {
+		severity = db.SeverityMajor
+	}
+	if !ValidSeverity(severity) {
+		return Incident{}, ErrInvalidSeverity
+	}
+	if message == "" {
+		message = "Incident opened."
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.create(db.Incident{
+		Title:     title,
+		Status:    db.IncidentOpen,
+		Severity:  severity,
+		Monitors:  monitors,
+		StartedAt: time.Now(),
+	}, message, author)
+}
+
+func (m *Manager) Get(id int64) (Incident, error) {
+	incident, err := m.store.Incident(id)
+	if err != nil {
+		return Incident{}, err
+	}
+	return m.view(incident)
+}
+
+func (m *Manager) List(filter db.IncidentFilter) ([]Incident, error) {
+	incidents, err := m.store.Incidents(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]Incident, 0, len(incidents))
+	for _, incident := range incidents {
+		view, err := m.view(incident)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+func (m *Manager) Acknowledge(id int64, author string) (Incident, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	incident, err := m.store.Incident(id)
+	if err != nil {
+		return Incident{}, err
+	}
+	if incident.Status != db.IncidentOpen {
+		return Incident{}, ErrInvalidTransition
+	}
+
+	now := time.Now()
+	incident.Status = db.IncidentAcknowledged
+	incident.AcknowledgedAt = now
+	incident.AcknowledgedBy = author
+	if err := m.store.UpdateIncident(incident); err != nil {
+		return Incident{}, err
+	}
+	return m.addUpdate(incident, incident.Status, "Incident acknowledged.", author, now)
+}
+
+func (m *Manager) Resolve(id int64, message string, author string) (Incident, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	incident, err := m.store.Incident(id)
+	if err != nil {
+		return Incident{}, err
+	}
+	if incident.Status == db.IncidentResolved {
+		return Incident{}, ErrInvalidTransition
+	}
+	if message == "" {
+		message = "Incident resolved."
+	}
+
+	return m.resolve(incident, message, author, time.Now())
+}
+
+// AddUpdate posts a timeline update, optionally changing the severity.
+func (m *Manager) AddUpdate(id int64, message string, severity string, author string) (Incident, error) {
+	if severity != "" && !ValidSeverity(severity) {
+		return Incident{}, ErrInvalidSeverity
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	incident, err := m.store.Incident(id)
+	if err != nil {
+		return Incident{}, err
+	}
+	if severity != "" && severity != incident.Severity {
+		incident.Severity = severity
+		if err := m.store.UpdateIncident(incident); err != nil {
+			return Incident{}, err
+		}
+	}
+	return m.addUpdate(incident, incident.Status, message, author, time.Now())
+}
+
+// SetPostmortem stores the postmortem notes of a resolved incident.
+func (m *Manager) SetPostmortem(id int64, postmortem string) (Incident, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	incident, err := m.store.Incident(id)
+	if err != nil {
+		return Incident{}, err
+	}
+	if incident.Status != db.IncidentResolved {
+		return Incident{}, ErrInvalidTransition
+	}
+
+	incident.Postmortem = postmortem
+	if err := m.store.UpdateIncident(incident); err != nil {
+		return Incident{}, err
+	}
+
+	view, err := m.view(incident)
+	if err != nil {
+		return Incident{}, err
+	}
+	m.publish(EventUpdated, view)
+	return view, nil
+}
+
+func (m *Manager) create(incident db.Incident, message string, author string) (Incident, error) {
+	id, err := m.store.CreateIncident(incident)
+	if err != nil {
+		return Incident{}, err
+	}
+	incident.ID = id
+
+	if _, err := m.store.AddIncidentUpdate(db.IncidentUpdate{
+		IncidentID: id,
+		CreatedAt:  incident.StartedAt,
+		Status:     incident.Status,
+		Message:    message,
+		Author:     author,
+	}); err != nil {
+		return Incident{}, err
+	}
+
+	view, err := m.view(incident)
+	if err != nil {
+		return Incident{}, err
+	}
+	slog.Info("Opened incident", "incident", id, "title", incident.Title)
+	m.publish(EventOpened, view)
+	return view, nil
+}
+
+func (m *Manager) addUpdate(incident db.Incident, status string, message string, author string, at time.Time) (Incident, error) {
+	if _, err := m.store.AddIncidentUpdate(db.IncidentUpdate{
+		IncidentID: incident.ID,
+		CreatedAt:  at,
+		Status:     status,
+		Message:    message,
+		Author:     author,
+	}); err != nil {
+		return Incident{}, err
+	}
+
+	view, err := m.view(incident)
+	if err != nil {
+		return Incident{}, err
+	}
+	m.publish(EventUpdated, view)
+	return view, nil
+}
+
+func (m *Manager) resolve(incident db.Incident, message string, author string, at time.Time) (Incident, error) {
+	incident.Status = db.IncidentResolved
+	incident.ResolvedAt = at
+	if err := m.store.UpdateIncident(incident); err != nil {
+		return Incident{}, err
+	}
+	if _, err := m.store.AddIncidentUpdate(db.IncidentUpdate{
+		IncidentID: incident.ID,
+		CreatedAt:  at,
+		Status:     incident.Status,
+		Message:    message,
+		Author:     author,
+	}); err != nil {
+		return Incident{}, err
+	}
+
+	view, err := m.view(incident)
+	if err != nil {
+		return Incident{}, err
+	}
+	slog.Info("Resolved incident", "incident", incident.ID, "title", incident.Title)
+	m.publish(EventResolved, view)
+	return view, nil
+}
+
+func (m *Manager) view(incident db.Incident) (Incident, error) {
+	updates, err := m.store.IncidentUpdates(incident.ID)
+	if err != nil {
+		return Incident{}, err
+	}
+
+	view := Incident{
+		ID:             incident.ID,
+		Title:          incident.Title,
+		Status:         incident.Status,
+		Severity:       incident.Severity,
+		Monitors:       incident.Monitors,
+		StartedAt:      incident.StartedAt,
+		AcknowledgedBy: incident.AcknowledgedBy,
+		Postmortem:     incident.Postmortem,
+		Automatic:      incident.Automatic,
+		Updates:        make([]Update, 0, len(updates)),
+	}
+	if view.Monitors == nil {
+		view.Monitors = []string{}
+	}
+	if !incident.AcknowledgedAt.IsZero() {
+		view.AcknowledgedAt = &incident.AcknowledgedAt
+	}
+	if !incident.ResolvedAt.IsZero() {
+		view.ResolvedAt = &incident.ResolvedAt
+	}
+	for _, update := range updates {
+		view.Updates = append(view.Updates, Update{
+			ID:        update.ID,
+			CreatedAt: update.CreatedAt,
+			Status:    update.Status,
+			Message:   update.Message,
+			Author:    update.Author,
+		})
+	}
+	return view, nil
+}
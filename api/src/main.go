@@ -2,28 +2,105 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"iammati/statuspage/config"
+	"iammati/statuspage/crd"
+	"iammati/statuspage/discovery"
 	"iammati/statuspage/handlers"
+	"iammati/statuspage/incidents"
+	"iammati/statuspage/maintenance"
+	"iammati/statuspage/monitors"
+	"iammati/statuspage/notify"
+	"iammati/statuspage/scheduler"
+	"iammati/statuspage/tracing"
 	"iammati/statuspage/websocket"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
 	// Bootstrapping the application
 	config.Bootstrap()
 
-	// Connect to the database
-	config.DbConn = config.Database()
-	defer config.DbConn.Close()
+	// Export traces and propagate trace context
+	shutdownTracing, err := tracing.Setup(context.Background(), config.AppSettings.Tracing)
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), config.AppSettings.HTTP.ShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
+		}
+	}()
+
+	// Connect to the configured store
+	config.Store = config.OpenStore()
+	defer config.Store.Close()
+
+	// Keep the configured log levels in the store
+	config.LogToStore(config.Store)
 
-	port := "8080"
+	// Load maintenance windows before any transition is handled
+	handlers.Maintenance = maintenance.NewManager(config.Store)
+	if err := handlers.Maintenance.Reload(); err != nil {
+		slog.Error("Failed to load maintenance windows", "error", err)
+	}
+
+	// Deliver state changes and incident events to the notification channels
+	notifier, err := notify.FromSettings(config.Store, config.AppSettings.Notifications)
+	if err != nil {
+		slog.Error("Invalid notification channels", "error", err)
+		os.Exit(1)
+	}
+	handlers.Notifier = notifier
+	defer handlers.Notifier.Stop()
+	handlers.Alerts = notify.NewRouter(notifier, config.AppSettings.Notifications.Routes)
+	handlers.Alerts.Lookup = handlers.MonitorLabels
+	stopAlerts := make(chan struct{})
+	defer close(stopAlerts)
+	go handlers.Alerts.Run(config.AppSettings.Notifications.EscalationInterval, stopAlerts)
+
+	// Open incidents when monitors go down and push changes to WebSocket clients
+	handlers.Incidents = incidents.NewManager(config.Store, func(event string, incident incidents.Incident) {
+		websocket.Publish(event, incident)
+		handlers.NotifyIncident(event, incident)
+	})
+	handlers.OnTransition(handlers.RecordTransition)
+	handlers.OnTransition(handlers.TrackMaintenanceTransition)
+	handlers.OnTransition(handlers.HandleMonitorTransition)
+
+	// Catch up on monitors that are still down when their maintenance ends
+	stopMaintenance := make(chan struct{})
+	defer close(stopMaintenance)
+	go handlers.ReplayMaintenanceTransitions(time.Minute, stopMaintenance)
+
+	// Probe registered monitors on their own schedule. The handlers read the
+	// scheduler, so it is running before the HTTP server starts
+	handlers.Scheduler = scheduler.New(scheduler.Options{
+		Workers: scheduler.DefaultWorkers,
+		Jitter:  scheduler.DefaultJitter,
+		Handler: handlers.RecordProbe,
+	})
+	handlers.Scheduler.Start()
+	defer handlers.Scheduler.Stop()
+
+	port := strconv.Itoa(config.AppSettings.HTTP.Port)
 	srv := &http.Server{
 		Addr: ":" + port,
 	}
@@ -32,65 +109,136 @@ func main() {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/up", handlers.HandleUp)
 	mux.HandleFunc("/certinfo", handlers.HandleCertInfo)
+	mux.HandleFunc("GET /api/v1/status", handlers.HandleStatus)
+	mux.HandleFunc("GET /api/v1/monitors/{monitor}/probes", handlers.HandleProbeResults)
+	mux.HandleFunc("GET /api/v1/monitors/{monitor}/uptime", handlers.HandleMonitorUptime)
+	mux.HandleFunc("GET /api/v1/groups/{group}/uptime", handlers.HandleGroupUptime)
+	mux.HandleFunc("GET /api/v1/maintenance", handlers.HandleListMaintenance)
+	mux.HandleFunc("POST /api/v1/maintenance", handlers.HandleCreateMaintenance)
+	mux.HandleFunc("GET /api/v1/maintenance/{id}", handlers.HandleGetMaintenance)
+	mux.HandleFunc("PUT /api/v1/maintenance/{id}", handlers.HandleUpdateMaintenance)
+	mux.HandleFunc("DELETE /api/v1/maintenance/{id}", handlers.HandleDeleteMaintenance)
+	mux.HandleFunc("GET /api/v1/incidents", handlers.HandleListIncidents)
+	mux.HandleFunc("POST /api/v1/incidents", handlers.HandleCreateIncident)
+	mux.HandleFunc("GET /api/v1/incidents/{id}", handlers.HandleGetIncident)
+	mux.HandleFunc("POST /api/v1/incidents/{id}/updates", handlers.HandleAddIncidentUpdate)
+	mux.HandleFunc("POST /api/v1/incidents/{id}/acknowledge", handlers.HandleAcknowledgeIncident)
+	mux.HandleFunc("POST /api/v1/incidents/{id}/resolve", handlers.HandleResolveIncident)
+	mux.HandleFunc("PUT /api/v1/incidents/{id}/postmortem", handlers.HandleIncidentPostmortem)
+	mux.HandleFunc("GET /api/v1/notifications/channels", handlers.HandleListChannels)
+	mux.HandleFunc("POST /api/v1/notifications/channels/{channel}/test", handlers.HandleTestChannel)
+	mux.HandleFunc("GET /api/v1/notifications/deliveries", handlers.HandleListDeliveries)
+	mux.HandleFunc("GET /api/v1/notifications/alerts", handlers.HandleListAlerts)
+	mux.HandleFunc("POST /api/v1/notifications/alerts/{id}/acknowledge", handlers.HandleAcknowledgeAlert)
+	mux.HandleFunc("GET /api/v1/logs", handlers.HandleListLogs)
 
 	// WebSocket server
 	mux.HandleFunc("/ws", websocket.Handle)
 
-	// Wrap the mux with the CORS middleware
-	corsHandler := corsMiddleware(mux)
+	// Prometheus metrics
+	if config.AppSettings.Metrics.Enabled {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: "statuspage_websocket_clients",
+				Help: "Number of connected WebSocket clients.",
+			}, func() float64 {
+				return float64(websocket.ClientCount())
+			}),
+		)
+		handlers.RegisterMetrics(registry)
+		mux.Handle("GET /metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	}
+
+	// Wrap the mux with the CORS and tracing middleware
+	corsHandler := tracing.Middleware(corsMiddleware(mux))
 
 	// Start WebSocket broadcast routine
 	go websocket.BroadcastMessages()
 
-	for range 5 {
-		PrintLog("", false)
-	}
-
-	PrintLog("======================================= STATUSPAGE =======================================", false)
-	PrintLog("", false)
-
 	srv.Handler = corsHandler
 
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			PrintLog("listen: ", true)
-			log.Fatalf("%s\n", err)
+			slog.Error("HTTP server failed", "error", err)
+			os.Exit(1)
 		}
 	}()
-	PrintLog(fmt.Sprintf("HTTP server listening on port *:%s...", port), false)
+	slog.Info("HTTP server listening", "port", port)
 
 	go handlers.MonitorHostChanges(5 * time.Second)
 
+	// Load declarative monitors and keep them in sync with the file
+	watcher := monitors.NewWatcher(config.AppSettings.MonitorsFile, handlers.MonitorRegistry{Source: handlers.SourceFile})
+	watcher.OnReload(handlers.SyncComponents)
+	if err := watcher.Reload(); err != nil {
+		slog.Warn("Starting without declarative monitors", "error", err)
+	}
+	stopWatcher := make(chan struct{})
+	defer close(stopWatcher)
+	go watcher.Run(monitors.DefaultPollInterval, stopWatcher)
+
+	// Discover monitors from the pods selected by the discovery targets and
+	// from Ingresses
+	if config.AppSettings.Discovery.Enabled && config.Clientset == nil {
+		slog.Info("Kubernetes is unavailable, not discovering monitors")
+	} else if config.AppSettings.Discovery.Enabled {
+		discovered, err := discovery.FromSettings(config.Clientset, config.DynamicClient, handlers.MonitorRegistry{Source: handlers.SourceDiscovery}, config.AppSettings.Discovery)
+		if err != nil {
+			slog.Error("Invalid discovery settings", "error", err)
+			os.Exit(1)
+		}
+		stopDiscovery := make(chan struct{})
+		defer close(stopDiscovery)
+		go discovered.Run(stopDiscovery)
+	}
+
+	// Run the monitors declared as Monitor resources and write their health
+	// back to the resources
+	if config.AppSettings.CRD.Enabled && config.Clientset != nil && config.DynamicClient != nil && crd.Served(config.Clientset) {
+		controller := crd.New(config.DynamicClient, config.AppSettings.CRD.Namespace, crd.Options{
+			Registry:       handlers.MonitorRegistry{Source: handlers.SourceResource},
+			States:         handlers.MonitorStates,
+			Transitions:    config.Store,
+			Excluded:       handlers.Maintenance.Intervals,
+			Resync:         config.AppSettings.Discovery.Resync,
+			StatusInterval: config.AppSettings.CRD.StatusInterval,
+		})
+		handlers.OnProbe(controller.Observe)
+		stopMonitors := make(chan struct{})
+		defer close(stopMonitors)
+		go controller.Run(stopMonitors)
+	} else if config.AppSettings.CRD.Enabled {
+		slog.Info("Monitor resources are not served, not watching them")
+	}
+
+	if config.AppSettings.Metrics.Enabled {
+		stopCertificates := make(chan struct{})
+		defer close(stopCertificates)
+		go handlers.MonitorCertificates(config.AppSettings.Metrics.CertInterval, stopCertificates)
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	PrintLog("Shutting down server...", false)
+	slog.Info("Shutting down server")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), config.AppSettings.HTTP.ShutdownTimeout)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		PrintLog("Server forced to shutdown: ", true)
-		log.Fatalf("%s\n", err)
+		slog.Error("Server forced to shutdown", "error", err)
+		os.Exit(1)
 	}
 
-	PrintLog("Server exiting", false)
-}
-
-func PrintLog(reason string, doNotLogIt bool) {
-	if !doNotLogIt {
-		handlers.LogUpdatetimeEvent(handlers.UpdatetimeEvent{
-			Time:   time.Now(),
-			Reason: reason,
-		}, !doNotLogIt)
-
-		log.Println(reason)
-	}
+	slog.Info("Server exiting")
 }
 
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Set CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", "http://app:3000")
+		w.Header().Set("Access-Control-Allow-Origin", config.AppSettings.HTTP.CORSOrigin)
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
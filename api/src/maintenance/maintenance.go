@@ -0,0 +1,145 @@
+package maintenance
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/teambition/rrule-go"
+
+	"iammati/statuspage/db"
+)
+
+var ErrInvalidWindow = errors.New("invalid maintenance window")
+
+// ErrTooManyOccurrences is returned along with the first maxOccurrences
+// occurrences of a window recurring more often within a range.
+var ErrTooManyOccurrences = errors.New("too many maintenance occurrences")
+
+// maxOccurrences bounds how many occurrences of a recurring window are
+// expanded for a single range.
+const maxOccurrences = 10000
+
+// Interval is a single occurrence of a maintenance window.
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (i Interval) Contains(t time.Time) bool {
+	return !t.Before(i.Start) && t.Before(i.End)
+}
+
+// Occurrences returns the occurrences of a window overlapping [from, to),
+// oldest first. One-off windows have a single occurrence from StartsAt to
+// EndsAt. Recurring windows start on every tick of their cron expression or
+// RRULE, last Duration and are bounded by StartsAt and an optional EndsAt.
+// Only the first maxOccurrences are returned, along with
+// ErrTooManyOccurrences if there are more.
+func Occurrences(window db.MaintenanceWindow, from, to time.Time) ([]Interval, error) {
+	if window.Schedule == "" {
+		interval := Interval{Start: window.StartsAt, End: window.EndsAt}
+		if interval.Start.Before(to) && interval.End.After(from) {
+			return []Interval{interval}, nil
+		}
+		return nil, nil
+	}
+
+	next, err := parseSchedule(window.Schedule, window.StartsAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Occurrences starting up to Duration before from still overlap it.
+	after := from.Add(-window.Duration)
+	if after.Before(window.StartsAt) {
+		after = window.StartsAt
+	}
+	after = after.Add(-time.Nanosecond)
+
+	var intervals []Interval
+	for {
+		start := next(after)
+		if start.IsZero() || !start.Before(to) {
+			break
+		}
+		if !window.EndsAt.IsZero() && !start.Before(window.EndsAt) {
+			break
+		}
+		if len(intervals) == maxOccurrences {
+			return intervals, fmt.Errorf("%w: more than %d until %s", ErrTooManyOccurrences, maxOccurrences, to.Format(time.RFC3339))
+		}
+		intervals = append(intervals, Interval{Start: start, End: start.Add(window.Duration)})
+		after = start
+	}
+	return intervals, nil
+}
+
+// parseSchedule accepts a standard five-field cron expression (optionally
+// prefixed with CRON_TZ=) or an RRULE, and returns a function yielding the
+// first occurrence strictly after a given time.
+func parseSchedule(schedule string, dtstart time.Time) (func(time.Time) time.Time, error) {
+	upper := strings.ToUpper(schedule)
+	if strings.HasPrefix(upper, "RRULE:") || strings.Contains(upper, "FREQ=") {
+		if strings.HasPrefix(upper, "RRULE:") {
+			schedule = schedule[len("RRULE:"):]
+		}
+		option, err := rrule.StrToROption(schedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RRULE: %v", err)
+		}
+		if option.Dtstart.IsZero() {
+			option.Dtstart = dtstart
+		}
+		rule, err := rrule.NewRRule(*option)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RRULE: %v", err)
+		}
+		return func(after time.Time) time.Time {
+			return rule.After(after, false)
+		}, nil
+	}
+
+	parsed, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression: %v", err)
+	}
+	return parsed.Next, nil
+}
+
+// Validate checks a window before it is stored.
+func Validate(window db.MaintenanceWindow) error {
+	var problems []string
+	if window.Title == "" {
+		problems = append(problems, "title is required")
+	}
+	if len(window.Monitors) == 0 && len(window.Components) == 0 {
+		problems = append(problems, "at least one monitor or component is required")
+	}
+	if window.StartsAt.IsZero() {
+		problems = append(problems, "startsAt is required")
+	}
+
+	if window.Schedule == "" {
+		if !window.EndsAt.After(window.StartsAt) {
+			problems = append(problems, "endsAt must be after startsAt")
+		}
+	} else {
+		if window.Duration <= 0 {
+			problems = append(problems, "recurring windows need a positive duration")
+		}
+		if !window.EndsAt.IsZero() && !window.EndsAt.After(window.StartsAt) {
+			problems = append(problems, "endsAt must be after startsAt")
+		}
+		if _, err := parseSchedule(window.Schedule, window.StartsAt); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidWindow, strings.Join(problems, "; "))
+	}
+	return nil
+}
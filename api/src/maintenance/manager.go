@@ -0,0 +1,222 @@
+package maintenance
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"iammati/statuspage/db"
+	"iammati/statuspage/store"
+)
+
+var ErrNotFound = db.ErrNotFound
+
+// Store persists maintenance windows and resolves the monitors of the
+// components they target.
+type Store interface {
+	store.MaintenanceStore
+	store.ComponentStore
+}
+
+// Manager keeps the maintenance windows cached, since they are consulted on
+// every state transition.
+type Manager struct {
+	store Store
+
+	mu      sync.RWMutex
+	windows []db.MaintenanceWindow
+}
+
+func NewManager(s Store) *Manager {
+	return &Manager{store: s}
+}
+
+// Reload refreshes the cached windows from the store.
+func (m *Manager) Reload() error {
+	windows, err := m.store.MaintenanceWindows()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.windows = windows
+	return nil
+}
+
+func (m *Manager) List() []db.MaintenanceWindow {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.windows)
+}
+
+func (m *Manager) Get(id int64) (db.MaintenanceWindow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, window := range m.windows {
+		if window.ID == id {
+			return window, nil
+		}
+	}
+	return db.MaintenanceWindow{}, ErrNotFound
+}
+
+func (m *Manager) Create(window db.MaintenanceWindow) (db.MaintenanceWindow, error) {
+	if err := Validate(window); err != nil {
+		return db.MaintenanceWindow{}, err
+	}
+	window.CreatedAt = time.Now()
+
+	id, err := m.store.CreateMaintenanceWindow(window)
+	if err != nil {
+		return db.MaintenanceWindow{}, err
+	}
+	window.ID = id
+	return window, m.Reload()
+}
+
+func (m *Manager) Update(window db.MaintenanceWindow) (db.MaintenanceWindow, error) {
+	if err := Validate(window); err != nil {
+		return db.MaintenanceWindow{}, err
+	}
+	if err := m.store.UpdateMaintenanceWindow(window); err != nil {
+		return db.MaintenanceWindow{}, err
+	}
+	if err := m.Reload(); err != nil {
+		return db.MaintenanceWindow{}, err
+	}
+	return m.Get(window.ID)
+}
+
+func (m *Manager) Delete(id int64) error {
+	if err := m.store.DeleteMaintenanceWindow(id); err != nil {
+		return err
+	}
+	return m.Reload()
+}
+
+// Occurrence is a single occurrence of a window, e.g. for the status page.
+type Occurrence struct {
+	Window db.MaintenanceWindow
+	Interval
+}
+
+// Occurrences lists every occurrence of every window overlapping [from, to),
+// ordered by start.
+func (m *Manager) Occurrences(from, to time.Time) []Occurrence {
+	var occurrences []Occurrence
+	for _, window := range m.List() {
+		intervals, err := Occurrences(window, from, to)
+		switch {
+		case errors.Is(err, ErrTooManyOccurrences):
+			slog.Warn("Truncating maintenance window", "window", window.ID, "until", intervals[len(intervals)-1].End, "error", err)
+		case err != nil:
+			slog.Warn("Skipping maintenance window", "window", window.ID, "error", err)
+			continue
+		}
+		for _, interval := range intervals {
+			occurrences = append(occurrences, Occurrence{Window: window, Interval: interval})
+		}
+	}
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].Start.Before(occurrences[j].Start)
+	})
+	return occurrences
+}
+
+// Monitors returns the monitors a window covers, including the monitors of
+// the components it targets.
+func (m *Manager) Monitors(window db.MaintenanceWindow) []string {
+	return covered(window, m.components([]db.MaintenanceWindow{window}))
+}
+
+// components loads the components once if any of the windows targets some.
+func (m *Manager) components(windows []db.MaintenanceWindow) []db.Component {
+	if !slices.ContainsFunc(windows, func(window db.MaintenanceWindow) bool { return len(window.Components) > 0 }) {
+		return nil
+	}
+	components, err := m.store.Components()
+	if err != nil {
+		slog.Error("Failed to resolve components of maintenance windows", "error", err)
+		return nil
+	}
+	return components
+}
+
+func covered(window db.MaintenanceWindow, components []db.Component) []string {
+	monitors := slices.Clone(window.Monitors)
+	for _, component := range components {
+		if slices.Contains(window.Components, component.Name) {
+			monitors = append(monitors, component.Monitors...)
+		}
+	}
+	return monitors
+}
+
+// Intervals returns the maintenance of a monitor within [from, to), merged
+// into non-overlapping intervals ordered by start.
+func (m *Manager) Intervals(monitor string, from, to time.Time) []Interval {
+	occurrences := m.Occurrences(from, to)
+	windows := make([]db.MaintenanceWindow, 0, len(occurrences))
+	for _, occurrence := range occurrences {
+		windows = append(windows, occurrence.Window)
+	}
+	components := m.components(windows)
+
+	var intervals []Interval
+	for _, occurrence := range occurrences {
+		if slices.Contains(covered(occurrence.Window, components), monitor) {
+			intervals = append(intervals, occurrence.Interval)
+		}
+	}
+	return Merge(intervals)
+}
+
+// Active returns the monitors covered by a window active at the given time.
+func (m *Manager) Active(at time.Time) []string {
+	occurrences := m.Occurrences(at, at.Add(time.Nanosecond))
+	windows := make([]db.MaintenanceWindow, 0, len(occurrences))
+	for _, occurrence := range occurrences {
+		windows = append(windows, occurrence.Window)
+	}
+	components := m.components(windows)
+
+	var monitors []string
+	for _, window := range windows {
+		monitors = append(monitors, covered(window, components)...)
+	}
+	return monitors
+}
+
+// UnderMaintenance reports whether a monitor is in maintenance at the given time.
+func (m *Manager) UnderMaintenance(monitor string, at time.Time) bool {
+	return len(m.Intervals(monitor, at, at.Add(time.Nanosecond))) > 0
+}
+
+// Merge sorts intervals and joins the ones that overlap or touch.
+func Merge(intervals []Interval) []Interval {
+	if len(intervals) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(intervals)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+
+	merged := []Interval{sorted[0]}
+	for _, interval := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if interval.Start.After(last.End) {
+			merged = append(merged, interval)
+			continue
+		}
+		if interval.End.After(last.End) {
+			last.End = interval.End
+		}
+	}
+	return merged
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/db"
+)
+
+const migrateUsage = "usage: statuspage migrate status|up|down [steps]"
+
+// runMigrate implements the `migrate` command and returns the exit code.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load settings: %v\n", err)
+		return 1
+	}
+	config.AppSettings = settings
+
+	conn, err := config.Connect()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to connect to database: %v\n", err)
+		return 1
+	}
+	defer conn.Close()
+
+	switch args[0] {
+	case "status":
+		statuses, err := db.MigrationStatuses(conn)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read migration status: %v\n", err)
+			return 1
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, status := range statuses {
+			state, appliedAt := "pending", ""
+			if status.Applied {
+				state, appliedAt = "applied", status.AppliedAt.Format(time.RFC3339)
+			}
+			if status.ChecksumMismatch {
+				state = "checksum mismatch"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+		}
+		w.Flush()
+	case "up":
+		if err := db.MigrateUp(conn); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to migrate database: %v\n", err)
+			return 1
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+		}
+		if err := db.MigrateDown(conn, steps); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to revert migrations: %v\n", err)
+			return 1
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	return 0
+}
@@ -0,0 +1,319 @@
+package monitors
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"iammati/statuspage/scheduler"
+)
+
+// Duration accepts Go duration strings ("30s", "1m") in monitor files.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q", value.Line, value.Value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+type Defaults struct {
+	Interval       Duration `yaml:"interval"`
+	Timeout        Duration `yaml:"timeout"`
+	ExpectedStatus []int    `yaml:"expectedStatus"`
+	// SLO is the availability objective in percent, e.g. 99.9.
+	SLO float64 `yaml:"slo"`
+	// FailureThreshold and SuccessThreshold are the consecutive probes
+	// needed to mark a monitor down or up.
+	FailureThreshold int `yaml:"failureThreshold"`
+	SuccessThreshold int `yaml:"successThreshold"`
+}
+
+type Group struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Defaults    `yaml:",inline"`
+
+	Line int `yaml:"-"`
+}
+
+func (g *Group) UnmarshalYAML(value *yaml.Node) error {
+	type plain Group
+	if err := value.Decode((*plain)(g)); err != nil {
+		return err
+	}
+	g.Line = value.Line
+	return nil
+}
+
+type Definition struct {
+	Name     string   `yaml:"name"`
+	Type     string   `yaml:"type"` // http (the default) or certificate
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Path     string   `yaml:"path"`
+	Group    string   `yaml:"group"`
+	Tags     []string `yaml:"tags"`
+	Defaults `yaml:",inline"`
+
+	Line int `yaml:"-"`
+}
+
+func (d *Definition) UnmarshalYAML(value *yaml.Node) error {
+	type plain Definition
+	if err := value.Decode((*plain)(d)); err != nil {
+		return err
+	}
+	d.Line = value.Line
+	return nil
+}
+
+// Component is a public-facing part of the system, backed by monitors.
+type Component struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Group       string   `yaml:"group"`
+	Order       int      `yaml:"order"`
+	Monitors    []string `yaml:"monitors"`
+
+	Line int `yaml:"-"`
+}
+
+func (c *Component) UnmarshalYAML(value *yaml.Node) error {
+	type plain Component
+	if err := value.Decode((*plain)(c)); err != nil {
+		return err
+	}
+	c.Line = value.Line
+	return nil
+}
+
+// GlobalDefaults apply to every monitor that neither sets a value itself
+// nor inherits one from its group.
+type GlobalDefaults struct {
+	Defaults `yaml:",inline"`
+
+	Line int `yaml:"-"`
+}
+
+func (g *GlobalDefaults) UnmarshalYAML(value *yaml.Node) error {
+	type plain GlobalDefaults
+	if err := value.Decode((*plain)(g)); err != nil {
+		return err
+	}
+	g.Line = value.Line
+	return nil
+}
+
+// File is the declarative monitor configuration, written as YAML or JSON.
+type File struct {
+	Defaults   GlobalDefaults `yaml:"defaults"`
+	Groups     []Group        `yaml:"groups"`
+	Monitors   []Definition   `yaml:"monitors"`
+	Components []Component    `yaml:"components"`
+}
+
+// ValidationError collects every problem found in a monitors file.
+type ValidationError struct {
+	Path     string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid monitors file %s:\n  %s", e.Path, strings.Join(e.Problems, "\n  "))
+}
+
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading monitors file: %v", err)
+	}
+	return Parse(path, data)
+}
+
+func Parse(path string, data []byte) (*File, error) {
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, &ValidationError{Path: path, Problems: []string{strings.TrimPrefix(err.Error(), "yaml: ")}}
+	}
+
+	if problems := file.validate(); len(problems) > 0 {
+		return nil, &ValidationError{Path: path, Problems: problems}
+	}
+
+	return &file, nil
+}
+
+func (f *File) validate() []string {
+	var problems []string
+	report := func(line int, format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("line %d: ", line)+fmt.Sprintf(format, args...))
+	}
+
+	groups := map[string]bool{}
+	for _, group := range f.Groups {
+		if group.Name == "" {
+			report(group.Line, "group is missing a name")
+			continue
+		}
+		if groups[group.Name] {
+			report(group.Line, "duplicate group '%s'", group.Name)
+		}
+		groups[group.Name] = true
+		validateDefaults(group.Line, group.Defaults, report)
+	}
+
+	names := map[string]bool{}
+	for _, monitor := range f.Monitors {
+		if monitor.Host == "" {
+			report(monitor.Line, "monitor is missing a host")
+		} else if strings.Contains(monitor.Host, "/") {
+			report(monitor.Line, "host '%s' must not contain a scheme or path", monitor.Host)
+		} else if _, port, err := net.SplitHostPort(monitor.Host); err == nil && monitor.Port != 0 && port != strconv.Itoa(monitor.Port) {
+			report(monitor.Line, "host '%s' conflicts with port %d", monitor.Host, monitor.Port)
+		}
+
+		key := f.definitionKey(monitor)
+		if names[key] {
+			report(monitor.Line, "duplicate monitor '%s'", key)
+		}
+		names[key] = true
+
+		switch monitor.Type {
+		case "", scheduler.TypeHTTP, scheduler.TypeCertificate:
+		default:
+			report(monitor.Line, "unknown type '%s', must be %s or %s", monitor.Type, scheduler.TypeHTTP, scheduler.TypeCertificate)
+		}
+		if monitor.Port < 0 || monitor.Port > 65535 {
+			report(monitor.Line, "port %d is out of range", monitor.Port)
+		}
+		if monitor.Path != "" && !strings.HasPrefix(monitor.Path, "/") {
+			report(monitor.Line, "path '%s' must start with '/'", monitor.Path)
+		}
+		if monitor.Group != "" && !groups[monitor.Group] {
+			report(monitor.Line, "unknown group '%s'", monitor.Group)
+		}
+		validateDefaults(monitor.Line, monitor.Defaults, report)
+	}
+
+	validateDefaults(f.Defaults.Line, f.Defaults.Defaults, report)
+
+	components := map[string]bool{}
+	for _, component := range f.Components {
+		if component.Name == "" {
+			report(component.Line, "component is missing a name")
+		} else if components[component.Name] {
+			report(component.Line, "duplicate component '%s'", component.Name)
+		}
+		components[component.Name] = true
+
+		if component.Group != "" && !groups[component.Group] {
+			report(component.Line, "unknown group '%s'", component.Group)
+		}
+		for _, monitor := range component.Monitors {
+			if !names[monitor] {
+				report(component.Line, "unknown monitor '%s'", monitor)
+			}
+		}
+	}
+
+	return problems
+}
+
+func validateDefaults(line int, defaults Defaults, report func(int, string, ...interface{})) {
+	if defaults.Interval < 0 || (defaults.Interval > 0 && time.Duration(defaults.Interval) < time.Second) {
+		report(line, "interval must be at least 1s")
+	}
+	if defaults.Timeout < 0 {
+		report(line, "timeout must not be negative")
+	}
+	if defaults.Interval > 0 && defaults.Timeout > defaults.Interval {
+		report(line, "timeout must not exceed the interval")
+	}
+	for _, code := range defaults.ExpectedStatus {
+		if code < 100 || code > 599 {
+			report(line, "expected status %d is not a valid HTTP status code", code)
+		}
+	}
+	if defaults.SLO < 0 || defaults.SLO >= 100 {
+		report(line, "slo must be a percentage below 100")
+	}
+	if defaults.FailureThreshold < 0 || defaults.SuccessThreshold < 0 {
+		report(line, "thresholds must not be negative")
+	}
+}
+
+func (f *File) definitionKey(d Definition) string {
+	if d.Name != "" {
+		return d.Name
+	}
+	return d.address() + d.Path
+}
+
+func (d Definition) address() string {
+	if d.Port == 0 {
+		return d.Host
+	}
+	if _, _, err := net.SplitHostPort(d.Host); err == nil {
+		return d.Host
+	}
+	return net.JoinHostPort(d.Host, strconv.Itoa(d.Port))
+}
+
+func (f *File) group(name string) Group {
+	for _, group := range f.Groups {
+		if group.Name == name {
+			return group
+		}
+	}
+	return Group{}
+}
+
+// Resolve resolves the definitions against their group and file defaults.
+func (f *File) Resolve() []scheduler.Monitor {
+	monitors := make([]scheduler.Monitor, 0, len(f.Monitors))
+	for _, d := range f.Monitors {
+		group := f.group(d.Group)
+		monitors = append(monitors, scheduler.Monitor{
+			Name:             f.definitionKey(d),
+			Type:             d.Type,
+			Host:             d.address(),
+			Path:             d.Path,
+			Group:            d.Group,
+			Tags:             d.Tags,
+			Interval:         time.Duration(firstNonZero(d.Interval, group.Interval, f.Defaults.Interval)),
+			Timeout:          time.Duration(firstNonZero(d.Timeout, group.Timeout, f.Defaults.Timeout)),
+			ExpectedStatus:   firstNonEmpty(d.ExpectedStatus, group.ExpectedStatus, f.Defaults.ExpectedStatus),
+			SLOTarget:        firstNonZero(d.SLO, group.SLO, f.Defaults.SLO),
+			FailureThreshold: firstNonZero(d.FailureThreshold, group.FailureThreshold, f.Defaults.FailureThreshold),
+			SuccessThreshold: firstNonZero(d.SuccessThreshold, group.SuccessThreshold, f.Defaults.SuccessThreshold),
+		})
+	}
+	return monitors
+}
+
+func firstNonZero[T Duration | float64 | int](values ...T) T {
+	for _, value := range values {
+		if value != 0 {
+			return value
+		}
+	}
+	return 0
+}
+
+func firstNonEmpty(values ...[]int) []int {
+	for _, value := range values {
+		if len(value) > 0 {
+			return value
+		}
+	}
+	return nil
+}
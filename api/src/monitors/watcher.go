@@ -0,0 +1,130 @@
+package monitors
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"iammati/statuspage/scheduler"
+)
+
+const DefaultPollInterval = 5 * time.Second
+
+// Watcher keeps a monitor registry in sync with a monitors file. The file is
+// reloaded on SIGHUP and whenever its modification time changes. Monitors
+// registered by other means (e.g. discovered ones) are left untouched.
+type Watcher struct {
+	path     string
+	registry scheduler.Registry
+
+	mu       sync.Mutex
+	file     *File
+	modTime  time.Time
+	applied  map[string]bool
+	onReload []func(*File)
+}
+
+func NewWatcher(path string, registry scheduler.Registry) *Watcher {
+	return &Watcher{
+		path:     path,
+		registry: registry,
+		applied:  make(map[string]bool),
+	}
+}
+
+// OnReload registers a callback that is run with every successfully
+// loaded file, e.g. to sync the components it defines.
+func (w *Watcher) OnReload(callback func(*File)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onReload = append(w.onReload, callback)
+}
+
+// File returns the last successfully loaded monitors file.
+func (w *Watcher) File() *File {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file
+}
+
+// Reload parses the monitors file and applies it to the registry. An
+// invalid file leaves the previously loaded monitors running.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if info, err := os.Stat(w.path); err == nil {
+		w.modTime = info.ModTime()
+	}
+
+	file, err := Load(w.path)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for _, monitor := range file.Resolve() {
+		w.registry.Upsert(monitor)
+		seen[monitor.Key()] = true
+	}
+
+	// Only unschedule monitors that disappeared from the file; the state
+	// of the others is kept across reloads.
+	for key := range w.applied {
+		if !seen[key] {
+			w.registry.Remove(key)
+		}
+	}
+
+	w.applied = seen
+	w.file = file
+	slog.Info("Loaded monitors", "count", len(seen), "path", w.path)
+
+	for _, callback := range w.onReload {
+		callback(file)
+	}
+
+	return nil
+}
+
+func (w *Watcher) changed() bool {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !info.ModTime().Equal(w.modTime)
+}
+
+// Run reloads the file on SIGHUP or change until quit is closed.
+func (w *Watcher) Run(pollInterval time.Duration, quit <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-quit:
+			return
+		case <-hup:
+			slog.Info("Received SIGHUP, reloading monitors", "path", w.path)
+		case <-ticker.C:
+			if !w.changed() {
+				continue
+			}
+			slog.Info("Detected change, reloading monitors", "path", w.path)
+		}
+
+		if err := w.Reload(); err != nil {
+			slog.Error("Keeping previous monitors", "path", w.path, "error", err)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"iammati/statuspage/config"
+)
+
+const chatUsername = "Status page"
+
+// Field is a labelled value shown in a chat message.
+type Field struct {
+	Name  string
+	Value string
+	// Short fields may be laid out side by side.
+	Short bool
+}
+
+// Summary is the platform-independent content of a chat message.
+type Summary struct {
+	Title  string
+	Text   string
+	Color  string
+	URL    string
+	Fields []Field
+	At     time.Time
+}
+
+// Summarize picks what is worth showing of an event in a chat message.
+func Summarize(event Event) Summary {
+	message := NewMessage(event)
+	summary := Summary{Title: message.Headline, Color: message.Color, URL: event.URL, At: event.At}
+	if summary.At.IsZero() {
+		summary.At = time.Now()
+	}
+
+	if monitor := event.Monitor; monitor != nil {
+		summary.Text = fmt.Sprintf("Since %s", summary.At.Format("2006-01-02 15:04:05 MST"))
+		summary.Fields = append(summary.Fields, Field{Name: "Monitor", Value: monitor.Name, Short: true})
+		if monitor.Host != "" {
+			summary.Fields = append(summary.Fields, Field{Name: "Host", Value: monitor.Host, Short: true})
+		}
+		if monitor.Group != "" {
+			summary.Fields = append(summary.Fields, Field{Name: "Group", Value: monitor.Group, Short: true})
+		}
+		if probe := monitor.Probe; probe != nil {
+			if probe.StatusCode != 0 {
+				summary.Fields = append(summary.Fields, Field{Name: "Status code", Value: strconv.Itoa(probe.StatusCode), Short: true})
+			}
+			summary.Fields = append(summary.Fields,
+				Field{Name: "DNS", Value: probe.DNSResolution.String(), Short: true},
+				Field{Name: "TCP", Value: probe.TCPConnection.String(), Short: true},
+				Field{Name: "TLS", Value: probe.TLSConnection.String(), Short: true},
+				Field{Name: "HTTP", Value: probe.HTTP.String(), Short: true},
+			)
+			if probe.Error != "" {
+				summary.Fields = append(summary.Fields, Field{Name: "Error", Value: probe.Error})
+			}
+		}
+	}
+
+	if incident := event.Incident; incident != nil {
+		if len(incident.Updates) > 0 {
+			summary.Text = incident.Updates[len(incident.Updates)-1].Message
+		}
+		summary.Fields = append(summary.Fields,
+			Field{Name: "Status", Value: incident.Status, Short: true},
+			Field{Name: "Severity", Value: incident.Severity, Short: true},
+		)
+		if len(incident.Monitors) > 0 {
+			summary.Fields = append(summary.Fields, Field{Name: "Affected", Value: strings.Join(incident.Monitors, ", ")})
+		}
+	}
+
+	if summary.Text == "" {
+		summary.Text = event.Message
+	}
+	return summary
+}
+
+// Chat posts events to the incoming webhook of a chat platform, rendered in
+// the platform's format: slack, mattermost, discord or teams.
+type Chat struct {
+	name   string
+	format string
+	url    string
+	client *http.Client
+}
+
+func NewChat(name string, format string, url string) *Chat {
+	return &Chat{name: name, format: format, url: url, client: &http.Client{}}
+}
+
+func (c *Chat) Name() string {
+	return c.name
+}
+
+func (c *Chat) Type() string {
+	return c.format
+}
+
+func (c *Chat) Send(ctx context.Context, event Event) error {
+	payload, err := ChatPayload(c.format, Summarize(event))
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return &PermanentError{Err: fmt.Errorf("failed to encode message: %v", err)}
+	}
+	return postJSON(ctx, c.client, c.url, body, nil)
+}
+
+// ChatPayload renders a summary in the incoming-webhook format of a platform.
+func ChatPayload(format string, summary Summary) (interface{}, error) {
+	switch format {
+	case config.ChannelSlack:
+		return slackPayload(summary, false), nil
+	case config.ChannelMattermost:
+		return slackPayload(summary, true), nil
+	case config.ChannelDiscord:
+		return discordPayload(summary), nil
+	case config.ChannelTeams:
+		return teamsPayload(summary), nil
+	default:
+		return nil, fmt.Errorf("unknown chat format '%s'", format)
+	}
+}
+
+// slackPayload uses message attachments, which Mattermost understands as
+// well; Mattermost additionally takes the username from the payload.
+func slackPayload(summary Summary, mattermost bool) map[string]interface{} {
+	fields := make([]map[string]interface{}, 0, len(summary.Fields))
+	for _, field := range summary.Fields {
+		fields = append(fields, map[string]interface{}{"title": field.Name, "value": field.Value, "short": field.Short})
+	}
+	attachment := map[string]interface{}{
+		"fallback": summary.Title,
+		"color":    summary.Color,
+		"title":    summary.Title,
+		"text":     summary.Text,
+		"fields":   fields,
+		"footer":   chatUsername,
+		"ts":       summary.At.Unix(),
+	}
+	if summary.URL != "" {
+		attachment["title_link"] = summary.URL
+	}
+
+	payload := map[string]interface{}{
+		"text":        summary.Title,
+		"attachments": []interface{}{attachment},
+	}
+	if mattermost {
+		payload["username"] = chatUsername
+	}
+	return payload
+}
+
+func discordPayload(summary Summary) map[string]interface{} {
+	fields := make([]map[string]interface{}, 0, len(summary.Fields))
+	for _, field := range summary.Fields {
+		fields = append(fields, map[string]interface{}{"name": field.Name, "value": field.Value, "inline": field.Short})
+	}
+	color, _ := strconv.ParseInt(strings.TrimPrefix(summary.Color, "#"), 16, 32)
+	embed := map[string]interface{}{
+		"title":       summary.Title,
+		"description": summary.Text,
+		"color":       color,
+		"fields":      fields,
+		"timestamp":   summary.At.UTC().Format(time.RFC3339),
+		"footer":      map[string]interface{}{"text": chatUsername},
+	}
+	if summary.URL != "" {
+		embed["url"] = summary.URL
+	}
+	return map[string]interface{}{
+		"username": chatUsername,
+		"embeds":   []interface{}{embed},
+	}
+}
+
+// teamsPayload wraps an Adaptive Card the way Teams workflow webhooks
+// expect. Cards only know a few named colors.
+func teamsPayload(summary Summary) map[string]interface{} {
+	color := "Default"
+	switch summary.Color {
+	case ColorDown, ColorCritical:
+		color = "Attention"
+	case ColorWarning:
+		color = "Warning"
+	case ColorUp:
+		color = "Good"
+	}
+
+	facts := make([]map[string]interface{}, 0, len(summary.Fields))
+	for _, field := range summary.Fields {
+		facts = append(facts, map[string]interface{}{"title": field.Name, "value": field.Value})
+	}
+	body := []interface{}{
+		map[string]interface{}{"type": "TextBlock", "text": summary.Title, "weight": "Bolder", "size": "Medium", "color": color, "wrap": true},
+	}
+	if summary.Text != "" {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": summary.Text, "wrap": true})
+	}
+	if len(facts) > 0 {
+		body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	if summary.URL != "" {
+		card["actions"] = []interface{}{
+			map[string]interface{}{"type": "Action.OpenUrl", "title": "View incident", "url": summary.URL},
+		}
+	}
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{
+			map[string]interface{}{"contentType": "application/vnd.microsoft.card.adaptive", "content": card},
+		},
+	}
+}
@@ -0,0 +1,216 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/utils"
+)
+
+// Email sends events as multipart text/HTML mails through an SMTP server.
+type Email struct {
+	name      string
+	to        []string
+	smtp      config.SMTPSettings
+	templates *Templates
+	// TLSConfig overrides the TLS configuration, which trusts the
+	// certificates in CA_CERT_DIR by default.
+	TLSConfig *tls.Config
+}
+
+func NewEmail(name string, to []string, settings config.SMTPSettings, templates *Templates) *Email {
+	return &Email{name: name, to: to, smtp: settings, templates: templates}
+}
+
+func (e *Email) Name() string {
+	return e.name
+}
+
+func (e *Email) Type() string {
+	return config.ChannelEmail
+}
+
+func (e *Email) Send(ctx context.Context, event Event) error {
+	mail, err := e.render(NewMessage(event))
+	if err != nil {
+		return &PermanentError{Err: fmt.Errorf("failed to render email: %v", err)}
+	}
+	return e.deliver(ctx, mail)
+}
+
+// render builds the complete message including its headers.
+func (e *Email) render(message Message) ([]byte, error) {
+	subject, err := e.templates.Subject(message)
+	if err != nil {
+		return nil, err
+	}
+	text, err := e.templates.Text(message)
+	if err != nil {
+		return nil, err
+	}
+	html, err := e.templates.HTML(message)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", text},
+		{"text/html; charset=UTF-8", html},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoder := quotedprintable.NewWriter(writer)
+		if _, err := encoder.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var mail bytes.Buffer
+	headers := [][2]string{
+		{"From", e.smtp.From},
+		{"To", strings.Join(e.to, ", ")},
+		{"Subject", mime.QEncoding.Encode("UTF-8", subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", messageID(e.smtp.From)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + parts.Boundary()},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&mail, "%s: %s\r\n", header[0], header[1])
+	}
+	mail.WriteString("\r\n")
+	mail.Write(body.Bytes())
+	return mail.Bytes(), nil
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = strings.Trim(from[at+1:], "> ")
+	}
+	random := make([]byte, 12)
+	rand.Read(random)
+	return "<" + hex.EncodeToString(random) + "@" + domain + ">"
+}
+
+// deliver talks SMTP to the configured server. Rejections by the server
+// (5xx replies) are permanent.
+func (e *Email) deliver(ctx context.Context, mail []byte) error {
+	address := net.JoinHostPort(e.smtp.Host, strconv.Itoa(e.smtp.Port))
+	tlsConfig := e.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+		if pool, err := utils.LoadCertsFromDir(config.AppSettings.CACertDir); err == nil {
+			tlsConfig.RootCAs = pool
+		}
+	}
+	tlsConfig = tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = e.smtp.Host
+	}
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", address, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if e.smtp.TLS == config.SMTPTLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, e.smtp.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to greet %s: %v", address, err)
+	}
+	defer client.Close()
+
+	if e.smtp.TLS == config.SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return &PermanentError{Err: fmt.Errorf("%s does not support STARTTLS", address)}
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS failed: %v", err)
+		}
+	}
+
+	if e.smtp.Username != "" {
+		auth := smtp.PlainAuth("", e.smtp.Username, e.smtp.Password, e.smtp.Host)
+		if err := client.Auth(auth); err != nil {
+			return smtpError("authentication failed", err)
+		}
+	}
+
+	if err := client.Mail(envelopeAddress(e.smtp.From)); err != nil {
+		return smtpError("MAIL FROM rejected", err)
+	}
+	for _, recipient := range e.to {
+		if err := client.Rcpt(envelopeAddress(recipient)); err != nil {
+			return smtpError("RCPT TO "+recipient+" rejected", err)
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return smtpError("DATA rejected", err)
+	}
+	if _, err := writer.Write(mail); err != nil {
+		return fmt.Errorf("failed to send message: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return smtpError("message rejected", err)
+	}
+	// The server accepted the message, retrying would deliver it twice.
+	if err := client.Quit(); err != nil {
+		slog.Warn("SMTP QUIT failed after the message was accepted", "channel", e.name, "error", err)
+	}
+	return nil
+}
+
+// envelopeAddress strips the display name from "Name <address>".
+func envelopeAddress(address string) string {
+	if start := strings.LastIndex(address, "<"); start >= 0 {
+		return strings.TrimSuffix(address[start+1:], ">")
+	}
+	return strings.TrimSpace(address)
+}
+
+func smtpError(message string, err error) error {
+	wrapped := fmt.Errorf("%s: %w", message, err)
+	var protocolErr *textproto.Error
+	if errors.As(err, &protocolErr) && protocolErr.Code >= 500 {
+		return &PermanentError{Err: wrapped}
+	}
+	return wrapped
+}
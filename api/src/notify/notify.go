@@ -0,0 +1,449 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/db"
+	"iammati/statuspage/incidents"
+	"iammati/statuspage/store"
+	"iammati/statuspage/utils"
+)
+
+// Types of the events sent to notification channels.
+const (
+	EventMonitorDown      = "monitor.down"
+	EventMonitorUp        = "monitor.up"
+	EventMonitorFlapping  = "monitor.flapping"
+	EventIncidentOpened   = "incident.opened"
+	EventIncidentUpdated  = "incident.updated"
+	EventIncidentResolved = "incident.resolved"
+	// EventTest is only sent through the test-send endpoint.
+	EventTest = "test"
+)
+
+var Events = []string{
+	EventMonitorDown,
+	EventMonitorUp,
+	EventMonitorFlapping,
+	EventIncidentOpened,
+	EventIncidentUpdated,
+	EventIncidentResolved,
+	EventTest,
+}
+
+// maxBackoff caps the delay between two delivery attempts.
+const maxBackoff = 5 * time.Minute
+
+// Event is what channels are notified about: either a monitor changing state
+// or an incident changing.
+type Event struct {
+	Type     string              `json:"type"`
+	At       time.Time           `json:"at"`
+	Monitor  *Monitor            `json:"monitor,omitempty"`
+	Incident *incidents.Incident `json:"incident,omitempty"`
+	Message  string              `json:"message,omitempty"`
+	// Severity of the outage, used for routing; incident events default to
+	// the severity of their incident.
+	Severity string `json:"severity,omitempty"`
+	// URL links to the incident the event belongs to, if any.
+	URL string `json:"url,omitempty"`
+	// IncidentID is the incident a monitor event belongs to, if any.
+	IncidentID int64 `json:"incidentId,omitempty"`
+}
+
+// IncidentURL expands the {id} placeholder of a configured incident link.
+func IncidentURL(template string, id int64) string {
+	if template == "" || id == 0 {
+		return ""
+	}
+	return strings.ReplaceAll(template, "{id}", strconv.FormatInt(id, 10))
+}
+
+// Monitor is the state of a monitor right after a transition.
+type Monitor struct {
+	Name     string   `json:"name"`
+	Host     string   `json:"host,omitempty"`
+	Group    string   `json:"group,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Up       bool     `json:"up"`
+	Flapping bool     `json:"flapping"`
+	ProbeIDs []int64  `json:"probeIds,omitempty"`
+	// Probe is the probe that completed the transition, if any.
+	Probe *Probe `json:"probe,omitempty"`
+}
+
+// Probe holds the metrics of a single probe. Timings are encoded as
+// milliseconds.
+type Probe struct {
+	DNSResolution time.Duration
+	TCPConnection time.Duration
+	TLSConnection time.Duration
+	HTTP          time.Duration
+	StatusCode    int
+	Error         string
+}
+
+func ProbeFromMetrics(metrics utils.Metrics) *Probe {
+	probe := &Probe{
+		DNSResolution: metrics.DnsResolutionTime,
+		TCPConnection: metrics.TcpConnectionTime,
+		TLSConnection: metrics.TlsConnectionTime,
+		HTTP:          metrics.HttpTime,
+		StatusCode:    metrics.StatusCode,
+	}
+	if metrics.Error != nil {
+		probe.Error = metrics.Error.Error()
+	}
+	return probe
+}
+
+func (p Probe) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		DNSResolution float64 `json:"dnsResolutionMs"`
+		TCPConnection float64 `json:"tcpConnectionMs"`
+		TLSConnection float64 `json:"tlsConnectionMs"`
+		HTTP          float64 `json:"httpMs"`
+		StatusCode    int     `json:"statusCode,omitempty"`
+		Error         string  `json:"error,omitempty"`
+	}{
+		DNSResolution: milliseconds(p.DNSResolution),
+		TCPConnection: milliseconds(p.TCPConnection),
+		TLSConnection: milliseconds(p.TLSConnection),
+		HTTP:          milliseconds(p.HTTP),
+		StatusCode:    p.StatusCode,
+		Error:         p.Error,
+	})
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// MonitorEventType maps the state of a monitor to an event type.
+func MonitorEventType(up bool, flapping bool) string {
+	switch {
+	case flapping:
+		return EventMonitorFlapping
+	case up:
+		return EventMonitorUp
+	default:
+		return EventMonitorDown
+	}
+}
+
+// IncidentEventType maps the events published by the incident manager.
+func IncidentEventType(event string) string {
+	switch event {
+	case incidents.EventOpened:
+		return EventIncidentOpened
+	case incidents.EventResolved:
+		return EventIncidentResolved
+	default:
+		return EventIncidentUpdated
+	}
+}
+
+// Channel delivers events to a single destination.
+type Channel interface {
+	Name() string
+	Type() string
+	Send(ctx context.Context, event Event) error
+}
+
+// PermanentError marks a failure retrying won't fix, e.g. a rejected request.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is returned for unexpected HTTP responses.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("unexpected status code %d", e.Code)
+	}
+	return fmt.Sprintf("unexpected status code %d: %s", e.Code, e.Body)
+}
+
+type Options struct {
+	// Retries is how often a failed delivery is retried.
+	Retries int
+	// Backoff is the delay before the first retry; it doubles on every one.
+	Backoff time.Duration
+	// Timeout bounds a single delivery attempt.
+	Timeout time.Duration
+}
+
+type subscription struct {
+	channel Channel
+	events  []string
+}
+
+// Dispatcher fans events out to the subscribed channels, retries failed
+// deliveries with exponential backoff and records them in the delivery log.
+type Dispatcher struct {
+	store   store.DeliveryStore
+	options Options
+
+	mu            sync.RWMutex
+	subscriptions []subscription
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewDispatcher(deliveries store.DeliveryStore, options Options) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{store: deliveries, options: options, ctx: ctx, cancel: cancel}
+}
+
+// FromSettings builds a dispatcher with the channels declared in the settings.
+func FromSettings(deliveries store.DeliveryStore, settings config.NotificationSettings) (*Dispatcher, error) {
+	d := NewDispatcher(deliveries, Options{
+		Retries: settings.Retries,
+		Backoff: settings.RetryBackoff,
+		Timeout: settings.Timeout,
+	})
+	templates, err := LoadTemplates(settings.TemplatesDir)
+	if err != nil {
+		return nil, fmt.Errorf("loading templates: %v", err)
+	}
+	for _, channel := range settings.Channels {
+		for _, event := range channel.Events {
+			if !slices.Contains(Events, event) {
+				return nil, fmt.Errorf("channel '%s': unknown event '%s'", channel.Name, event)
+			}
+		}
+		switch channel.Type {
+		case config.ChannelWebhook:
+			d.Add(NewWebhook(channel.Name, channel.URL, channel.Secret), channel.Events...)
+		case config.ChannelEmail:
+			d.Add(NewEmail(channel.Name, channel.To, settings.SMTP, templates), channel.Events...)
+		case config.ChannelSlack, config.ChannelMattermost, config.ChannelDiscord, config.ChannelTeams:
+			d.Add(NewChat(channel.Name, channel.Type, channel.URL), channel.Events...)
+		default:
+			return nil, fmt.Errorf("channel '%s': unknown type '%s'", channel.Name, channel.Type)
+		}
+	}
+	return d, nil
+}
+
+// Add subscribes a channel to the given event types, or to all of them.
+func (d *Dispatcher) Add(channel Channel, events ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.subscriptions = append(d.subscriptions, subscription{channel: channel, events: events})
+}
+
+// Channels returns the channels in the order they were added.
+func (d *Dispatcher) Channels() []Channel {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	channels := make([]Channel, 0, len(d.subscriptions))
+	for _, subscription := range d.subscriptions {
+		channels = append(channels, subscription.channel)
+	}
+	return channels
+}
+
+// Events returns the event types a channel is subscribed to, nil for all.
+func (d *Dispatcher) Events(name string) []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, subscription := range d.subscriptions {
+		if subscription.channel.Name() == name {
+			return slices.Clone(subscription.events)
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) Channel(name string) (Channel, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, subscription := range d.subscriptions {
+		if subscription.channel.Name() == name {
+			return subscription.channel, true
+		}
+	}
+	return nil, false
+}
+
+// Dispatch delivers the event to every subscribed channel in the background.
+func (d *Dispatcher) Dispatch(event Event) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, subscription := range d.subscriptions {
+		if len(subscription.events) > 0 && !slices.Contains(subscription.events, event.Type) {
+			continue
+		}
+		d.deliverAsync(subscription.channel, event)
+	}
+}
+
+// DispatchTo delivers the event in the background to the named channels
+// that are subscribed to it.
+func (d *Dispatcher) DispatchTo(event Event, channels ...string) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, subscription := range d.subscriptions {
+		if !slices.Contains(channels, subscription.channel.Name()) {
+			continue
+		}
+		if len(subscription.events) > 0 && !slices.Contains(subscription.events, event.Type) {
+			continue
+		}
+		d.deliverAsync(subscription.channel, event)
+	}
+}
+
+func (d *Dispatcher) deliverAsync(channel Channel, event Event) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		if _, err := d.Deliver(channel, event, d.options.Retries); err != nil {
+			slog.Error("Failed to notify channel", "channel", channel.Name(), "event", event.Type, "error", err)
+		}
+	}()
+}
+
+// Deliver sends the event to a channel, retrying up to the given number of
+// times, and returns the resulting entry of the delivery log.
+func (d *Dispatcher) Deliver(channel Channel, event Event, retries int) (db.NotificationDelivery, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return db.NotificationDelivery{}, fmt.Errorf("failed to encode event: %v", err)
+	}
+
+	now := time.Now()
+	delivery := db.NotificationDelivery{
+		Channel:   channel.Name(),
+		Event:     event.Type,
+		Payload:   string(payload),
+		Status:    db.DeliveryPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	delivery.ID, err = d.store.CreateNotificationDelivery(delivery)
+	if err != nil {
+		slog.Error("Failed to record notification delivery", "channel", delivery.Channel, "error", err)
+	}
+
+	backoff := d.options.Backoff
+	for {
+		err = d.attempt(channel, event, delivery.ID)
+		delivery.Attempts++
+		delivery.ResponseCode, delivery.LastError = 0, ""
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			delivery.ResponseCode = statusErr.Code
+		}
+
+		var permanent *PermanentError
+		switch {
+		case err == nil:
+			delivery.Status = db.DeliveryDelivered
+		case errors.As(err, &permanent) || delivery.Attempts > retries:
+			delivery.Status, delivery.LastError = db.DeliveryFailed, err.Error()
+		default:
+			delivery.LastError = err.Error()
+		}
+		d.record(delivery)
+
+		if delivery.Status != db.DeliveryPending {
+			return delivery, err
+		}
+		if !d.sleep(backoff) {
+			delivery.Status = db.DeliveryFailed
+			d.record(delivery)
+			return delivery, err
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (d *Dispatcher) record(delivery db.NotificationDelivery) {
+	if delivery.ID == 0 {
+		return
+	}
+	delivery.UpdatedAt = time.Now()
+	if err := d.store.UpdateNotificationDelivery(delivery); err != nil {
+		slog.Error("Failed to record notification delivery", "delivery", delivery.ID, "error", err)
+	}
+}
+
+func (d *Dispatcher) attempt(channel Channel, event Event, deliveryID int64) error {
+	ctx := d.ctx
+	if d.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.options.Timeout)
+		defer cancel()
+	}
+	return channel.Send(withDeliveryID(ctx, deliveryID), event)
+}
+
+// sleep waits before the next retry and reports false once the dispatcher
+// is stopped.
+func (d *Dispatcher) sleep(duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-d.ctx.Done():
+		return false
+	}
+}
+
+// Stop abandons pending retries and waits for running deliveries.
+func (d *Dispatcher) Stop() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// Wait blocks until every dispatched event is delivered or has failed.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+type deliveryIDKey struct{}
+
+func withDeliveryID(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, deliveryIDKey{}, id)
+}
+
+// DeliveryID returns the delivery log entry a Send call belongs to, 0 if
+// the delivery wasn't recorded.
+func DeliveryID(ctx context.Context) int64 {
+	id, _ := ctx.Value(deliveryIDKey{}).(int64)
+	return id
+}
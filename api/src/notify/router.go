@@ -0,0 +1,333 @@
+package notify
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/db"
+)
+
+var ErrAlertNotFound = errors.New("alert not found")
+
+// Alert is an outage being notified about. It is opened by the first event
+// of its dedupe key, e.g. a monitor going down, swallows further events of
+// the same kind and is closed again once the outage is over.
+type Alert struct {
+	ID    int64
+	Key   string
+	Route string
+	// Event opened the alert; escalations and repeats resend it.
+	Event          Event
+	OpenedAt       time.Time
+	AcknowledgedAt time.Time
+	NotifiedAt     time.Time
+	// Channels have been notified so far.
+	Channels []string
+	// Escalations is the number of escalation steps taken.
+	Escalations int
+	Repeats     int
+	// Suppressed counts the deduplicated events.
+	Suppressed int
+}
+
+func (a Alert) Acknowledged() bool {
+	return !a.AcknowledgedAt.IsZero()
+}
+
+// DedupeKey identifies the outage an event belongs to: a monitor or an
+// incident. Monitor events of an incident share its key, so an outage opens
+// a single alert. Other events aren't deduplicated.
+func DedupeKey(event Event) string {
+	switch {
+	case event.Monitor != nil && event.IncidentID != 0:
+		return "incident/" + strconv.FormatInt(event.IncidentID, 10)
+	case event.Monitor != nil:
+		return "monitor/" + event.Monitor.Name
+	case event.Incident != nil:
+		return "incident/" + strconv.FormatInt(event.Incident.ID, 10)
+	default:
+		return ""
+	}
+}
+
+// opens reports whether an event starts an outage, closes whether it ends one.
+// The outage of an incident only ends once the incident is resolved, not
+// when one of its monitors recovers.
+func opens(eventType string) bool {
+	return eventType == EventMonitorDown || eventType == EventMonitorFlapping || eventType == EventIncidentOpened
+}
+
+func closes(event Event) bool {
+	return event.Type == EventIncidentResolved || (event.Type == EventMonitorUp && event.IncidentID == 0)
+}
+
+// Router sends events to channels according to the configured routes,
+// deduplicates them per monitor and incident and escalates and repeats
+// unacknowledged alerts. Without routes, it hands every event to the
+// dispatcher as is. Alerts are only kept in memory.
+type Router struct {
+	dispatcher *Dispatcher
+	routes     []config.RouteSettings
+	// Lookup returns the group and tags of a monitor, used to route
+	// incident events by their affected monitors. Optional.
+	Lookup func(monitor string) (group string, tags []string)
+	// Now defaults to time.Now.
+	Now func() time.Time
+
+	mu     sync.Mutex
+	alerts map[string][]*Alert
+	nextID int64
+}
+
+func NewRouter(dispatcher *Dispatcher, routes []config.RouteSettings) *Router {
+	return &Router{dispatcher: dispatcher, routes: routes, alerts: make(map[string][]*Alert)}
+}
+
+func (r *Router) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// Handle routes a single event.
+func (r *Router) Handle(event Event) {
+	if len(r.routes) == 0 {
+		r.dispatcher.Dispatch(event)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := DedupeKey(event)
+	alerts := r.alerts[key]
+	if key == "" || len(alerts) == 0 {
+		r.route(event, key)
+		return
+	}
+
+	switch {
+	case opens(event.Type):
+		for _, alert := range alerts {
+			alert.Suppressed++
+		}
+	case closes(event):
+		r.dispatcher.DispatchTo(event, notifiedChannels(alerts)...)
+		delete(r.alerts, key)
+	default:
+		r.dispatcher.DispatchTo(event, notifiedChannels(alerts)...)
+		if event.Incident != nil && event.Incident.Status == db.IncidentAcknowledged {
+			r.acknowledgeIncident(event)
+		}
+	}
+}
+
+// route sends an event without an open alert to the immediate channels of
+// the matching routes, opening an alert per route for outages.
+func (r *Router) route(event Event, key string) {
+	now := r.now()
+	for _, route := range r.routes {
+		if !r.matches(route.Match, event) {
+			continue
+		}
+		if len(route.Channels) > 0 {
+			r.dispatcher.DispatchTo(event, route.Channels...)
+		}
+		if key != "" && opens(event.Type) {
+			r.nextID++
+			r.alerts[key] = append(r.alerts[key], &Alert{
+				ID:         r.nextID,
+				Key:        key,
+				Route:      route.Name,
+				Event:      event,
+				OpenedAt:   now,
+				NotifiedAt: now,
+				Channels:   slices.Clone(route.Channels),
+			})
+		}
+		if !route.Continue {
+			return
+		}
+	}
+}
+
+// acknowledgeIncident stops escalating an incident and the monitors it affects.
+func (r *Router) acknowledgeIncident(event Event) {
+	now := r.now()
+	keys := []string{DedupeKey(event)}
+	for _, monitor := range event.Incident.Monitors {
+		keys = append(keys, "monitor/"+monitor)
+	}
+	for _, key := range keys {
+		for _, alert := range r.alerts[key] {
+			if !alert.Acknowledged() {
+				alert.AcknowledgedAt = now
+			}
+		}
+	}
+}
+
+func (r *Router) matches(match config.RouteMatch, event Event) bool {
+	if len(match.Events) > 0 && !slices.Contains(match.Events, event.Type) {
+		return false
+	}
+	if len(match.Severities) > 0 && !slices.Contains(match.Severities, eventSeverity(event)) {
+		return false
+	}
+	if len(match.Groups) == 0 && len(match.Tags) == 0 {
+		return true
+	}
+
+	groups, tags := r.labels(event)
+	if len(match.Groups) > 0 && !slices.ContainsFunc(groups, func(group string) bool { return slices.Contains(match.Groups, group) }) {
+		return false
+	}
+	if len(match.Tags) > 0 && !slices.ContainsFunc(tags, func(tag string) bool { return slices.Contains(match.Tags, tag) }) {
+		return false
+	}
+	return true
+}
+
+func eventSeverity(event Event) string {
+	if event.Severity == "" && event.Incident != nil {
+		return event.Incident.Severity
+	}
+	return event.Severity
+}
+
+// labels returns the groups and tags of the monitors an event is about.
+func (r *Router) labels(event Event) (groups []string, tags []string) {
+	if monitor := event.Monitor; monitor != nil {
+		if monitor.Group != "" {
+			groups = append(groups, monitor.Group)
+		}
+		return groups, monitor.Tags
+	}
+	if event.Incident != nil && r.Lookup != nil {
+		for _, monitor := range event.Incident.Monitors {
+			group, monitorTags := r.Lookup(monitor)
+			if group != "" {
+				groups = append(groups, group)
+			}
+			tags = append(tags, monitorTags...)
+		}
+	}
+	return groups, tags
+}
+
+func (r *Router) findRoute(name string) (config.RouteSettings, bool) {
+	for _, route := range r.routes {
+		if route.Name == name {
+			return route, true
+		}
+	}
+	return config.RouteSettings{}, false
+}
+
+// Tick escalates and repeats the alerts that are due.
+func (r *Router) Tick() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	for _, alerts := range r.alerts {
+		for _, alert := range alerts {
+			route, ok := r.findRoute(alert.Route)
+			if !ok || alert.Acknowledged() {
+				continue
+			}
+
+			for alert.Escalations < len(route.Escalation) && now.Sub(alert.OpenedAt) >= route.Escalation[alert.Escalations].After {
+				var added []string
+				for _, channel := range route.Escalation[alert.Escalations].Channels {
+					if !slices.Contains(alert.Channels, channel) {
+						added = append(added, channel)
+					}
+				}
+				r.dispatcher.DispatchTo(alert.Event, added...)
+				alert.Channels = append(alert.Channels, added...)
+				alert.Escalations++
+				alert.NotifiedAt = now
+			}
+
+			if route.RepeatInterval > 0 && now.Sub(alert.NotifiedAt) >= route.RepeatInterval {
+				r.dispatcher.DispatchTo(alert.Event, alert.Channels...)
+				alert.Repeats++
+				alert.NotifiedAt = now
+			}
+		}
+	}
+}
+
+// Run calls Tick on every interval until stop is closed.
+func (r *Router) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.Tick()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Alerts returns the open alerts, oldest first.
+func (r *Router) Alerts() []Alert {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := []Alert{}
+	for _, alerts := range r.alerts {
+		for _, alert := range alerts {
+			copied := *alert
+			copied.Channels = slices.Clone(alert.Channels)
+			list = append(list, copied)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// Acknowledge stops escalating and repeating an alert.
+func (r *Router) Acknowledge(id int64) (Alert, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, alerts := range r.alerts {
+		for _, alert := range alerts {
+			if alert.ID != id {
+				continue
+			}
+			if !alert.Acknowledged() {
+				alert.AcknowledgedAt = r.now()
+			}
+			copied := *alert
+			copied.Channels = slices.Clone(alert.Channels)
+			return copied, nil
+		}
+	}
+	return Alert{}, ErrAlertNotFound
+}
+
+func notifiedChannels(alerts []*Alert) []string {
+	var channels []string
+	for _, alert := range alerts {
+		for _, channel := range alert.Channels {
+			if !slices.Contains(channels, channel) {
+				channels = append(channels, channel)
+			}
+		}
+	}
+	return channels
+}
@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"iammati/statuspage/db"
+	"iammati/statuspage/incidents"
+)
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// Colors of the messages, by how bad the news is.
+const (
+	ColorDown     = "#d92d20"
+	ColorWarning  = "#f79009"
+	ColorUp       = "#039855"
+	ColorNeutral  = "#475467"
+	ColorCritical = "#912018"
+)
+
+// Message is the data templates and chat formats render: the event plus a
+// short headline and a color matching it.
+type Message struct {
+	Event
+	Headline string
+	Color    string
+}
+
+func NewMessage(event Event) Message {
+	message := Message{Event: event, Headline: event.Message, Color: ColorNeutral}
+	switch {
+	case event.Monitor != nil:
+		monitor := event.Monitor
+		switch event.Type {
+		case EventMonitorFlapping:
+			message.Headline, message.Color = monitor.Name+" is flapping", ColorWarning
+		case EventMonitorUp:
+			message.Headline, message.Color = monitor.Name+" is up", ColorUp
+		default:
+			message.Headline, message.Color = monitor.Name+" is down", ColorDown
+		}
+	case event.Incident != nil:
+		incident := event.Incident
+		switch {
+		case incident.Status == db.IncidentResolved:
+			message.Headline, message.Color = "Resolved: "+incident.Title, ColorUp
+		case incident.Severity == db.SeverityCritical:
+			message.Headline, message.Color = incident.Title, ColorCritical
+		case incident.Severity == db.SeverityMinor:
+			message.Headline, message.Color = incident.Title, ColorWarning
+		default:
+			message.Headline, message.Color = incident.Title, ColorDown
+		}
+	}
+	if message.Headline == "" {
+		message.Headline = "Status page notification: " + event.Type
+	}
+	return message
+}
+
+var templateFuncs = map[string]interface{}{
+	"upper": strings.ToUpper,
+	"join":  strings.Join,
+	"latest": func(incident incidents.Incident) *incidents.Update {
+		if len(incident.Updates) == 0 {
+			return nil
+		}
+		return &incident.Updates[len(incident.Updates)-1]
+	},
+}
+
+// Templates render messages per event type. Every event type has a text
+// template, a "<type>.subject" and an HTML template named after it; types
+// without one fall back to "default".
+type Templates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// LoadTemplates parses the built-in templates and then the *.txt.tmpl and
+// *.html.tmpl files in dir, if set, which replace templates of the same name.
+func LoadTemplates(dir string) (*Templates, error) {
+	text, err := texttemplate.New("").Funcs(templateFuncs).ParseFS(builtinTemplates, "templates/*.txt.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	html, err := htmltemplate.New("").Funcs(templateFuncs).ParseFS(builtinTemplates, "templates/*.html.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	if dir != "" {
+		if text, err = parseOverrides(text, filepath.Join(dir, "*.txt.tmpl")); err != nil {
+			return nil, err
+		}
+		if html, err = parseOverrides(html, filepath.Join(dir, "*.html.tmpl")); err != nil {
+			return nil, err
+		}
+	}
+	return &Templates{text: text, html: html}, nil
+}
+
+func parseOverrides[T interface{ Parse(string) (T, error) }](templates T, pattern string) (T, error) {
+	matches, _ := filepath.Glob(pattern)
+	for _, path := range matches {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return templates, err
+		}
+		if templates, err = templates.Parse(string(content)); err != nil {
+			return templates, fmt.Errorf("parsing %s: %v", path, err)
+		}
+	}
+	return templates, nil
+}
+
+// Subject renders the single-line subject of a message.
+func (t *Templates) Subject(message Message) (string, error) {
+	var buf bytes.Buffer
+	if err := t.text.ExecuteTemplate(&buf, t.textName(message.Type+".subject", "default.subject"), message); err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(buf.String()), " "), nil
+}
+
+// Text renders the plain text body of a message.
+func (t *Templates) Text(message Message) (string, error) {
+	var buf bytes.Buffer
+	if err := t.text.ExecuteTemplate(&buf, t.textName(message.Type, "default"), message); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()) + "\n", nil
+}
+
+// HTML renders the HTML body of a message.
+func (t *Templates) HTML(message Message) (string, error) {
+	name := message.Type
+	if t.html.Lookup(name) == nil {
+		name = "default"
+	}
+	var buf bytes.Buffer
+	if err := t.html.ExecuteTemplate(&buf, name, message); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (t *Templates) textName(name string, fallback string) string {
+	if t.text.Lookup(name) == nil {
+		return fallback
+	}
+	return name
+}
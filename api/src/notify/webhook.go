@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"iammati/statuspage/config"
+)
+
+// Headers sent along with every webhook.
+const (
+	EventHeader     = "X-Statuspage-Event"
+	DeliveryHeader  = "X-Statuspage-Delivery"
+	TimestampHeader = "X-Statuspage-Timestamp"
+	SignatureHeader = "X-Statuspage-Signature"
+)
+
+// Webhook POSTs the event as JSON. With a secret, the request is signed so
+// receivers can verify it came from us; see Sign.
+type Webhook struct {
+	name   string
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewWebhook(name string, url string, secret string) *Webhook {
+	return &Webhook{name: name, url: url, secret: secret, client: &http.Client{}}
+}
+
+func (w *Webhook) Name() string {
+	return w.name
+}
+
+func (w *Webhook) Type() string {
+	return config.ChannelWebhook
+}
+
+func (w *Webhook) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return &PermanentError{Err: fmt.Errorf("failed to encode event: %v", err)}
+	}
+
+	headers := map[string]string{EventHeader: event.Type}
+	if id := DeliveryID(ctx); id != 0 {
+		headers[DeliveryHeader] = strconv.FormatInt(id, 10)
+	}
+	if w.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		headers[TimestampHeader] = timestamp
+		headers[SignatureHeader] = Sign(w.secret, timestamp, body)
+	}
+	return postJSON(ctx, w.client, w.url, body, headers)
+}
+
+// Sign returns the signature of a webhook: the hex encoded HMAC-SHA256 of
+// the timestamp, a dot and the body, prefixed with "sha256=".
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a received webhook in constant time.
+func Verify(secret string, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// postJSON sends the body and turns unexpected responses into a StatusError.
+// Client errors other than timeouts and rate limits are permanent.
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return &PermanentError{Err: fmt.Errorf("failed to create request: %v", err)}
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("User-Agent", "statuspage")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	statusErr := &StatusError{Code: resp.StatusCode, Body: strings.TrimSpace(string(excerpt))}
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return &PermanentError{Err: statusErr}
+	}
+	return statusErr
+}
@@ -0,0 +1,353 @@
+package scheduler
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"iammati/statuspage/utils"
+)
+
+const (
+	DefaultInterval = 30 * time.Second
+	DefaultTimeout  = 5 * time.Second
+	DefaultWorkers  = 8
+	DefaultJitter   = 0.1
+
+	DefaultFailureThreshold = 3
+	DefaultSuccessThreshold = 1
+)
+
+// Monitor types. An HTTP monitor requests its path, a certificate monitor
+// only completes a TLS handshake and checks the certificate of its host.
+const (
+	TypeHTTP        = "http"
+	TypeCertificate = "certificate"
+)
+
+// Monitor describes a single host that is probed on a fixed interval.
+type Monitor struct {
+	Name string
+	// Type is TypeHTTP if empty.
+	Type     string
+	Host     string
+	Path     string
+	Group    string
+	Tags     []string
+	Interval time.Duration
+	Timeout  time.Duration
+	// ExpectedStatus overrides the default 2xx/3xx check when set.
+	ExpectedStatus []int
+	// MaxResponseTime fails slower probes when set.
+	MaxResponseTime time.Duration
+	// SLOTarget is the availability objective in percent, 0 if unset.
+	SLOTarget float64
+	// FailureThreshold and SuccessThreshold are how many consecutive probes
+	// it takes to mark the monitor down or up again.
+	FailureThreshold int
+	SuccessThreshold int
+	// Component is the status page component the monitor joins, besides
+	// those listing it.
+	Component string
+}
+
+// Key returns the identifier the scheduler tracks the monitor under.
+func (m Monitor) Key() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	return m.Host + m.Path
+}
+
+// Equal reports whether two monitors share the same definition.
+func (m Monitor) Equal(other Monitor) bool {
+	return m.Name == other.Name &&
+		m.Type == other.Type &&
+		m.Host == other.Host &&
+		m.Path == other.Path &&
+		m.Group == other.Group &&
+		m.Interval == other.Interval &&
+		m.Timeout == other.Timeout &&
+		m.MaxResponseTime == other.MaxResponseTime &&
+		m.SLOTarget == other.SLOTarget &&
+		m.FailureThreshold == other.FailureThreshold &&
+		m.SuccessThreshold == other.SuccessThreshold &&
+		m.Component == other.Component &&
+		slices.Equal(m.Tags, other.Tags) &&
+		slices.Equal(m.ExpectedStatus, other.ExpectedStatus)
+}
+
+// WithDefaults fills in the type, interval, timeout and thresholds when they
+// are unset.
+func (m Monitor) WithDefaults() Monitor {
+	if m.Type == "" {
+		m.Type = TypeHTTP
+	}
+	if m.Interval <= 0 {
+		m.Interval = DefaultInterval
+	}
+	if m.Timeout <= 0 {
+		m.Timeout = DefaultTimeout
+	}
+	if m.FailureThreshold <= 0 {
+		m.FailureThreshold = DefaultFailureThreshold
+	}
+	if m.SuccessThreshold <= 0 {
+		m.SuccessThreshold = DefaultSuccessThreshold
+	}
+	return m
+}
+
+// Result is the outcome of a single probe run.
+type Result struct {
+	Monitor  Monitor
+	Metrics  utils.Metrics
+	Err      error
+	Started  time.Time
+	Finished time.Time
+}
+
+// Up reports whether the probe considers the monitored host reachable.
+func (r Result) Up() bool {
+	if r.Err != nil {
+		return false
+	}
+	if r.Monitor.MaxResponseTime > 0 && r.Metrics.ResponseTime() > r.Monitor.MaxResponseTime {
+		return false
+	}
+	if len(r.Monitor.ExpectedStatus) > 0 {
+		return slices.Contains(r.Monitor.ExpectedStatus, r.Metrics.StatusCode)
+	}
+	return r.Metrics.Reachable
+}
+
+type ResultHandler func(Result)
+
+// Registry is implemented by anything monitors can be registered with.
+type Registry interface {
+	Upsert(monitor Monitor)
+	Remove(key string)
+}
+
+type ProbeFunc func(host string, path string, timeout time.Duration) (utils.Metrics, error)
+
+type Options struct {
+	// Workers bounds how many probes may run at the same time.
+	Workers int
+	// Jitter is the fraction of the interval each tick is randomly shifted by.
+	Jitter  float64
+	Handler ResultHandler
+	// Probe defaults to utils.HostMetricsWithTimeout.
+	Probe ProbeFunc
+	// CertificateProbe runs certificate monitors and defaults to
+	// utils.CertificateMetrics.
+	CertificateProbe ProbeFunc
+}
+
+type entry struct {
+	monitor Monitor
+	stop    chan struct{}
+	running atomic.Bool
+}
+
+type Scheduler struct {
+	opts     Options
+	mu       sync.Mutex
+	monitors map[string]*entry
+	jobs     chan *entry
+	quit     chan struct{}
+	started  bool
+	wg       sync.WaitGroup
+}
+
+func New(opts Options) *Scheduler {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.Jitter < 0 {
+		opts.Jitter = 0
+	}
+	if opts.Probe == nil {
+		opts.Probe = utils.HostMetricsWithTimeout
+	}
+	if opts.CertificateProbe == nil {
+		opts.CertificateProbe = utils.CertificateMetrics
+	}
+
+	return &Scheduler{
+		opts:     opts,
+		monitors: make(map[string]*entry),
+		jobs:     make(chan *entry, opts.Workers),
+		quit:     make(chan struct{}),
+	}
+}
+
+// Start launches the worker pool and the loops of all registered monitors.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return
+	}
+	s.started = true
+
+	for range s.opts.Workers {
+		s.wg.Add(1)
+		go s.worker()
+	}
+
+	for _, e := range s.monitors {
+		s.wg.Add(1)
+		go s.loop(e)
+	}
+}
+
+// Stop halts all monitor loops and waits for in-flight probes to finish.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = false
+	close(s.quit)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Upsert registers a monitor or replaces the definition stored under the
+// same key. Unchanged definitions keep their running schedule.
+func (s *Scheduler) Upsert(monitor Monitor) {
+	monitor = monitor.WithDefaults()
+	key := monitor.Key()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.monitors[key]; ok {
+		if existing.monitor.Equal(monitor) {
+			return
+		}
+		close(existing.stop)
+	} else {
+		slog.Info("Scheduling monitor", "monitor", key, "host", monitor.Host, "interval", monitor.Interval)
+	}
+
+	e := &entry{monitor: monitor, stop: make(chan struct{})}
+	s.monitors[key] = e
+	if s.started {
+		s.wg.Add(1)
+		go s.loop(e)
+	}
+}
+
+// Remove stops probing the monitor stored under key.
+func (s *Scheduler) Remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.monitors[key]; ok {
+		close(e.stop)
+		delete(s.monitors, key)
+		slog.Info("Unscheduled monitor", "monitor", key)
+	}
+}
+
+// Monitors returns a snapshot of the registered monitor definitions.
+func (s *Scheduler) Monitors() []Monitor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	monitors := make([]Monitor, 0, len(s.monitors))
+	for _, e := range s.monitors {
+		monitors = append(monitors, e.monitor)
+	}
+	return monitors
+}
+
+func (s *Scheduler) loop(e *entry) {
+	defer s.wg.Done()
+
+	// Spread the first probe over the interval so monitors registered at
+	// the same time don't hit the worker pool in one burst.
+	delay := time.Duration(rand.Int64N(int64(e.monitor.Interval)))
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-s.quit:
+			timer.Stop()
+			return
+		case <-e.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.enqueue(e)
+		delay = s.jittered(e.monitor.Interval)
+	}
+}
+
+func (s *Scheduler) enqueue(e *entry) {
+	// Skip the tick if the previous probe of this monitor is still running.
+	if !e.running.CompareAndSwap(false, true) {
+		return
+	}
+
+	select {
+	case s.jobs <- e:
+	case <-s.quit:
+		e.running.Store(false)
+	case <-e.stop:
+		e.running.Store(false)
+	}
+}
+
+func (s *Scheduler) worker() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.quit:
+			return
+		case e := <-s.jobs:
+			s.run(e)
+		}
+	}
+}
+
+func (s *Scheduler) run(e *entry) {
+	defer e.running.Store(false)
+
+	probe := s.opts.Probe
+	if e.monitor.Type == TypeCertificate {
+		probe = s.opts.CertificateProbe
+	}
+
+	result := Result{Monitor: e.monitor, Started: time.Now()}
+	result.Metrics, result.Err = probe(e.monitor.Host, e.monitor.Path, e.monitor.Timeout)
+	result.Finished = time.Now()
+
+	select {
+	case <-e.stop:
+		// Removed while probing; its state is gone already.
+		return
+	default:
+	}
+	if s.opts.Handler != nil {
+		s.opts.Handler(result)
+	}
+}
+
+func (s *Scheduler) jittered(interval time.Duration) time.Duration {
+	if s.opts.Jitter == 0 {
+		return interval
+	}
+	spread := float64(interval) * s.opts.Jitter
+	return interval + time.Duration((rand.Float64()*2-1)*spread)
+}
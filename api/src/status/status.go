@@ -0,0 +1,130 @@
+package status
+
+import (
+	"slices"
+
+	"iammati/statuspage/db"
+)
+
+// Statuses of components and the overall system, from best to worst.
+const (
+	Operational      = "operational"
+	UnderMaintenance = "under_maintenance"
+	Degraded         = "degraded"
+	PartialOutage    = "partial_outage"
+	MajorOutage      = "major_outage"
+	// Unknown is reported while none of a component's monitors were probed.
+	Unknown = "unknown"
+)
+
+var rank = map[string]int{
+	Operational:      0,
+	UnderMaintenance: 1,
+	Degraded:         2,
+	PartialOutage:    3,
+	MajorOutage:      4,
+}
+
+// Worse returns the more severe of two statuses. Unknown never wins over a
+// known status.
+func Worse(a, b string) string {
+	if a == Unknown {
+		return b
+	}
+	if b == Unknown {
+		return a
+	}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// MonitorState is the current state of a single monitor.
+type MonitorState struct {
+	Up          bool
+	Flapping    bool
+	Maintenance bool
+}
+
+func (m MonitorState) Status() string {
+	switch {
+	case m.Maintenance:
+		return UnderMaintenance
+	case m.Flapping:
+		return Degraded
+	case m.Up:
+		return Operational
+	default:
+		return MajorOutage
+	}
+}
+
+// IncidentStatus maps the severity of an active incident to a status.
+func IncidentStatus(severity string) string {
+	switch severity {
+	case db.SeverityMinor:
+		return Degraded
+	case db.SeverityCritical:
+		return MajorOutage
+	default:
+		return PartialOutage
+	}
+}
+
+// Component derives the status of a component from its monitors and the
+// active incidents affecting any of them. A component with some monitors
+// down is in a partial outage, with all of them down in a major one.
+// Monitors under maintenance are left out; a component whose monitors are
+// all under maintenance is under maintenance itself.
+func Component(monitors []string, states map[string]MonitorState, incidents []db.Incident) string {
+	result := Unknown
+	known, down, maintenance := 0, 0, 0
+	for _, monitor := range monitors {
+		state, ok := states[monitor]
+		if !ok {
+			continue
+		}
+		if state.Maintenance {
+			maintenance++
+			continue
+		}
+		known++
+		if !state.Up && !state.Flapping {
+			down++
+			continue
+		}
+		result = Worse(result, state.Status())
+	}
+	switch {
+	case down > 0 && down == known:
+		result = Worse(result, MajorOutage)
+	case down > 0:
+		result = Worse(result, PartialOutage)
+	case known == 0 && maintenance > 0:
+		return UnderMaintenance
+	}
+
+	for _, incident := range incidents {
+		if incident.Status == db.IncidentResolved || !affects(incident, monitors) {
+			continue
+		}
+		result = Worse(result, IncidentStatus(incident.Severity))
+	}
+	return result
+}
+
+func affects(incident db.Incident, monitors []string) bool {
+	return slices.ContainsFunc(incident.Monitors, func(affected string) bool {
+		return slices.Contains(monitors, affected)
+	})
+}
+
+// Overall is the worst of the given statuses.
+func Overall(statuses []string) string {
+	result := Unknown
+	for _, status := range statuses {
+		result = Worse(result, status)
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"iammati/statuspage/db"
+)
+
+var ErrLogBufferFull = errors.New("log buffer is full, entry dropped")
+
+// AsyncLogWriter buffers log entries and writes them in the background, so
+// a slow or unavailable database degrades logging instead of blocking the
+// callers.
+type AsyncLogWriter struct {
+	entries chan db.LogEntry
+	write   func(db.LogEntry) error
+	dropped atomic.Int64
+	failed  atomic.Int64
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+func NewAsyncLogWriter(size int, write func(db.LogEntry) error) *AsyncLogWriter {
+	w := &AsyncLogWriter{
+		entries: make(chan db.LogEntry, size),
+		write:   write,
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *AsyncLogWriter) run() {
+	defer close(w.done)
+
+	for entry := range w.entries {
+		if err := w.write(entry); err != nil {
+			w.failed.Add(1)
+			fmt.Fprintf(os.Stderr, "Failed to write log entry: %v\n", err)
+		}
+	}
+}
+
+// Write queues the entry without blocking and drops it if the buffer is full.
+func (w *AsyncLogWriter) Write(entry db.LogEntry) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return errors.New("log writer is closed")
+	}
+
+	select {
+	case w.entries <- entry:
+		return nil
+	default:
+		w.dropped.Add(1)
+		return ErrLogBufferFull
+	}
+}
+
+// Dropped returns how many entries were discarded because the buffer was full.
+func (w *AsyncLogWriter) Dropped() int64 {
+	return w.dropped.Load()
+}
+
+// Failed returns how many entries could not be written.
+func (w *AsyncLogWriter) Failed() int64 {
+	return w.failed.Load()
+}
+
+// Close stops accepting entries and waits until the buffer is drained.
+func (w *AsyncLogWriter) Close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.entries)
+	w.mu.Unlock()
+
+	<-w.done
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"iammati/statuspage/db"
+)
+
+// LogHandler is a slog.Handler keeping the records of the given levels as
+// log entries. Attributes are stored as strings; those in groups are keyed
+// by their dotted path, e.g. "pod.name".
+type LogHandler struct {
+	store  LogStore
+	levels []slog.Level
+	attrs  map[string]string
+	prefix string
+}
+
+func NewLogHandler(store LogStore, levels ...slog.Level) *LogHandler {
+	return &LogHandler{store: store, levels: levels, attrs: map[string]string{}}
+}
+
+func (h *LogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return slices.Contains(h.levels, level)
+}
+
+func (h *LogHandler) Handle(_ context.Context, record slog.Record) error {
+	attributes := maps.Clone(h.attrs)
+	record.Attrs(func(attr slog.Attr) bool {
+		addAttr(attributes, h.prefix, attr)
+		return true
+	})
+	return h.store.InsertLog(db.LogEntry{
+		Timestamp:  record.Time.UTC().Format(time.RFC3339Nano),
+		Level:      record.Level.String(),
+		Message:    record.Message,
+		Attributes: attributes,
+	})
+}
+
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handler := *h
+	handler.attrs = maps.Clone(h.attrs)
+	for _, attr := range attrs {
+		addAttr(handler.attrs, h.prefix, attr)
+	}
+	return &handler
+}
+
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	handler := *h
+	handler.prefix = h.prefix + name + "."
+	return &handler
+}
+
+func addAttr(attributes map[string]string, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() != slog.KindGroup {
+		attributes[prefix+attr.Key] = attr.Value.String()
+		return
+	}
+	// Groups without a key are inlined.
+	if attr.Key != "" {
+		prefix += attr.Key + "."
+	}
+	for _, member := range attr.Value.Group() {
+		addAttr(attributes, prefix, member)
+	}
+}
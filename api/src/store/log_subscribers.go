@@ -0,0 +1,44 @@
+package store
+
+import (
+	"sync"
+
+	"iammati/statuspage/db"
+)
+
+// logSubscribers hands every stored log entry to the live subscribers. The
+// zero value is ready to use.
+type logSubscribers struct {
+	mu        sync.RWMutex
+	next      int
+	listeners map[int]func(db.LogEntry)
+}
+
+// SubscribeLogs calls listener with every log entry once it is stored,
+// until unsubscribe is called. Listeners must not block.
+func (s *logSubscribers) SubscribeLogs(listener func(db.LogEntry)) (unsubscribe func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listeners == nil {
+		s.listeners = make(map[int]func(db.LogEntry))
+	}
+	id := s.next
+	s.next++
+	s.listeners[id] = listener
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.listeners, id)
+	}
+}
+
+func (s *logSubscribers) publish(entry db.LogEntry) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, listener := range s.listeners {
+		listener(entry)
+	}
+}
@@ -0,0 +1,471 @@
+package store
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"iammati/statuspage/db"
+)
+
+// Memory keeps everything in process memory. It is meant for local runs
+// and tests; nothing survives a restart.
+type Memory struct {
+	logSubscribers
+
+	mu             sync.RWMutex
+	logs           []db.LogEntry
+	probeResults   []db.ProbeResult
+	incidents      []db.Incident
+	updates        []db.IncidentUpdate
+	transitions    []db.StateTransition
+	monitors       map[string]db.Monitor
+	components     map[string]db.Component
+	maintenance    []db.MaintenanceWindow
+	deliveries     []db.NotificationDelivery
+	nextLogID      int64
+	nextProbeID    int64
+	nextIncidentID int64
+	nextUpdateID   int64
+	nextStateID    int64
+	nextWindowID   int64
+	nextDeliveryID int64
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		monitors:   make(map[string]db.Monitor),
+		components: make(map[string]db.Component),
+	}
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+func (m *Memory) InsertLog(entry db.LogEntry) error {
+	m.mu.Lock()
+	m.nextLogID++
+	entry.ID = m.nextLogID
+	m.logs = append(m.logs, entry)
+	m.mu.Unlock()
+
+	m.publish(entry)
+	return nil
+}
+
+func (m *Memory) LogEntries(filter db.LogFilter) ([]db.LogEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	entries := []db.LogEntry{}
+	for i := len(m.logs) - 1; i >= 0 && len(entries) < limit; i-- {
+		if filter.Matches(m.logs[i]) {
+			entries = append(entries, m.logs[i])
+		}
+	}
+	return entries, nil
+}
+
+// Logs returns a copy of every stored log entry, oldest first.
+func (m *Memory) Logs() []db.LogEntry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Clone(m.logs)
+}
+
+func (m *Memory) InsertProbeResult(result db.ProbeResult) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextProbeID++
+	result.ID = m.nextProbeID
+	m.probeResults = append(m.probeResults, result)
+	return result.ID, nil
+}
+
+func (m *Memory) probeResultsInRange(monitor string, from, to time.Time) []db.ProbeResult {
+	var results []db.ProbeResult
+	for _, result := range m.probeResults {
+		if result.Monitor == monitor && !result.StartedAt.Before(from) && result.StartedAt.Before(to) {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+func (m *Memory) ProbeResults(monitor string, from, to time.Time, limit int) ([]db.ProbeResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	results := m.probeResultsInRange(monitor, from, to)
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].StartedAt.After(results[j].StartedAt)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	if results == nil {
+		results = []db.ProbeResult{}
+	}
+	return results, nil
+}
+
+func (m *Memory) ProbeResultBuckets(monitor string, from, to time.Time, step time.Duration) ([]db.ProbeBucket, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	type sums struct {
+		bucket             db.ProbeBucket
+		dns, tcp, tls, web time.Duration
+	}
+	byStart := map[int64]*sums{}
+	for _, result := range m.probeResultsInRange(monitor, from, to) {
+		// Align buckets to the epoch like the Postgres implementation does.
+		start := time.Unix(0, result.StartedAt.UnixNano()/int64(step)*int64(step))
+		entry, ok := byStart[start.Unix()]
+		if !ok {
+			entry = &sums{bucket: db.ProbeBucket{Start: start}}
+			byStart[start.Unix()] = entry
+		}
+		entry.bucket.Count++
+		if result.Up {
+			entry.bucket.UpCount++
+		}
+		entry.dns += result.DnsResolutionTime
+		entry.tcp += result.TcpConnectionTime
+		entry.tls += result.TlsConnectionTime
+		entry.web += result.HttpTime
+	}
+
+	buckets := make([]db.ProbeBucket, 0, len(byStart))
+	for _, entry := range byStart {
+		count := time.Duration(entry.bucket.Count)
+		entry.bucket.DnsResolutionTime = entry.dns / count
+		entry.bucket.TcpConnectionTime = entry.tcp / count
+		entry.bucket.TlsConnectionTime = entry.tls / count
+		entry.bucket.HttpTime = entry.web / count
+		buckets = append(buckets, entry.bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Start.Before(buckets[j].Start)
+	})
+	return buckets, nil
+}
+
+func (m *Memory) CreateIncident(incident db.Incident) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextIncidentID++
+	incident.ID = m.nextIncidentID
+	incident.Monitors = slices.Clone(incident.Monitors)
+	m.incidents = append(m.incidents, incident)
+	return incident.ID, nil
+}
+
+func (m *Memory) UpdateIncident(incident db.Incident) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.incidents {
+		if m.incidents[i].ID == incident.ID {
+			incident.Monitors = slices.Clone(incident.Monitors)
+			m.incidents[i] = incident
+			return nil
+		}
+	}
+	return db.ErrNotFound
+}
+
+func (m *Memory) Incident(id int64) (db.Incident, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, incident := range m.incidents {
+		if incident.ID == id {
+			incident.Monitors = slices.Clone(incident.Monitors)
+			return incident, nil
+		}
+	}
+	return db.Incident{}, db.ErrNotFound
+}
+
+func (m *Memory) Incidents(filter db.IncidentFilter) ([]db.Incident, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	incidents := []db.Incident{}
+	for i := len(m.incidents) - 1; i >= 0; i-- {
+		incident := m.incidents[i]
+		if filter.Status != "" && incident.Status != filter.Status {
+			continue
+		}
+		if filter.Active && incident.Status == db.IncidentResolved {
+			continue
+		}
+		if filter.Monitor != "" && !slices.Contains(incident.Monitors, filter.Monitor) {
+			continue
+		}
+		incident.Monitors = slices.Clone(incident.Monitors)
+		incidents = append(incidents, incident)
+	}
+	sort.SliceStable(incidents, func(i, j int) bool {
+		return incidents[i].StartedAt.After(incidents[j].StartedAt)
+	})
+	if len(incidents) > limit {
+		incidents = incidents[:limit]
+	}
+	return incidents, nil
+}
+
+func (m *Memory) AddIncidentUpdate(update db.IncidentUpdate) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !slices.ContainsFunc(m.incidents, func(incident db.Incident) bool { return incident.ID == update.IncidentID }) {
+		return 0, db.ErrNotFound
+	}
+
+	m.nextUpdateID++
+	update.ID = m.nextUpdateID
+	m.updates = append(m.updates, update)
+	return update.ID, nil
+}
+
+func (m *Memory) IncidentUpdates(incidentID int64) ([]db.IncidentUpdate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	updates := []db.IncidentUpdate{}
+	for _, update := range m.updates {
+		if update.IncidentID == incidentID {
+			updates = append(updates, update)
+		}
+	}
+	sort.SliceStable(updates, func(i, j int) bool {
+		return updates[i].CreatedAt.Before(updates[j].CreatedAt)
+	})
+	return updates, nil
+}
+
+func (m *Memory) InsertStateTransition(transition db.StateTransition) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextStateID++
+	transition.ID = m.nextStateID
+	m.transitions = append(m.transitions, transition)
+	return transition.ID, nil
+}
+
+func (m *Memory) StateTransitions(monitor string, from, to time.Time) ([]db.StateTransition, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var transitions []db.StateTransition
+	for _, transition := range m.transitions {
+		if transition.Monitor == monitor && transition.At.Before(to) {
+			transitions = append(transitions, transition)
+		}
+	}
+	sort.SliceStable(transitions, func(i, j int) bool {
+		return transitions[i].At.Before(transitions[j].At)
+	})
+
+	// Keep the last transition before from, it holds the initial state.
+	start := 0
+	for i, transition := range transitions {
+		if transition.At.Before(from) {
+			start = i
+		}
+	}
+	if len(transitions) == 0 {
+		return []db.StateTransition{}, nil
+	}
+	return transitions[start:], nil
+}
+
+func (m *Memory) UpsertMonitor(monitor db.Monitor) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.monitors[monitor.Name] = monitor
+	return nil
+}
+
+func (m *Memory) DeleteMonitor(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.monitors, name)
+	return nil
+}
+
+func (m *Memory) Monitors() ([]db.Monitor, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	monitors := make([]db.Monitor, 0, len(m.monitors))
+	for _, monitor := range m.monitors {
+		monitors = append(monitors, monitor)
+	}
+	sort.Slice(monitors, func(i, j int) bool {
+		return monitors[i].Name < monitors[j].Name
+	})
+	return monitors, nil
+}
+
+func (m *Memory) UpsertComponent(component db.Component) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	component.Monitors = slices.Clone(component.Monitors)
+	m.components[component.Name] = component
+	return nil
+}
+
+func (m *Memory) DeleteComponent(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.components, name)
+	return nil
+}
+
+func (m *Memory) Components() ([]db.Component, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	components := make([]db.Component, 0, len(m.components))
+	for _, component := range m.components {
+		component.Monitors = slices.Clone(component.Monitors)
+		components = append(components, component)
+	}
+	sort.Slice(components, func(i, j int) bool {
+		if components[i].Order != components[j].Order {
+			return components[i].Order < components[j].Order
+		}
+		return components[i].Name < components[j].Name
+	})
+	return components, nil
+}
+
+func (m *Memory) CreateMaintenanceWindow(window db.MaintenanceWindow) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextWindowID++
+	window.ID = m.nextWindowID
+	window.Monitors = slices.Clone(window.Monitors)
+	window.Components = slices.Clone(window.Components)
+	m.maintenance = append(m.maintenance, window)
+	return window.ID, nil
+}
+
+func (m *Memory) UpdateMaintenanceWindow(window db.MaintenanceWindow) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.maintenance {
+		if m.maintenance[i].ID == window.ID {
+			window.CreatedAt = m.maintenance[i].CreatedAt
+			window.Monitors = slices.Clone(window.Monitors)
+			window.Components = slices.Clone(window.Components)
+			m.maintenance[i] = window
+			return nil
+		}
+	}
+	return db.ErrNotFound
+}
+
+func (m *Memory) DeleteMaintenanceWindow(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.maintenance {
+		if m.maintenance[i].ID == id {
+			m.maintenance = slices.Delete(m.maintenance, i, i+1)
+			return nil
+		}
+	}
+	return db.ErrNotFound
+}
+
+func (m *Memory) MaintenanceWindows() ([]db.MaintenanceWindow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	windows := make([]db.MaintenanceWindow, 0, len(m.maintenance))
+	for _, window := range m.maintenance {
+		window.Monitors = slices.Clone(window.Monitors)
+		window.Components = slices.Clone(window.Components)
+		windows = append(windows, window)
+	}
+	sort.SliceStable(windows, func(i, j int) bool {
+		return windows[i].StartsAt.Before(windows[j].StartsAt)
+	})
+	return windows, nil
+}
+
+func (m *Memory) CreateNotificationDelivery(delivery db.NotificationDelivery) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextDeliveryID++
+	delivery.ID = m.nextDeliveryID
+	m.deliveries = append(m.deliveries, delivery)
+	return delivery.ID, nil
+}
+
+func (m *Memory) UpdateNotificationDelivery(delivery db.NotificationDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.deliveries {
+		if m.deliveries[i].ID == delivery.ID {
+			m.deliveries[i].Status = delivery.Status
+			m.deliveries[i].Attempts = delivery.Attempts
+			m.deliveries[i].ResponseCode = delivery.ResponseCode
+			m.deliveries[i].LastError = delivery.LastError
+			m.deliveries[i].UpdatedAt = delivery.UpdatedAt
+			return nil
+		}
+	}
+	return db.ErrNotFound
+}
+
+func (m *Memory) NotificationDeliveries(filter db.DeliveryFilter) ([]db.NotificationDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	deliveries := []db.NotificationDelivery{}
+	for i := len(m.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		delivery := m.deliveries[i]
+		if filter.Channel != "" && delivery.Channel != filter.Channel {
+			continue
+		}
+		if filter.Status != "" && delivery.Status != filter.Status {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
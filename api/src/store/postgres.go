@@ -0,0 +1,274 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx"
+
+	"iammati/statuspage/db"
+)
+
+type PostgresOptions struct {
+	// QueryTimeout bounds every single attempt of a query.
+	QueryTimeout time.Duration
+	// Retries is how often a query failing with a transient error is retried.
+	Retries int
+	// LogBuffer is the number of log entries buffered for the async writer.
+	LogBuffer int
+}
+
+// Postgres persists everything through the repositories in package db,
+// sharing a connection pool between all callers.
+type Postgres struct {
+	logSubscribers
+
+	pool *pgx.ConnPool
+	// conn traces the writes going through the pool.
+	conn db.Querier
+	opts PostgresOptions
+	logs *AsyncLogWriter
+}
+
+func NewPostgres(pool *pgx.ConnPool, opts PostgresOptions) *Postgres {
+	p := &Postgres{pool: pool, conn: db.Traced(pool), opts: opts}
+	// Log entries skip tracing, every entry would be a span of its own.
+	p.logs = NewAsyncLogWriter(opts.LogBuffer, func(entry db.LogEntry) error {
+		err := p.insert(func(ctx context.Context) (err error) {
+			entry.ID, err = db.InsertLogEntry(ctx, p.pool, entry)
+			return err
+		})
+		if err == nil {
+			p.publish(entry)
+		}
+		return err
+	})
+	return p
+}
+
+func (p *Postgres) Close() error {
+	p.logs.Close()
+	p.pool.Close()
+	return nil
+}
+
+// do runs fn with a per-attempt timeout and retries transient failures
+// with exponential backoff. fn must be idempotent: an attempt that timed out
+// or lost its connection may still have taken effect.
+func (p *Postgres) do(fn func(ctx context.Context) error) error {
+	return p.retry(fn, func(err error) bool {
+		return db.IsTransient(err) || errors.Is(err, context.DeadlineExceeded)
+	})
+}
+
+// insert runs fn like do, but only retries failures that are known to
+// have left the database untouched, so an insert is never repeated.
+func (p *Postgres) insert(fn func(ctx context.Context) error) error {
+	return p.retry(fn, db.IsUnapplied)
+}
+
+func (p *Postgres) retry(fn func(ctx context.Context) error, retryable func(error) bool) error {
+	backoff := 100 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), p.opts.QueryTimeout)
+		err := fn(ctx)
+		cancel()
+
+		if err == nil || !retryable(err) || attempt >= p.opts.Retries {
+			return err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// InsertLog hands the entry to the async writer and never blocks.
+func (p *Postgres) InsertLog(entry db.LogEntry) error {
+	return p.logs.Write(entry)
+}
+
+func (p *Postgres) LogEntries(filter db.LogFilter) (entries []db.LogEntry, err error) {
+	err = p.do(func(ctx context.Context) error {
+		entries, err = db.LogEntries(ctx, p.conn, filter)
+		return err
+	})
+	return entries, err
+}
+
+func (p *Postgres) InsertProbeResult(result db.ProbeResult) (id int64, err error) {
+	err = p.insert(func(ctx context.Context) error {
+		id, err = db.InsertProbeResult(ctx, p.conn, result)
+		return err
+	})
+	return id, err
+}
+
+func (p *Postgres) ProbeResults(monitor string, from, to time.Time, limit int) (results []db.ProbeResult, err error) {
+	err = p.do(func(ctx context.Context) error {
+		results, err = db.ProbeResults(ctx, p.conn, monitor, from, to, limit)
+		return err
+	})
+	return results, err
+}
+
+func (p *Postgres) ProbeResultBuckets(monitor string, from, to time.Time, step time.Duration) (buckets []db.ProbeBucket, err error) {
+	err = p.do(func(ctx context.Context) error {
+		buckets, err = db.ProbeResultBuckets(ctx, p.conn, monitor, from, to, step)
+		return err
+	})
+	return buckets, err
+}
+
+func (p *Postgres) CreateIncident(incident db.Incident) (id int64, err error) {
+	err = p.insert(func(ctx context.Context) error {
+		id, err = db.CreateIncident(ctx, p.conn, incident)
+		return err
+	})
+	return id, err
+}
+
+func (p *Postgres) UpdateIncident(incident db.Incident) error {
+	return p.do(func(ctx context.Context) error {
+		return db.UpdateIncident(ctx, p.conn, incident)
+	})
+}
+
+func (p *Postgres) Incident(id int64) (incident db.Incident, err error) {
+	err = p.do(func(ctx context.Context) error {
+		incident, err = db.GetIncident(ctx, p.conn, id)
+		return err
+	})
+	return incident, err
+}
+
+func (p *Postgres) Incidents(filter db.IncidentFilter) (incidents []db.Incident, err error) {
+	err = p.do(func(ctx context.Context) error {
+		incidents, err = db.Incidents(ctx, p.conn, filter)
+		return err
+	})
+	return incidents, err
+}
+
+func (p *Postgres) AddIncidentUpdate(update db.IncidentUpdate) (id int64, err error) {
+	err = p.insert(func(ctx context.Context) error {
+		id, err = db.AddIncidentUpdate(ctx, p.conn, update)
+		return err
+	})
+	return id, err
+}
+
+func (p *Postgres) IncidentUpdates(incidentID int64) (updates []db.IncidentUpdate, err error) {
+	err = p.do(func(ctx context.Context) error {
+		updates, err = db.IncidentUpdates(ctx, p.conn, incidentID)
+		return err
+	})
+	return updates, err
+}
+
+func (p *Postgres) UpsertMonitor(monitor db.Monitor) error {
+	return p.do(func(ctx context.Context) error {
+		return db.UpsertMonitor(ctx, p.conn, monitor)
+	})
+}
+
+func (p *Postgres) DeleteMonitor(name string) error {
+	return p.do(func(ctx context.Context) error {
+		return db.DeleteMonitor(ctx, p.conn, name)
+	})
+}
+
+func (p *Postgres) Monitors() (monitors []db.Monitor, err error) {
+	err = p.do(func(ctx context.Context) error {
+		monitors, err = db.Monitors(ctx, p.conn)
+		return err
+	})
+	return monitors, err
+}
+
+func (p *Postgres) InsertStateTransition(transition db.StateTransition) (id int64, err error) {
+	err = p.insert(func(ctx context.Context) error {
+		id, err = db.InsertStateTransition(ctx, p.conn, transition)
+		return err
+	})
+	return id, err
+}
+
+func (p *Postgres) StateTransitions(monitor string, from, to time.Time) (transitions []db.StateTransition, err error) {
+	err = p.do(func(ctx context.Context) error {
+		transitions, err = db.StateTransitions(ctx, p.conn, monitor, from, to)
+		return err
+	})
+	return transitions, err
+}
+
+func (p *Postgres) UpsertComponent(component db.Component) error {
+	return p.do(func(ctx context.Context) error {
+		return db.UpsertComponent(ctx, p.conn, component)
+	})
+}
+
+func (p *Postgres) DeleteComponent(name string) error {
+	return p.do(func(ctx context.Context) error {
+		return db.DeleteComponent(ctx, p.conn, name)
+	})
+}
+
+func (p *Postgres) Components() (components []db.Component, err error) {
+	err = p.do(func(ctx context.Context) error {
+		components, err = db.Components(ctx, p.conn)
+		return err
+	})
+	return components, err
+}
+
+func (p *Postgres) CreateMaintenanceWindow(window db.MaintenanceWindow) (id int64, err error) {
+	err = p.insert(func(ctx context.Context) error {
+		id, err = db.CreateMaintenanceWindow(ctx, p.conn, window)
+		return err
+	})
+	return id, err
+}
+
+func (p *Postgres) UpdateMaintenanceWindow(window db.MaintenanceWindow) error {
+	return p.do(func(ctx context.Context) error {
+		return db.UpdateMaintenanceWindow(ctx, p.conn, window)
+	})
+}
+
+func (p *Postgres) DeleteMaintenanceWindow(id int64) error {
+	return p.do(func(ctx context.Context) error {
+		return db.DeleteMaintenanceWindow(ctx, p.conn, id)
+	})
+}
+
+func (p *Postgres) MaintenanceWindows() (windows []db.MaintenanceWindow, err error) {
+	err = p.do(func(ctx context.Context) error {
+		windows, err = db.MaintenanceWindows(ctx, p.conn)
+		return err
+	})
+	return windows, err
+}
+
+func (p *Postgres) CreateNotificationDelivery(delivery db.NotificationDelivery) (id int64, err error) {
+	err = p.insert(func(ctx context.Context) error {
+		id, err = db.CreateNotificationDelivery(ctx, p.conn, delivery)
+		return err
+	})
+	return id, err
+}
+
+func (p *Postgres) UpdateNotificationDelivery(delivery db.NotificationDelivery) error {
+	return p.do(func(ctx context.Context) error {
+		return db.UpdateNotificationDelivery(ctx, p.conn, delivery)
+	})
+}
+
+func (p *Postgres) NotificationDeliveries(filter db.DeliveryFilter) (deliveries []db.NotificationDelivery, err error) {
+	err = p.do(func(ctx context.Context) error {
+		deliveries, err = db.NotificationDeliveries(ctx, p.conn, filter)
+		return err
+	})
+	return deliveries, err
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"iammati/statuspage/db"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+)
+
+// Store is everything the application persists, independent of the backend.
+type Store interface {
+	LogStore
+	ProbeResultStore
+	IncidentStore
+	MonitorStore
+	TransitionStore
+	ComponentStore
+	MaintenanceStore
+	DeliveryStore
+	Close() error
+}
+
+type LogStore interface {
+	InsertLog(entry db.LogEntry) error
+	// LogEntries returns the matching entries, newest first.
+	LogEntries(filter db.LogFilter) ([]db.LogEntry, error)
+	// SubscribeLogs calls listener with every entry once it is stored, until
+	// unsubscribe is called. Listeners must not block.
+	SubscribeLogs(listener func(db.LogEntry)) (unsubscribe func())
+}
+
+type ProbeResultStore interface {
+	InsertProbeResult(result db.ProbeResult) (int64, error)
+	ProbeResults(monitor string, from, to time.Time, limit int) ([]db.ProbeResult, error)
+	ProbeResultBuckets(monitor string, from, to time.Time, step time.Duration) ([]db.ProbeBucket, error)
+}
+
+type IncidentStore interface {
+	CreateIncident(incident db.Incident) (int64, error)
+	UpdateIncident(incident db.Incident) error
+	Incident(id int64) (db.Incident, error)
+	Incidents(filter db.IncidentFilter) ([]db.Incident, error)
+	AddIncidentUpdate(update db.IncidentUpdate) (int64, error)
+	IncidentUpdates(incidentID int64) ([]db.IncidentUpdate, error)
+}
+
+type TransitionStore interface {
+	InsertStateTransition(transition db.StateTransition) (int64, error)
+	// StateTransitions includes the last transition before from, if any.
+	StateTransitions(monitor string, from, to time.Time) ([]db.StateTransition, error)
+}
+
+type MonitorStore interface {
+	UpsertMonitor(monitor db.Monitor) error
+	DeleteMonitor(name string) error
+	Monitors() ([]db.Monitor, error)
+}
+
+type ComponentStore interface {
+	UpsertComponent(component db.Component) error
+	DeleteComponent(name string) error
+	// Components are returned in display order.
+	Components() ([]db.Component, error)
+}
+
+type MaintenanceStore interface {
+	CreateMaintenanceWindow(window db.MaintenanceWindow) (int64, error)
+	UpdateMaintenanceWindow(window db.MaintenanceWindow) error
+	DeleteMaintenanceWindow(id int64) error
+	MaintenanceWindows() ([]db.MaintenanceWindow, error)
+}
+
+type DeliveryStore interface {
+	CreateNotificationDelivery(delivery db.NotificationDelivery) (int64, error)
+	UpdateNotificationDelivery(delivery db.NotificationDelivery) error
+	NotificationDeliveries(filter db.DeliveryFilter) ([]db.NotificationDelivery, error)
+}
+
+func ValidateDriver(driver string) error {
+	switch driver {
+	case DriverPostgres, DriverMemory:
+		return nil
+	default:
+		return fmt.Errorf("unknown store driver '%s'", driver)
+	}
+}
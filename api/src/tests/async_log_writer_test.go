@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx"
+
+	"iammati/statuspage/db"
+	"iammati/statuspage/store"
+)
+
+func TestAsyncLogWriterDropsInsteadOfBlocking(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var written []string
+
+	writer := store.NewAsyncLogWriter(2, func(entry db.LogEntry) error {
+		<-release
+		mu.Lock()
+		defer mu.Unlock()
+		written = append(written, entry.Message)
+		return nil
+	})
+
+	// One entry is picked up by the (blocked) writer, two fill the buffer
+	// and everything after that is dropped.
+	var dropped int
+	for i := range 10 {
+		if err := writer.Write(db.LogEntry{Message: fmt.Sprint(i)}); errors.Is(err, store.ErrLogBufferFull) {
+			dropped++
+		}
+	}
+	if dropped == 0 || int64(dropped) != writer.Dropped() {
+		t.Fatalf("expected dropped entries to be counted, got %d/%d", dropped, writer.Dropped())
+	}
+
+	close(release)
+	writer.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(written)+dropped != 10 {
+		t.Fatalf("expected every accepted entry to be written on close, got %d written and %d dropped", len(written), dropped)
+	}
+	if err := writer.Write(db.LogEntry{}); err == nil {
+		t.Fatal("expected writes after close to fail")
+	}
+}
+
+func TestAsyncLogWriterSurvivesWriteFailures(t *testing.T) {
+	writer := store.NewAsyncLogWriter(10, func(entry db.LogEntry) error {
+		return errors.New("database is down")
+	})
+	writer.Write(db.LogEntry{Message: "a"})
+	writer.Write(db.LogEntry{Message: "b"})
+	writer.Close()
+
+	if writer.Failed() != 2 {
+		t.Fatalf("expected 2 failed writes, got %d", writer.Failed())
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	cases := map[error]bool{
+		nil:                        false,
+		pgx.ErrDeadConn:            true,
+		pgx.ErrAcquireTimeout:      true,
+		pgx.PgError{Code: "08006"}: true,
+		pgx.PgError{Code: "23505"}: false,
+		fmt.Errorf("failed to insert probe result: %w", pgx.ErrDeadConn): true,
+		errors.New("syntax error"):                                       false,
+	}
+	for err, expected := range cases {
+		if db.IsTransient(err) != expected {
+			t.Errorf("IsTransient(%v) = %t, expected %t", err, !expected, expected)
+		}
+	}
+}
+
+func TestIsUnapplied(t *testing.T) {
+	cases := map[error]bool{
+		nil:                        false,
+		pgx.ErrAcquireTimeout:      true,
+		pgx.PgError{Code: "40001"}: true,
+		pgx.PgError{Code: "08006"}: false,
+		io.ErrUnexpectedEOF:        false,
+		context.DeadlineExceeded:   false,
+		fmt.Errorf("failed to create incident: %w", pgx.ErrConnBusy): true,
+	}
+	for err, expected := range cases {
+		if db.IsUnapplied(err) != expected {
+			t.Errorf("IsUnapplied(%v) = %t, expected %t", err, !expected, expected)
+		}
+	}
+}
@@ -14,7 +14,7 @@ import (
 )
 
 func CertPool() *x509.CertPool {
-	caCertPool, err := utils.LoadCertsFromDir("/usr/local/share/ca-certificates")
+	caCertPool, err := utils.LoadCertsFromDir(config.AppSettings.CACertDir)
 	if err != nil {
 		log.Fatalf("failed to load custom CA certificates.\nReason: %s", err)
 	}
@@ -44,8 +44,13 @@ func ApiV1(client http.Client, url string, data map[string]interface{}) error {
 		return fmt.Errorf("failed to create request: %v", err)
 	}
 
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return fmt.Errorf("failed to load settings: %v", err)
+	}
+
 	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
-	req.Header.Add("X-App-Key", config.AppKey)
+	req.Header.Add("X-App-Key", settings.AppKey)
 
 	// Send the request
 	resp, err := client.Do(req)
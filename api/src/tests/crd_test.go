@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"iammati/statuspage/crd"
+	"iammati/statuspage/db"
+	"iammati/statuspage/maintenance"
+	"iammati/statuspage/scheduler"
+	"iammati/statuspage/status"
+	"iammati/statuspage/store"
+	"iammati/statuspage/utils"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func monitorResource(name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "statuspage.io/v1alpha1",
+		"kind":       "Monitor",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "shop", "generation": int64(1)},
+		"spec":       spec,
+	}}
+}
+
+func waitForStatus(t *testing.T, client *dynamicfake.FakeDynamicClient, name string, expected string, fields ...string) *unstructured.Unstructured {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resource, err := client.Resource(crd.Monitors).Namespace("shop").Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, _, _ := unstructured.NestedString(resource.Object, append([]string{"status"}, fields...)...)
+		if value == expected {
+			return resource
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected status.%s of %s to be %q, got %q", strings.Join(fields, "."), name, expected, value)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMonitorResources(t *testing.T) {
+	checkout := monitorResource("checkout", map[string]interface{}{
+		"target":     map[string]interface{}{"host": "shop.example.com", "path": "/health"},
+		"interval":   "45s",
+		"component":  "Checkout",
+		"assertions": map[string]interface{}{"statusCodes": []interface{}{int64(200)}, "maxResponseTime": "2s"},
+	})
+	payments := monitorResource("payments", map[string]interface{}{
+		"target": map[string]interface{}{"host": "payments.example.com"},
+	})
+	broken := monitorResource("broken", map[string]interface{}{
+		"target": map[string]interface{}{"host": "https://shop.example.com"},
+		"type":   "ping",
+	})
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{crd.Monitors: "MonitorList"}, checkout, payments, broken)
+
+	memory := store.NewMemory()
+	now := time.Now()
+	memory.InsertStateTransition(db.StateTransition{Monitor: "shop/checkout", At: now.Add(-2 * time.Hour), Up: false})
+	memory.InsertStateTransition(db.StateTransition{Monitor: "shop/checkout", At: now.Add(-time.Hour), Up: true})
+
+	states := map[string]status.MonitorState{"shop/checkout": {Up: true}, "shop/payments": {Maintenance: true}}
+	sched := scheduler.New(scheduler.Options{})
+	controller := crd.New(client, "", crd.Options{
+		Registry:    sched,
+		States:      func() map[string]status.MonitorState { return states },
+		Transitions: memory,
+		// The downtime of checkout was planned.
+		Excluded: func(monitor string, from, to time.Time) []maintenance.Interval {
+			return []maintenance.Interval{{Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)}}
+		},
+		StatusInterval: 20 * time.Millisecond,
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go controller.Run(stop)
+
+	waitForMonitors(t, sched, "shop/checkout", "shop/payments")
+	monitor := scheduledMonitor(sched, "shop/checkout")
+	if monitor.Host != "shop.example.com" || monitor.Path != "/health" || monitor.Interval != 45*time.Second ||
+		monitor.Component != "Checkout" || monitor.MaxResponseTime != 2*time.Second || len(monitor.ExpectedStatus) != 1 {
+		t.Fatalf("expected the monitor of the spec, got %+v", monitor)
+	}
+
+	waitForStatus(t, client, "payments", crd.StateMaintenance, "state")
+	resource := waitForStatus(t, client, "broken", crd.StateInvalid, "state")
+	if message, _, _ := unstructured.NestedString(resource.Object, "status", "message"); message == "" {
+		t.Fatal("expected an invalid spec to be explained")
+	}
+
+	controller.Observe(scheduler.Result{
+		Monitor: monitor,
+		Metrics: utils.Metrics{Reachable: true, StatusCode: 200, HttpTime: 120 * time.Millisecond},
+		Started: now,
+	})
+	resource = waitForStatus(t, client, "checkout", "120ms", "lastProbe", "responseTime")
+	state, _, _ := unstructured.NestedString(resource.Object, "status", "state")
+	uptime, _, _ := unstructured.NestedString(resource.Object, "status", "uptime")
+	code, _, _ := unstructured.NestedInt64(resource.Object, "status", "lastProbe", "statusCode")
+	if state != crd.StateUp || uptime != "100.00%" || code != 200 {
+		t.Fatalf("expected an up monitor with its last probe and uptime, got %v", resource.Object["status"])
+	}
+
+	// Results of monitors not declared as resources are ignored.
+	controller.Observe(scheduler.Result{Monitor: scheduler.Monitor{Host: "blog.example.com"}, Started: now})
+
+	if err := client.Resource(crd.Monitors).Namespace("shop").Delete(context.Background(), "checkout", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForMonitors(t, sched, "shop/payments")
+}
+
+func TestMonitorResourcesUpsertChangesOnly(t *testing.T) {
+	checkout := monitorResource("checkout", map[string]interface{}{
+		"target": map[string]interface{}{"host": "shop.example.com"},
+	})
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{crd.Monitors: "MonitorList"}, checkout)
+
+	registry := &countingRegistry{Scheduler: scheduler.New(scheduler.Options{})}
+	controller := crd.New(client, "", crd.Options{Registry: registry, StatusInterval: 20 * time.Millisecond})
+	stop := make(chan struct{})
+	defer close(stop)
+	go controller.Run(stop)
+	waitForMonitors(t, registry.Scheduler, "shop/checkout")
+
+	// Writing the status updates the resource, but not its spec.
+	waitForStatus(t, client, "checkout", crd.StatePending, "state")
+	controller.Observe(scheduler.Result{Monitor: scheduledMonitor(registry.Scheduler, "shop/checkout"), Started: time.Now()})
+	waitForStatus(t, client, "checkout", "0s", "lastProbe", "responseTime")
+	if upserts := registry.count(); upserts != 1 {
+		t.Fatalf("expected a single upsert, got %d", upserts)
+	}
+
+	resource, err := client.Resource(crd.Monitors).Namespace("shop").Get(context.Background(), "checkout", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unstructured.SetNestedField(resource.Object, "45s", "spec", "interval"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Resource(crd.Monitors).Namespace("shop").Update(context.Background(), resource, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for scheduledMonitor(registry.Scheduler, "shop/checkout").Interval != 45*time.Second {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the changed interval to be upserted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,337 @@
+package tests
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/discovery"
+	"iammati/statuspage/scheduler"
+
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func discoveredPod(name string, phase v1.PodPhase, domain string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", Labels: map[string]string{"workload-class": "webstack-php"}},
+		Spec: v1.PodSpec{Containers: []v1.Container{{
+			Name: "php",
+			Env:  []v1.EnvVar{{Name: "APP_ENV", Value: "prod"}, {Name: discovery.DomainEnv, Value: domain}},
+		}}},
+		Status: v1.PodStatus{Phase: phase},
+	}
+}
+
+func scheduledKeys(sched *scheduler.Scheduler) []string {
+	var keys []string
+	for _, monitor := range sched.Monitors() {
+		keys = append(keys, monitor.Key())
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func scheduledMonitor(sched *scheduler.Scheduler, key string) scheduler.Monitor {
+	for _, monitor := range sched.Monitors() {
+		if monitor.Key() == key {
+			return monitor
+		}
+	}
+	return scheduler.Monitor{}
+}
+
+func waitForMonitors(t *testing.T, sched *scheduler.Scheduler, expected ...string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !slices.Equal(scheduledKeys(sched), expected) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected monitors %v, got %v", expected, scheduledKeys(sched))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDiscoveryPods(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		discoveredPod("shop-1", v1.PodRunning, "shop.example.com"),
+		discoveredPod("shop-2", v1.PodRunning, "shop.example.com"),
+		discoveredPod("blog-1", v1.PodRunning, "blog.example.com"),
+		discoveredPod("next-1", v1.PodPending, "next.example.com"),
+	)
+	sched := scheduler.New(scheduler.Options{})
+	controller, err := discovery.FromSettings(clientset, nil, sched, config.DiscoverySettings{
+		Resync:  time.Minute,
+		Targets: []config.DiscoveryTarget{{Name: "shop", Namespaces: []string{"shop"}, PodSelectors: []string{"workload-class=webstack-php"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go controller.Run(stop)
+
+	waitForMonitors(t, sched, "blog.example.com", "shop.example.com")
+	if monitor := scheduledMonitor(sched, "blog.example.com"); monitor.Group != "shop" || monitor.Host != "blog.example.com" {
+		t.Fatalf("expected the namespace as group and the domain as host, got %+v", monitor)
+	}
+
+	ctx := context.Background()
+	pods := clientset.CoreV1().Pods("shop")
+	if _, err := pods.Update(ctx, discoveredPod("next-1", v1.PodRunning, "next.example.com"), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForMonitors(t, sched, "blog.example.com", "next.example.com", "shop.example.com")
+
+	// A replica going away keeps the monitor of the domain it shares.
+	if err := pods.Delete(ctx, "shop-1", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pods.Delete(ctx, "blog-1", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForMonitors(t, sched, "next.example.com", "shop.example.com")
+
+	if err := pods.Delete(ctx, "shop-2", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForMonitors(t, sched, "next.example.com")
+}
+
+func TestDiscoveryTargets(t *testing.T) {
+	namespace := func(name string, team string) *v1.Namespace {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"team": team}}}
+	}
+	pod := func(namespace string, name string, labels map[string]string, env ...v1.EnvVar) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+			Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app", Env: env}}},
+			Status:     v1.PodStatus{Phase: v1.PodRunning},
+		}
+	}
+	clientset := fake.NewSimpleClientset(
+		namespace("shop", "web"),
+		namespace("blog", "web"),
+		namespace("billing", "finance"),
+		pod("shop", "shop-1", map[string]string{"app": "shop"}, v1.EnvVar{Name: "PUBLIC_HOST", Value: "shop.example.com"}),
+		pod("blog", "blog-1", map[string]string{"app": "blog"}, v1.EnvVar{Name: "SITE_URL_HOST", Value: "blog.example.com"}),
+		pod("blog", "cron-1", map[string]string{"app": "cron"}, v1.EnvVar{Name: "PUBLIC_HOST", Value: "cron.example.com"}),
+		pod("billing", "billing-1", map[string]string{"app": "billing"}, v1.EnvVar{Name: "PUBLIC_HOST", Value: "billing.example.com"}),
+	)
+	sched := scheduler.New(scheduler.Options{})
+	controller, err := discovery.FromSettings(clientset, nil, sched, config.DiscoverySettings{Targets: []config.DiscoveryTarget{{
+		Name:              "web",
+		NamespaceSelector: "team=web",
+		PodSelectors:      []string{"app=shop", "app=blog"},
+		EnvVars:           []string{"PUBLIC_HOST", "SITE_URL_HOST"},
+		Monitor:           config.MonitorTemplate{Path: "/health", Tags: []string{"web"}, Interval: 30 * time.Second},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go controller.Run(stop)
+
+	waitForMonitors(t, sched, "blog.example.com/health", "shop.example.com/health")
+	monitor := scheduledMonitor(sched, "blog.example.com/health")
+	if monitor.Group != "blog" || monitor.Interval != 30*time.Second || !slices.Equal(monitor.Tags, []string{"web", "kubernetes"}) {
+		t.Fatalf("expected the template to apply, got %+v", monitor)
+	}
+
+	// Namespaces leaving the selection take their monitors along.
+	if _, err := clientset.CoreV1().Namespaces().Update(context.Background(), namespace("blog", "marketing"), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForMonitors(t, sched, "shop.example.com/health")
+
+	if _, err := discovery.FromSettings(clientset, nil, sched, config.DiscoverySettings{Targets: []config.DiscoveryTarget{{Name: "broken", PodSelectors: []string{"app in (shop"}}}}); err == nil {
+		t.Fatal("expected an invalid selector to be rejected")
+	}
+}
+
+func TestDiscoveryIngresses(t *testing.T) {
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "shop"},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{Host: "shop.example.com"}, {Host: "*.shop.example.com"}, {Host: "api.example.com"}},
+			TLS:   []networkingv1.IngressTLS{{Hosts: []string{"shop.example.com", "*.shop.example.com"}}},
+		},
+	}
+	route := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "gateway.networking.k8s.io/v1",
+		"kind":       "HTTPRoute",
+		"metadata":   map[string]interface{}{"name": "docs", "namespace": "shop"},
+		"spec":       map[string]interface{}{"hostnames": []interface{}{"docs.example.com"}},
+	}}
+	clientset := fake.NewSimpleClientset(ingress, discoveredPod("shop-1", v1.PodRunning, "shop.example.com"))
+	clientset.Resources = []*metav1.APIResourceList{{
+		GroupVersion: discovery.HTTPRoutes.GroupVersion().String(),
+		APIResources: []metav1.APIResource{{Name: discovery.HTTPRoutes.Resource, Kind: "HTTPRoute", Namespaced: true}},
+	}}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{discovery.HTTPRoutes: "HTTPRouteList"}, route)
+
+	sched := scheduler.New(scheduler.Options{})
+	controller, err := discovery.FromSettings(clientset, dynamicClient, sched, config.DiscoverySettings{
+		Targets:   []config.DiscoveryTarget{{Name: "shop", Namespaces: []string{"shop"}}},
+		Ingresses: config.IngressDiscovery{Enabled: true, HTTPRoutes: true, Certificates: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go controller.Run(stop)
+
+	waitForMonitors(t, sched, "api.example.com", discovery.CertificatePrefix+"shop.example.com", "docs.example.com", "shop.example.com")
+	if monitor := scheduledMonitor(sched, discovery.CertificatePrefix+"shop.example.com"); monitor.Type != scheduler.TypeCertificate || monitor.Host != "shop.example.com" || monitor.Group != "shop" {
+		t.Fatalf("expected a certificate monitor of the TLS host, got %+v", monitor)
+	}
+	if monitor := scheduledMonitor(sched, "api.example.com"); monitor.Type != scheduler.TypeHTTP {
+		t.Fatalf("expected an HTTP monitor of the rule host, got %+v", monitor)
+	}
+
+	// The pod still exposes the domain the Ingress shared with it.
+	if err := clientset.NetworkingV1().Ingresses("shop").Delete(context.Background(), "shop", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForMonitors(t, sched, "docs.example.com", "shop.example.com")
+
+	if err := dynamicClient.Resource(discovery.HTTPRoutes).Namespace("shop").Delete(context.Background(), "docs", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForMonitors(t, sched, "shop.example.com")
+}
+
+func TestDiscoveryAnnotations(t *testing.T) {
+	annotated := func(obj metav1.Object, annotations map[string]string) {
+		obj.SetAnnotations(annotations)
+	}
+	shop := discoveredPod("shop-1", v1.PodRunning, "shop.example.com")
+	annotated(shop, map[string]string{
+		discovery.AnnotationPath:           "/health",
+		discovery.AnnotationInterval:       "45s",
+		discovery.AnnotationExpectedStatus: "200, 204",
+		discovery.AnnotationComponent:      "Shop",
+	})
+	blog := discoveredPod("blog-1", v1.PodRunning, "blog.example.com")
+	annotated(blog, map[string]string{discovery.AnnotationEnabled: "false"})
+	api := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop"}}
+	annotated(api, map[string]string{discovery.AnnotationHost: "api.example.com", discovery.AnnotationComponent: "API"})
+	internal := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "internal", Namespace: "shop"}}
+	docs := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "docs", Namespace: "shop"},
+		Spec:       networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{{Host: "docs.example.com"}}},
+	}
+	annotated(docs, map[string]string{discovery.AnnotationPath: "/status", discovery.AnnotationInterval: "soon"})
+	clientset := fake.NewSimpleClientset(shop, blog, api, internal, docs)
+
+	run := func(settings config.DiscoverySettings) *scheduler.Scheduler {
+		sched := scheduler.New(scheduler.Options{})
+		settings.Targets = []config.DiscoveryTarget{{Name: "shop", Namespaces: []string{"shop"}, Monitor: config.MonitorTemplate{Interval: time.Minute}}}
+		settings.Ingresses.Enabled = true
+		settings.Services.Enabled = true
+		controller, err := discovery.FromSettings(clientset, nil, sched, settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stop := make(chan struct{})
+		t.Cleanup(func() { close(stop) })
+		go controller.Run(stop)
+		return sched
+	}
+
+	sched := run(config.DiscoverySettings{})
+	waitForMonitors(t, sched, "api.example.com", "docs.example.com/status", "shop.example.com/health")
+	monitor := scheduledMonitor(sched, "shop.example.com/health")
+	if monitor.Interval != 45*time.Second || !slices.Equal(monitor.ExpectedStatus, []int{200, 204}) || monitor.Component != "Shop" {
+		t.Fatalf("expected the annotations to override the template, got %+v", monitor)
+	}
+	if monitor := scheduledMonitor(sched, "api.example.com"); monitor.Component != "API" || monitor.Group != "shop" {
+		t.Fatalf("expected the annotated service to be monitored, got %+v", monitor)
+	}
+	if monitor := scheduledMonitor(sched, "docs.example.com/status"); monitor.Interval != scheduler.DefaultInterval {
+		t.Fatalf("expected an invalid annotation to be ignored, got %+v", monitor)
+	}
+
+	// When discovery is opt-in, only objects enabling it are monitored.
+	annotated(blog, map[string]string{discovery.AnnotationEnabled: "true"})
+	if _, err := clientset.CoreV1().Pods("shop").Update(context.Background(), blog, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForMonitors(t, run(config.DiscoverySettings{OptIn: true}), "api.example.com", "blog.example.com")
+}
+
+// countingRegistry counts the upserts reaching a scheduler.
+type countingRegistry struct {
+	*scheduler.Scheduler
+	mu      sync.Mutex
+	upserts int
+}
+
+func (r *countingRegistry) Upsert(monitor scheduler.Monitor) {
+	r.mu.Lock()
+	r.upserts++
+	r.mu.Unlock()
+	r.Scheduler.Upsert(monitor)
+}
+
+func (r *countingRegistry) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.upserts
+}
+
+func TestDiscoveryUpsertsChangesOnly(t *testing.T) {
+	clientset := fake.NewSimpleClientset(discoveredPod("shop-1", v1.PodRunning, "shop.example.com"))
+	registry := &countingRegistry{Scheduler: scheduler.New(scheduler.Options{})}
+	controller, err := discovery.FromSettings(clientset, nil, registry, config.DiscoverySettings{
+		Targets: []config.DiscoveryTarget{{Name: "shop", Namespaces: []string{"shop"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go controller.Run(stop)
+	waitForMonitors(t, registry.Scheduler, "shop.example.com")
+
+	// A status update leaves the monitor as it is, a new interval changes it.
+	ctx := context.Background()
+	pods := clientset.CoreV1().Pods("shop")
+	updated := discoveredPod("shop-1", v1.PodRunning, "shop.example.com")
+	updated.Status.PodIP = "10.0.0.1"
+	if _, err := pods.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	updated = updated.DeepCopy()
+	updated.SetAnnotations(map[string]string{discovery.AnnotationInterval: "45s"})
+	if _, err := pods.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for scheduledMonitor(registry.Scheduler, "shop.example.com").Interval != 45*time.Second {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the changed interval to be upserted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if upserts := registry.count(); upserts != 2 {
+		t.Fatalf("expected 2 upserts, got %d", upserts)
+	}
+}
@@ -0,0 +1,243 @@
+package tests
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/db"
+	"iammati/statuspage/notify"
+	"iammati/statuspage/store"
+	"iammati/statuspage/utils"
+)
+
+// smtpStandIn is a minimal SMTP server accepting STARTTLS, AUTH PLAIN and
+// every recipient except rejected@example.com.
+type smtpStandIn struct {
+	listener net.Listener
+	tls      *tls.Config
+	// failQuit answers QUIT with an error.
+	failQuit bool
+
+	mu       sync.Mutex
+	auth     []string
+	messages []string
+}
+
+func newSMTPStandIn(t *testing.T, tlsConfig *tls.Config) *smtpStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &smtpStandIn{listener: listener, tls: tlsConfig}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *smtpStandIn) settings() config.SMTPSettings {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return config.SMTPSettings{Host: host, Port: portNumber, From: "Status <status@example.com>", TLS: config.SMTPNoTLS}
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP")
+
+	secure := false
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.Fields(line + " ")[0])
+		switch command {
+		case "EHLO":
+			if s.tls != nil && !secure {
+				text.PrintfLine("250-localhost\r\n250-STARTTLS\r\n250 AUTH PLAIN")
+			} else {
+				text.PrintfLine("250-localhost\r\n250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			text.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, s.tls)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn, secure = tlsConn, true
+			text = textproto.NewConn(conn)
+		case "AUTH":
+			credentials, _ := base64.StdEncoding.DecodeString(strings.Fields(line)[2])
+			s.mu.Lock()
+			s.auth = append(s.auth, string(credentials))
+			s.mu.Unlock()
+			text.PrintfLine("235 authenticated")
+		case "RCPT":
+			if strings.Contains(line, "rejected@example.com") {
+				text.PrintfLine("550 no such user")
+			} else {
+				text.PrintfLine("250 ok")
+			}
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			lines, err := text.ReadDotLines()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, strings.Join(lines, "\n"))
+			s.mu.Unlock()
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			if s.failQuit {
+				text.PrintfLine("421 shutting down")
+				return
+			}
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+}
+
+func TestEmailOverStartTLS(t *testing.T) {
+	certificateServer := httptest.NewTLSServer(nil)
+	defer certificateServer.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(certificateServer.Certificate())
+
+	server := newSMTPStandIn(t, &tls.Config{Certificates: certificateServer.TLS.Certificates})
+	settings := server.settings()
+	settings.TLS = config.SMTPStartTLS
+	settings.Username, settings.Password = "statuspage", "hunter2"
+
+	templates, err := notify.LoadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	email := notify.NewEmail("on-call", []string{"oncall@example.com"}, settings, templates)
+	email.TLSConfig = &tls.Config{RootCAs: roots}
+
+	dispatcher := notify.NewDispatcher(store.NewMemory(), notify.Options{Timeout: 5 * time.Second})
+	_, err = dispatcher.Deliver(email, notify.Event{
+		Type: notify.EventMonitorDown,
+		At:   time.Now(),
+		Monitor: &notify.Monitor{
+			Name: "shop",
+			Host: "shop.example.com",
+			Probe: notify.ProbeFromMetrics(utils.Metrics{
+				DnsResolutionTime: 12 * time.Millisecond,
+				StatusCode:        503,
+				Error:             errors.New("service unavailable"),
+			}),
+		},
+	}, 0)
+	if err != nil {
+		t.Fatalf("expected the mail to be accepted, got %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.auth) != 1 || server.auth[0] != "\x00statuspage\x00hunter2" {
+		t.Fatalf("expected PLAIN authentication, got %q", server.auth)
+	}
+	if len(server.messages) != 1 {
+		t.Fatalf("expected a single message, got %d", len(server.messages))
+	}
+	message := server.messages[0]
+	for _, expected := range []string{
+		"Subject: [DOWN] shop is down",
+		"To: oncall@example.com",
+		"text/html; charset=UTF-8",
+		"DNS resolution:  12ms",
+		"Status code:     503",
+		"Error:           service unavailable",
+	} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("expected %q in the message:\n%s", expected, message)
+		}
+	}
+}
+
+func TestEmailRejectedRecipient(t *testing.T) {
+	server := newSMTPStandIn(t, nil)
+	templates, err := notify.LoadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	email := notify.NewEmail("on-call", []string{"rejected@example.com"}, server.settings(), templates)
+
+	dispatcher := notify.NewDispatcher(store.NewMemory(), notify.Options{Backoff: time.Millisecond, Timeout: 5 * time.Second})
+	delivery, err := dispatcher.Deliver(email, notify.Event{Type: notify.EventTest, Message: "Hello"}, 3)
+
+	var permanent *notify.PermanentError
+	if !errors.As(err, &permanent) || delivery.Attempts != 1 || delivery.Status != db.DeliveryFailed {
+		t.Fatalf("expected a single permanently failed attempt, got %+v: %v", delivery, err)
+	}
+}
+
+func TestEmailAcceptedDespiteFailedQuit(t *testing.T) {
+	server := newSMTPStandIn(t, nil)
+	server.failQuit = true
+	templates, err := notify.LoadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	email := notify.NewEmail("on-call", []string{"oncall@example.com"}, server.settings(), templates)
+
+	dispatcher := notify.NewDispatcher(store.NewMemory(), notify.Options{Backoff: time.Millisecond, Timeout: 5 * time.Second})
+	delivery, err := dispatcher.Deliver(email, notify.Event{Type: notify.EventTest, Message: "Hello"}, 3)
+	if err != nil || delivery.Attempts != 1 {
+		t.Fatalf("expected a single successful attempt, got %+v: %v", delivery, err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.messages) != 1 {
+		t.Fatalf("expected the message to be sent once, got %d", len(server.messages))
+	}
+}
+
+func TestEmailTemplateOverrides(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "custom.txt.tmpl"), []byte(`{{define "monitor.down.subject"}}PAGE: {{.Monitor.Name}}{{end}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := notify.LoadTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := notify.NewMessage(notify.Event{Type: notify.EventMonitorDown, Monitor: &notify.Monitor{Name: "shop"}})
+	if subject, _ := templates.Subject(message); subject != "PAGE: shop" {
+		t.Fatalf("expected the overridden subject, got %q", subject)
+	}
+	if body, _ := templates.Text(message); !strings.Contains(body, "shop is DOWN") {
+		t.Fatalf("expected the built-in body to remain, got %q", body)
+	}
+}
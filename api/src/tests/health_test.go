@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"iammati/statuspage/health"
+)
+
+func observe(tracker *health.Tracker, thresholds health.Thresholds, flap health.FlapDetection, results ...bool) []health.Transition {
+	start := time.Date(2024, 11, 21, 12, 0, 0, 0, time.UTC)
+
+	var transitions []health.Transition
+	for i, up := range results {
+		probe := health.Probe{ID: int64(i + 1), Up: up, At: start.Add(time.Duration(i) * time.Second)}
+		if transition, ok := tracker.Observe(probe, thresholds, flap); ok {
+			transitions = append(transitions, transition)
+		}
+	}
+	return transitions
+}
+
+func TestHealthTrackerThresholds(t *testing.T) {
+	var tracker health.Tracker
+	thresholds := health.Thresholds{Failures: 3, Successes: 2}
+
+	transitions := observe(&tracker, thresholds, health.FlapDetection{},
+		true, false, false, true, false, false, false, true, true)
+
+	if len(transitions) != 3 {
+		t.Fatalf("expected the initial state, one down and one up transition, got %+v", transitions)
+	}
+	down := transitions[1]
+	if down.Up || !slices.Equal(down.ProbeIDs, []int64{5, 6, 7}) {
+		t.Fatalf("expected down after probes 5-7, got %+v", down)
+	}
+	up := transitions[2]
+	if !up.Up || !slices.Equal(up.ProbeIDs, []int64{8, 9}) {
+		t.Fatalf("expected up after probes 8-9, got %+v", up)
+	}
+}
+
+func TestHealthTrackerFlapping(t *testing.T) {
+	var tracker health.Tracker
+	thresholds := health.Thresholds{Failures: 1, Successes: 1}
+	flap := health.FlapDetection{Window: 6, High: 0.5, Low: 0.2}
+
+	transitions := observe(&tracker, thresholds, flap, true, false, true, false, true, false)
+	last := transitions[len(transitions)-1]
+	if !last.Flapping || !tracker.Flapping() || len(last.ProbeIDs) != 6 {
+		t.Fatalf("expected the monitor to start flapping, got %+v", transitions)
+	}
+
+	// Up/down changes are held back until the monitor settles.
+	if held := observe(&tracker, thresholds, flap, true, true); len(held) != 0 {
+		t.Fatalf("expected no transitions while flapping, got %+v", held)
+	}
+
+	settled := observe(&tracker, thresholds, flap, true, true, true, true)
+	if len(settled) != 1 || settled[0].Flapping || !settled[0].Up {
+		t.Fatalf("expected the monitor to settle as up, got %+v", settled)
+	}
+}
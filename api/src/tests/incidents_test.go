@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"iammati/statuspage/db"
+	"iammati/statuspage/incidents"
+	"iammati/statuspage/store"
+)
+
+func TestIncidentsFollowTransitions(t *testing.T) {
+	var events []string
+	manager := incidents.NewManager(store.NewMemory(), func(event string, incident incidents.Incident) {
+		events = append(events, event)
+	})
+	now := time.Now()
+
+	manager.HandleTransition("shop", false, now)
+	manager.HandleTransition("shop", false, now.Add(time.Minute))
+
+	active, err := manager.List(db.IncidentFilter{Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].Severity != db.SeverityMajor || active[0].Monitors[0] != "shop" {
+		t.Fatalf("expected a single major incident for shop, got %+v", active)
+	}
+
+	manager.HandleTransition("shop", true, now.Add(2*time.Minute))
+
+	incident, err := manager.Get(active[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if incident.Status != db.IncidentResolved || incident.ResolvedAt == nil || len(incident.Updates) != 2 {
+		t.Fatalf("expected the incident to be resolved with two updates, got %+v", incident)
+	}
+	if len(events) != 2 || events[0] != incidents.EventOpened || events[1] != incidents.EventResolved {
+		t.Fatalf("unexpected events: %v", events)
+	}
+}
+
+func TestIncidentsWaitForAllAffectedMonitors(t *testing.T) {
+	memory := store.NewMemory()
+	now := time.Now()
+	id, err := memory.CreateIncident(db.Incident{
+		Title: "Datacenter outage", Status: db.IncidentOpen, Severity: db.SeverityCritical,
+		Monitors: []string{"shop", "blog"}, StartedAt: now, Automatic: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Right after a restart, nothing is known about blog yet.
+	manager := incidents.NewManager(memory, nil)
+	manager.HandleTransition("shop", true, now.Add(time.Minute))
+
+	if incident, _ := manager.Get(id); incident.Status != db.IncidentOpen {
+		t.Fatalf("expected the incident to stay open until blog is probed, got %s", incident.Status)
+	}
+
+	manager.HandleTransition("blog", true, now.Add(2*time.Minute))
+
+	if incident, _ := manager.Get(id); incident.Status != db.IncidentResolved {
+		t.Fatalf("expected the incident to be resolved, got %s", incident.Status)
+	}
+}
+
+func TestIncidentsKeepManualIncidentsOpen(t *testing.T) {
+	manager := incidents.NewManager(store.NewMemory(), nil)
+	now := time.Now()
+
+	incident, err := manager.Create("Slow checkout", db.SeverityMinor, []string{"shop"}, "", "ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.HandleTransition("shop", false, now)
+	manager.HandleTransition("shop", true, now.Add(time.Minute))
+
+	if incident, _ = manager.Get(incident.ID); incident.Status != db.IncidentOpen || incident.Automatic || len(incident.Updates) != 2 {
+		t.Fatalf("expected the manual incident to stay open with the recovery on its timeline, got %+v", incident)
+	}
+}
+
+func TestIncidentsManualLifecycle(t *testing.T) {
+	manager := incidents.NewManager(store.NewMemory(), nil)
+
+	if _, err := manager.Create("Slow checkout", "catastrophic", nil, "", ""); err != incidents.ErrInvalidSeverity {
+		t.Fatalf("expected ErrInvalidSeverity, got %v", err)
+	}
+
+	incident, err := manager.Create("Slow checkout", db.SeverityMinor, []string{"shop"}, "Investigating.", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.SetPostmortem(incident.ID, "too early"); err != incidents.ErrInvalidTransition {
+		t.Fatalf("expected ErrInvalidTransition for a postmortem on an open incident, got %v", err)
+	}
+
+	if incident, err = manager.Acknowledge(incident.ID, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if incident.Status != db.IncidentAcknowledged || incident.AcknowledgedBy != "bob" {
+		t.Fatalf("unexpected acknowledged incident: %+v", incident)
+	}
+	if _, err := manager.Acknowledge(incident.ID, "bob"); err != incidents.ErrInvalidTransition {
+		t.Fatalf("expected ErrInvalidTransition, got %v", err)
+	}
+
+	if incident, err = manager.AddUpdate(incident.ID, "Database failover in progress.", db.SeverityMajor, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if incident.Severity != db.SeverityMajor {
+		t.Fatalf("expected the severity to be raised, got %s", incident.Severity)
+	}
+
+	if _, err = manager.Resolve(incident.ID, "", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if incident, err = manager.SetPostmortem(incident.ID, "Failover took too long."); err != nil {
+		t.Fatal(err)
+	}
+	if incident.Postmortem == "" || len(incident.Updates) != 4 {
+		t.Fatalf("unexpected resolved incident: %+v", incident)
+	}
+
+	if _, err := manager.Get(42); err != incidents.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
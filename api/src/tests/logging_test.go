@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/store"
+)
+
+func TestLoggingDatabaseLevels(t *testing.T) {
+	memory := store.NewMemory()
+	var output bytes.Buffer
+	logger := config.NewLogger(config.LogSettings{Level: "debug", Format: config.LogJSON}, &output,
+		store.NewLogHandler(memory, slog.LevelWarn, slog.LevelError))
+
+	monitorLogger := logger.With("monitor", "shop")
+	monitorLogger.Debug("Probing")
+	monitorLogger.Info("Monitor is up")
+	monitorLogger.WithGroup("pod").Warn("Monitor is down", "namespace", "shop", "name", "web-1")
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected every record on stdout, got %d lines:\n%s", len(lines), output.String())
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil || record["level"] != "DEBUG" || record["monitor"] != "shop" {
+		t.Fatalf("expected a JSON record with the monitor, got %s: %v", lines[0], err)
+	}
+
+	logs := memory.Logs()
+	if len(logs) != 1 {
+		t.Fatalf("expected only the warning in the database, got %+v", logs)
+	}
+	entry := logs[0]
+	if entry.Level != "WARN" || entry.Message != "Monitor is down" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+	if entry.Attributes["monitor"] != "shop" || entry.Attributes["pod.namespace"] != "shop" || entry.Attributes["pod.name"] != "web-1" {
+		t.Fatalf("expected the attributes to be kept, got %v", entry.Attributes)
+	}
+}
+
+func TestLoggingSettingsValidation(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "verbose")
+	t.Setenv("LOG_FORMAT", "xml")
+	t.Setenv("LOG_DATABASE_LEVELS", "warn,fatal")
+
+	_, err := config.LoadSettings()
+	if err == nil {
+		t.Fatal("expected invalid log settings to be rejected")
+	}
+	for _, expected := range []string{"logging.level: unknown level 'verbose'", "logging.format 'xml'", "logging.databaseLevels: unknown level 'fatal'"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %v", expected, err)
+		}
+	}
+}
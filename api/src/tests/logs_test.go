@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/db"
+	"iammati/statuspage/handlers"
+	"iammati/statuspage/store"
+	"iammati/statuspage/websocket"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func useMemoryLogs(t *testing.T) *store.Memory {
+	previous := config.Store
+	memory := store.NewMemory()
+	config.Store = memory
+	t.Cleanup(func() { config.Store = previous })
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, entry := range []db.LogEntry{
+		{Level: "INFO", Message: "Monitor is up", Attributes: map[string]string{"monitor": "shop"}},
+		{Level: "WARN", Message: "Monitor is down", Attributes: map[string]string{"monitor": "shop"}},
+		{Level: "WARN", Message: "Monitor is down", Attributes: map[string]string{"monitor": "blog"}},
+		{Level: "ERROR", Message: "Failed to store probe result", Attributes: map[string]string{"monitor": "shop"}},
+		{Level: "WARN", Message: "Monitor started flapping", Attributes: map[string]string{"monitor": "shop"}},
+	} {
+		entry.Timestamp = start.Add(time.Duration(i) * time.Minute).Format(time.RFC3339Nano)
+		memory.InsertLog(entry)
+	}
+	return memory
+}
+
+type logsPage struct {
+	Logs       []handlers.LogResponse `json:"logs"`
+	NextCursor *string                `json:"nextCursor"`
+}
+
+func listLogs(t *testing.T, query string) logsPage {
+	recorder := httptest.NewRecorder()
+	handlers.HandleListLogs(recorder, httptest.NewRequest("GET", "/api/v1/logs?"+query, nil))
+	if recorder.Code != 200 {
+		t.Fatalf("expected 200 for %q, got %d: %s", query, recorder.Code, recorder.Body)
+	}
+	var page logsPage
+	if err := json.Unmarshal(recorder.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+func TestLogQuery(t *testing.T) {
+	useMemoryLogs(t)
+
+	page := listLogs(t, "level=warn,error&monitor=shop&limit=2")
+	if len(page.Logs) != 2 || page.Logs[0].Message != "Monitor started flapping" || page.NextCursor == nil {
+		t.Fatalf("expected the newest two entries and a cursor, got %+v", page)
+	}
+	page = listLogs(t, "level=warn,error&monitor=shop&limit=2&cursor="+*page.NextCursor)
+	if len(page.Logs) != 1 || page.Logs[0].Message != "Monitor is down" || page.NextCursor != nil {
+		t.Fatalf("expected the last entry without a cursor, got %+v", page)
+	}
+
+	page = listLogs(t, "q=MONITOR+IS&from=2024-05-01T12:01:00Z&to=2024-05-01T12:02:00Z")
+	if len(page.Logs) != 1 || page.Logs[0].Attributes["monitor"] != "shop" {
+		t.Fatalf("expected the search to be limited to the range, got %+v", page.Logs)
+	}
+
+	recorder := httptest.NewRecorder()
+	handlers.HandleListLogs(recorder, httptest.NewRequest("GET", "/api/v1/logs?level=verbose", nil))
+	if recorder.Code != 400 {
+		t.Fatalf("expected an unknown level to be rejected, got %d", recorder.Code)
+	}
+}
+
+func TestLogExport(t *testing.T) {
+	useMemoryLogs(t)
+
+	recorder := httptest.NewRecorder()
+	handlers.HandleListLogs(recorder, httptest.NewRequest("GET", "/api/v1/logs?format=csv&monitor=blog", nil))
+	if recorder.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("expected CSV, got %s", recorder.Header().Get("Content-Type"))
+	}
+	expected := "id,timestamp,level,message,attributes\n" +
+		`3,2024-05-01T12:02:00Z,WARN,Monitor is down,"{""monitor"":""blog""}"` + "\n"
+	if recorder.Body.String() != expected {
+		t.Fatalf("unexpected CSV:\n%s", recorder.Body)
+	}
+
+	recorder = httptest.NewRecorder()
+	handlers.HandleListLogs(recorder, httptest.NewRequest("GET", "/api/v1/logs?format=ndjson&level=warn", nil))
+	lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected every warning on its own line, got:\n%s", recorder.Body)
+	}
+	var entry handlers.LogResponse
+	if err := json.Unmarshal([]byte(lines[2]), &entry); err != nil || entry.ID != 2 {
+		t.Fatalf("expected the oldest warning last, got %s: %v", lines[2], err)
+	}
+}
+
+func TestLogTail(t *testing.T) {
+	memory := useMemoryLogs(t)
+
+	server := httptest.NewServer(http.HandlerFunc(websocket.Handle))
+	defer server.Close()
+	conn, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if err := conn.WriteJSON(websocket.Message{API: "logs/tail", Filter: map[string]string{"level": "error"}}); err != nil {
+		t.Fatal(err)
+	}
+	var event struct {
+		API     string               `json:"api"`
+		Payload handlers.LogResponse `json:"payload"`
+	}
+	if err := conn.ReadJSON(&event); err != nil || event.API != "logs/tail" {
+		t.Fatalf("expected the subscription to be confirmed, got %+v: %v", event, err)
+	}
+
+	memory.InsertLog(db.LogEntry{Timestamp: time.Now().Format(time.RFC3339Nano), Level: "INFO", Message: "Monitor is up"})
+	memory.InsertLog(db.LogEntry{Timestamp: time.Now().Format(time.RFC3339Nano), Level: "ERROR", Message: "Failed to store monitor"})
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatal(err)
+	}
+	if event.API != "logs/entry" || event.Payload.Message != "Failed to store monitor" || event.Payload.ID != 7 {
+		t.Fatalf("expected only the new error, got %+v", event)
+	}
+}
@@ -0,0 +1,207 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/db"
+	"iammati/statuspage/handlers"
+	"iammati/statuspage/incidents"
+	"iammati/statuspage/maintenance"
+	"iammati/statuspage/scheduler"
+	"iammati/statuspage/store"
+	"iammati/statuspage/uptime"
+	"iammati/statuspage/utils"
+)
+
+func TestMaintenanceOccurrences(t *testing.T) {
+	start := time.Date(2024, 11, 18, 0, 0, 0, 0, time.UTC) // a Monday
+
+	oneOff := db.MaintenanceWindow{StartsAt: start.Add(2 * time.Hour), EndsAt: start.Add(4 * time.Hour)}
+	if occurrences, _ := maintenance.Occurrences(oneOff, start, start.Add(3*time.Hour)); len(occurrences) != 1 {
+		t.Fatalf("expected the one-off window to overlap, got %+v", occurrences)
+	}
+
+	cron := db.MaintenanceWindow{StartsAt: start, Schedule: "0 2 * * *", Duration: time.Hour}
+	occurrences, err := maintenance.Occurrences(cron, start.Add(150*time.Minute), start.Add(3*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(occurrences) != 3 || !occurrences[0].Start.Equal(start.Add(2*time.Hour)) {
+		t.Fatalf("expected 3 nightly occurrences starting with the running one, got %+v", occurrences)
+	}
+
+	rrule := db.MaintenanceWindow{StartsAt: start.Add(22 * time.Hour), Schedule: "RRULE:FREQ=WEEKLY;BYDAY=MO,TH;COUNT=3", Duration: 2 * time.Hour}
+	occurrences, err = maintenance.Occurrences(rrule, start, start.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(occurrences) != 3 || occurrences[1].Start.Weekday() != time.Thursday {
+		t.Fatalf("expected Monday, Thursday, Monday, got %+v", occurrences)
+	}
+	// A month of minutely windows is cut short, but says so.
+	minutely := db.MaintenanceWindow{StartsAt: start, Schedule: "* * * * *", Duration: 30 * time.Second}
+	occurrences, err = maintenance.Occurrences(minutely, start, start.AddDate(0, 1, 0))
+	if !errors.Is(err, maintenance.ErrTooManyOccurrences) || len(occurrences) != 10000 {
+		t.Fatalf("expected the first 10000 occurrences and ErrTooManyOccurrences, got %d and %v", len(occurrences), err)
+	}
+}
+
+func TestMaintenanceValidate(t *testing.T) {
+	now := time.Now()
+	invalid := []db.MaintenanceWindow{
+		{Monitors: []string{"shop"}, StartsAt: now, EndsAt: now.Add(time.Hour)},
+		{Title: "Deploy", StartsAt: now, EndsAt: now.Add(time.Hour)},
+		{Title: "Deploy", Monitors: []string{"shop"}, StartsAt: now, EndsAt: now},
+		{Title: "Deploy", Monitors: []string{"shop"}, StartsAt: now, Schedule: "not a schedule", Duration: time.Hour},
+		{Title: "Deploy", Monitors: []string{"shop"}, StartsAt: now, Schedule: "0 2 * * *"},
+	}
+	for _, window := range invalid {
+		if err := maintenance.Validate(window); !errors.Is(err, maintenance.ErrInvalidWindow) {
+			t.Errorf("expected %+v to be invalid, got %v", window, err)
+		}
+	}
+}
+
+func TestMaintenanceManager(t *testing.T) {
+	memory := store.NewMemory()
+	memory.UpsertComponent(db.Component{Name: "Checkout", Monitors: []string{"shop", "payments"}})
+	manager := maintenance.NewManager(memory)
+	now := time.Now()
+
+	window, err := manager.Create(db.MaintenanceWindow{
+		Title:      "Database upgrade",
+		Components: []string{"Checkout"},
+		StartsAt:   now.Add(-time.Hour),
+		EndsAt:     now.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !manager.UnderMaintenance("payments", now) || manager.UnderMaintenance("blog", now) {
+		t.Fatal("expected only the monitors of the component to be under maintenance")
+	}
+	if manager.UnderMaintenance("payments", now.Add(2*time.Hour)) {
+		t.Fatal("expected the maintenance to be over after it ends")
+	}
+
+	if err := manager.Delete(window.ID); err != nil {
+		t.Fatal(err)
+	}
+	if manager.UnderMaintenance("payments", now) {
+		t.Fatal("expected deleted windows to be ignored")
+	}
+}
+
+func TestUptimeExcludesMaintenance(t *testing.T) {
+	start := time.Date(2024, 11, 21, 0, 0, 0, 0, time.UTC)
+	transitions := []db.StateTransition{
+		{At: start, Up: true},
+		{At: start.Add(10 * time.Hour), Up: false},
+		{At: start.Add(12 * time.Hour), Up: true},
+	}
+	excluded := maintenance.Merge([]maintenance.Interval{
+		{Start: start.Add(9 * time.Hour), End: start.Add(11 * time.Hour)},
+		{Start: start.Add(10 * time.Hour), End: start.Add(12 * time.Hour)},
+	})
+
+	availability := uptime.Compute(transitions, start, start.Add(24*time.Hour), excluded...)
+	if availability.Down != 0 || availability.Up != 21*time.Hour {
+		t.Fatalf("expected the downtime to be excluded, got %+v", availability)
+	}
+}
+
+func TestMaintenanceReplaysDownMonitors(t *testing.T) {
+	memory := store.NewMemory()
+	previousStore, previousMaintenance, previousIncidents := config.Store, handlers.Maintenance, handlers.Incidents
+	defer func() {
+		config.Store, handlers.Maintenance, handlers.Incidents = previousStore, previousMaintenance, previousIncidents
+	}()
+	config.Store = memory
+	handlers.Maintenance = maintenance.NewManager(memory)
+	handlers.Incidents = incidents.NewManager(memory, nil)
+
+	now := time.Now()
+	if _, err := handlers.Maintenance.Create(db.MaintenanceWindow{
+		Title:    "Deploy",
+		Monitors: []string{"maintenance-shop"},
+		StartsAt: now.Add(-time.Hour),
+		EndsAt:   now.Add(200 * time.Millisecond),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	handlers.RecordProbe(scheduler.Result{
+		Monitor: scheduler.Monitor{Name: "maintenance-shop", Host: "shop.example.com"},
+		Metrics: utils.Metrics{Reachable: false},
+		Started: now,
+	})
+	down := handlers.Transition{Monitor: "maintenance-shop", At: now, Initial: true}
+	handlers.TrackMaintenanceTransition(down)
+	handlers.HandleIncidentTransition(down)
+
+	active := func() []incidents.Incident {
+		list, err := handlers.Incidents.List(db.IncidentFilter{Monitor: "maintenance-shop", Active: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return list
+	}
+	if len(active()) != 0 {
+		t.Fatal("expected no incident while under maintenance")
+	}
+	if !handlers.MonitorStates()["maintenance-shop"].Maintenance {
+		t.Fatal("expected the monitor state to report the maintenance")
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go handlers.ReplayMaintenanceTransitions(20*time.Millisecond, stop)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(active()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected an incident once the maintenance ended with the monitor still down")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// countingStore counts how often the components are loaded.
+type countingStore struct {
+	*store.Memory
+	components int
+}
+
+func (s *countingStore) Components() ([]db.Component, error) {
+	s.components++
+	return s.Memory.Components()
+}
+
+func TestMaintenanceIntervalsLoadComponentsOnce(t *testing.T) {
+	counting := &countingStore{Memory: store.NewMemory()}
+	counting.UpsertComponent(db.Component{Name: "Checkout", Monitors: []string{"shop"}})
+	manager := maintenance.NewManager(counting)
+	now := time.Now()
+
+	if _, err := manager.Create(db.MaintenanceWindow{
+		Title:      "Nightly backup",
+		Components: []string{"Checkout"},
+		StartsAt:   now.Add(-60 * 24 * time.Hour),
+		Schedule:   "0 2 * * *",
+		Duration:   time.Hour,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	intervals := manager.Intervals("shop", now.Add(-30*24*time.Hour), now)
+	if len(intervals) < 29 {
+		t.Fatalf("expected a nightly interval, got %d", len(intervals))
+	}
+	if counting.components != 1 {
+		t.Fatalf("expected the components to be loaded once, got %d", counting.components)
+	}
+}
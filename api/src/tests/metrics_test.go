@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/handlers"
+	"iammati/statuspage/scheduler"
+	"iammati/statuspage/store"
+	"iammati/statuspage/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func TestMetricsProbes(t *testing.T) {
+	previous := config.Store
+	config.Store = store.NewMemory()
+	defer func() { config.Store = previous }()
+
+	handlers.RecordProbe(scheduler.Result{
+		Monitor: scheduler.Monitor{Name: "metrics-shop", Host: "shop.example.com"},
+		Metrics: utils.Metrics{
+			DnsResolutionTime: 20 * time.Millisecond,
+			TcpConnectionTime: 40 * time.Millisecond,
+			TlsConnectionTime: 80 * time.Millisecond,
+			HttpTime:          300 * time.Millisecond,
+			StatusCode:        200,
+			Reachable:         true,
+		},
+		Started: time.Now(),
+	})
+
+	registry := prometheus.NewRegistry()
+	handlers.RegisterMetrics(registry)
+	recorder := httptest.NewRecorder()
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := recorder.Body.String()
+	for _, expected := range []string{
+		`statuspage_probe_dns_resolution_seconds_bucket{monitor="metrics-shop",le="0.025"} 1`,
+		`statuspage_probe_tls_connection_seconds_bucket{monitor="metrics-shop",le="0.05"} 0`,
+		`statuspage_probe_http_seconds_count{monitor="metrics-shop"} 1`,
+		`statuspage_probe_status_code{monitor="metrics-shop"} 200`,
+		`statuspage_probes_total{monitor="metrics-shop",result="up"} 1`,
+		`statuspage_monitor_up{monitor="metrics-shop"} 1`,
+		`statuspage_monitor_flapping{monitor="metrics-shop"} 0`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q in the metrics:\n%s", expected, body)
+		}
+	}
+}
+
+func TestMetricsUntrustedCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+
+	// The self-signed certificate of the test server isn't trusted, yet its
+	// expiry is still exported.
+	handlers.CheckCertificate("metrics-untrusted", server.Listener.Addr().String(), time.Second)
+
+	registry := prometheus.NewRegistry()
+	handlers.RegisterMetrics(registry)
+	recorder := httptest.NewRecorder()
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	if expected := `statuspage_certificate_expiry_seconds{monitor="metrics-untrusted"}`; !strings.Contains(recorder.Body.String(), expected) {
+		t.Errorf("expected %q in the metrics:\n%s", expected, recorder.Body.String())
+	}
+}
+
+func TestMetricsForgetRemovedMonitors(t *testing.T) {
+	previousStore, previousScheduler := config.Store, handlers.Scheduler
+	config.Store = store.NewMemory()
+	handlers.Scheduler = scheduler.New(scheduler.Options{})
+	defer func() { config.Store, handlers.Scheduler = previousStore, previousScheduler }()
+
+	registry := handlers.MonitorRegistry{}
+	monitor := scheduler.Monitor{Name: "metrics-removed", Host: "removed.example.com"}
+	registry.Upsert(monitor)
+	handlers.RecordProbe(scheduler.Result{Monitor: monitor, Metrics: utils.Metrics{Reachable: true}, Started: time.Now()})
+	if _, ok := handlers.MonitorStates()["metrics-removed"]; !ok {
+		t.Fatal("expected the probed monitor to have a state")
+	}
+
+	registry.Remove("metrics-removed")
+	if _, ok := handlers.MonitorStates()["metrics-removed"]; ok {
+		t.Fatal("expected the state of a removed monitor to be dropped")
+	}
+
+	prometheusRegistry := prometheus.NewRegistry()
+	handlers.RegisterMetrics(prometheusRegistry)
+	recorder := httptest.NewRecorder()
+	promhttp.HandlerFor(prometheusRegistry, promhttp.HandlerOpts{}).ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if strings.Contains(recorder.Body.String(), `monitor="metrics-removed"`) {
+		t.Errorf("expected no series of the removed monitor:\n%s", recorder.Body.String())
+	}
+}
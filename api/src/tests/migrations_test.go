@@ -0,0 +1,24 @@
+package tests
+
+import (
+	"testing"
+
+	"iammati/statuspage/db"
+	db_migrations "iammati/statuspage/db/migrations"
+)
+
+func TestMigrationsAreOrderedAndReversible(t *testing.T) {
+	if err := db.ValidateMigrations(db_migrations.All); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateMigrationsRejectsOutOfOrderSteps(t *testing.T) {
+	err := db.ValidateMigrations([]db_migrations.Migration{
+		{Version: 2, Name: "second", Up: "SELECT 1", Down: "SELECT 1"},
+		{Version: 1, Name: "first", Up: "SELECT 1", Down: "SELECT 1"},
+	})
+	if err == nil {
+		t.Fatal("expected out of order migrations to be rejected")
+	}
+}
@@ -0,0 +1,194 @@
+package tests
+
+import (
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/handlers"
+	"iammati/statuspage/monitors"
+	"iammati/statuspage/scheduler"
+	"iammati/statuspage/store"
+	"iammati/statuspage/utils"
+)
+
+func TestMonitorsFileResolvesDefaults(t *testing.T) {
+	file, err := monitors.Parse("monitors.yaml", []byte(`
+defaults:
+  interval: 30s
+  expectedStatus: [200]
+groups:
+  - name: web
+    interval: 1m
+    slo: 99.5
+monitors:
+  - name: shop
+    host: shop.example.com
+    port: 8443
+    path: /health
+    group: web
+  - host: blog.example.com
+    timeout: 2s
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resolved := file.Resolve()
+	if len(resolved) != 2 {
+		t.Fatalf("expected 2 monitors, got %d", len(resolved))
+	}
+
+	shop := resolved[0]
+	if shop.Host != "shop.example.com:8443" || shop.Interval != time.Minute || shop.ExpectedStatus[0] != 200 || shop.SLOTarget != 99.5 {
+		t.Fatalf("unexpected shop monitor: %+v", shop)
+	}
+
+	blog := resolved[1]
+	if blog.Name != "blog.example.com" || blog.Interval != 30*time.Second || blog.Timeout != 2*time.Second || blog.SLOTarget != 0 {
+		t.Fatalf("unexpected blog monitor: %+v", blog)
+	}
+}
+
+func TestMonitorsFileReportsLineNumbers(t *testing.T) {
+	_, err := monitors.Parse("monitors.yaml", []byte(`monitors:
+  - name: shop
+    host: shop.example.com
+  - name: shop
+    host: shop.example.com
+    group: missing
+  - path: health
+    type: tcp
+components:
+  - name: Shop
+    monitors: [shop, checkout]
+`))
+
+	var validationErr *monitors.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+
+	message := err.Error()
+	for _, expected := range []string{
+		"line 4: duplicate monitor 'shop'",
+		"line 4: unknown group 'missing'",
+		"line 7: monitor is missing a host",
+		"line 7: path 'health' must start with '/'",
+		"line 7: unknown type 'tcp', must be http or certificate",
+		"line 10: unknown monitor 'checkout'",
+	} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("expected %q in:\n%s", expected, message)
+		}
+	}
+}
+
+func TestMonitorsWatcherKeepsMonitorsOnInvalidReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monitors.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sched := scheduler.New(scheduler.Options{})
+	sched.Upsert(scheduler.Monitor{Name: "adhoc", Host: "adhoc.example.com"})
+	watcher := monitors.NewWatcher(path, sched)
+
+	write("monitors:\n  - host: a.example.com\n  - host: b.example.com\n")
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sched.Monitors()) != 3 {
+		t.Fatalf("expected 3 monitors, got %d", len(sched.Monitors()))
+	}
+
+	write("monitors:\n  - host: a.example.com\n    interval: nope\n")
+	if err := watcher.Reload(); err == nil {
+		t.Fatal("expected the invalid file to be rejected")
+	}
+	if len(sched.Monitors()) != 3 {
+		t.Fatalf("expected the previous monitors to be kept, got %d", len(sched.Monitors()))
+	}
+
+	write("monitors:\n  - host: a.example.com\n")
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sched.Monitors()) != 2 {
+		t.Fatalf("expected b.example.com to be removed and adhoc kept, got %+v", sched.Monitors())
+	}
+}
+
+func TestMonitorsFileProbesDeclaredPort(t *testing.T) {
+	var requested string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+	}))
+	defer server.Close()
+
+	// Trust the certificate of the test server.
+	certDir := t.TempDir()
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(filepath.Join(certDir, "server.crt"), certificate, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	previous := config.AppSettings.CACertDir
+	config.AppSettings.CACertDir = certDir
+	defer func() { config.AppSettings.CACertDir = previous }()
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	file, err := monitors.Parse("monitors.yaml", []byte("monitors:\n  - host: 127.0.0.1\n    port: "+port+"\n    path: /health\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	monitor := file.Resolve()[0]
+
+	metrics, err := utils.HostMetricsWithTimeout(monitor.Host, monitor.Path, time.Second)
+	if err != nil || !metrics.Reachable || metrics.StatusCode != http.StatusOK {
+		t.Fatalf("expected the declared port to be probed, got %+v, %v", metrics, err)
+	}
+	if requested != "/health" {
+		t.Fatalf("expected /health to be requested, got %q", requested)
+	}
+}
+
+func TestMonitorRegistryKeepsMonitorsDeclaredElsewhere(t *testing.T) {
+	previousStore, previousScheduler := config.Store, handlers.Scheduler
+	config.Store = store.NewMemory()
+	handlers.Scheduler = scheduler.New(scheduler.Options{})
+	defer func() { config.Store, handlers.Scheduler = previousStore, previousScheduler }()
+
+	file := handlers.MonitorRegistry{Source: handlers.SourceFile}
+	discovered := handlers.MonitorRegistry{Source: handlers.SourceDiscovery}
+	declared := scheduler.Monitor{Host: "registry.example.com", Interval: 45 * time.Second, Group: "web"}
+
+	file.Upsert(declared)
+	discovered.Upsert(scheduler.Monitor{Host: "registry.example.com"})
+	if monitor := scheduledMonitor(handlers.Scheduler, "registry.example.com"); monitor.Interval != 45*time.Second || monitor.Group != "web" {
+		t.Fatalf("expected the file to take precedence over discovery, got %+v", monitor)
+	}
+
+	// The last pod exposing the host is gone.
+	discovered.Remove("registry.example.com")
+	if monitor := scheduledMonitor(handlers.Scheduler, "registry.example.com"); monitor.Interval != 45*time.Second {
+		t.Fatalf("expected the declared monitor to stay scheduled, got %+v", monitor)
+	}
+	if stored, err := config.Store.Monitors(); err != nil || len(stored) != 1 {
+		t.Fatalf("expected the declared monitor to stay stored, got %+v, %v", stored, err)
+	}
+
+	file.Remove("registry.example.com")
+	if len(handlers.Scheduler.Monitors()) != 0 {
+		t.Fatalf("expected the monitor to be removed with its last source, got %+v", handlers.Scheduler.Monitors())
+	}
+}
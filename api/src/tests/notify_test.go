@@ -0,0 +1,377 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/db"
+	"iammati/statuspage/handlers"
+	"iammati/statuspage/incidents"
+	"iammati/statuspage/notify"
+	"iammati/statuspage/store"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	var verified atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get(notify.TimestampHeader)
+		verified.Store(notify.Verify("s3cret", timestamp, body, r.Header.Get(notify.SignatureHeader)) &&
+			r.Header.Get(notify.EventHeader) == notify.EventMonitorDown &&
+			r.Header.Get(notify.DeliveryHeader) == "1")
+	}))
+	defer server.Close()
+
+	dispatcher := notify.NewDispatcher(store.NewMemory(), notify.Options{Backoff: time.Millisecond, Timeout: time.Second})
+	dispatcher.Add(notify.NewWebhook("ops", server.URL, "s3cret"))
+	dispatcher.Dispatch(notify.Event{Type: notify.EventMonitorDown, At: time.Now(), Monitor: &notify.Monitor{Name: "shop"}})
+	dispatcher.Wait()
+
+	if !verified.Load() {
+		t.Fatal("expected a signed webhook carrying the event and delivery id")
+	}
+	if notify.Verify("other", "1", []byte("{}"), notify.Sign("s3cret", "1", []byte("{}"))) {
+		t.Fatal("expected a signature made with another secret to be rejected")
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	memory := store.NewMemory()
+	dispatcher := notify.NewDispatcher(memory, notify.Options{Retries: 5, Backoff: time.Millisecond, Timeout: time.Second})
+	webhook := notify.NewWebhook("ops", server.URL, "")
+
+	delivery, err := dispatcher.Deliver(webhook, notify.Event{Type: notify.EventTest}, 5)
+	if err != nil {
+		t.Fatalf("expected the third attempt to succeed, got %v", err)
+	}
+	if delivery.Attempts != 3 || delivery.Status != db.DeliveryDelivered {
+		t.Fatalf("expected a delivery after 3 attempts, got %+v", delivery)
+	}
+
+	logged, _ := memory.NotificationDeliveries(db.DeliveryFilter{Channel: "ops"})
+	if len(logged) != 1 || logged[0].Attempts != 3 || logged[0].Status != db.DeliveryDelivered {
+		t.Fatalf("expected the delivery log to match, got %+v", logged)
+	}
+}
+
+func TestWebhookPermanentFailure(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "no such hook", http.StatusGone)
+	}))
+	defer server.Close()
+
+	memory := store.NewMemory()
+	dispatcher := notify.NewDispatcher(memory, notify.Options{Backoff: time.Millisecond, Timeout: time.Second})
+	delivery, err := dispatcher.Deliver(notify.NewWebhook("ops", server.URL, ""), notify.Event{Type: notify.EventTest}, 5)
+	if err == nil || requests.Load() != 1 {
+		t.Fatalf("expected a single rejected attempt, got %d and %v", requests.Load(), err)
+	}
+	if delivery.Status != db.DeliveryFailed || delivery.ResponseCode != http.StatusGone || delivery.LastError == "" {
+		t.Fatalf("expected the failure to be recorded, got %+v", delivery)
+	}
+}
+
+func TestNotificationEventFilter(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	settings := config.DefaultSettings().Notifications
+	settings.Channels = []config.ChannelSettings{
+		{Name: "incidents", Type: config.ChannelWebhook, URL: server.URL, Events: []string{notify.EventIncidentOpened}},
+	}
+	dispatcher, err := notify.FromSettings(store.NewMemory(), settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Dispatch(notify.Event{Type: notify.EventMonitorDown})
+	dispatcher.Dispatch(notify.Event{Type: notify.EventIncidentOpened})
+	dispatcher.Wait()
+
+	if requests.Load() != 1 {
+		t.Fatalf("expected only the subscribed event to be sent, got %d requests", requests.Load())
+	}
+
+	settings.Channels[0].Events = []string{"monitor.exploded"}
+	if _, err := notify.FromSettings(store.NewMemory(), settings); err == nil {
+		t.Fatal("expected unknown events to be rejected")
+	}
+}
+
+func TestChatPayloads(t *testing.T) {
+	event := notify.Event{
+		Type: notify.EventMonitorDown,
+		At:   time.Date(2024, 11, 18, 9, 30, 0, 0, time.UTC),
+		URL:  notify.IncidentURL("https://status.example.com/incidents/{id}", 7),
+		Monitor: &notify.Monitor{
+			Name:  "shop",
+			Probe: &notify.Probe{TLSConnection: 40 * time.Millisecond, StatusCode: 502, Error: "bad gateway"},
+		},
+	}
+	summary := notify.Summarize(event)
+
+	expected := map[string][]string{
+		config.ChannelSlack:      {`"color":"#d92d20"`, `"title_link":"https://status.example.com/incidents/7"`, `"title":"TLS","value":"40ms"`},
+		config.ChannelMattermost: {`"username":"Status page"`, `"title":"shop is down"`},
+		config.ChannelDiscord:    {`"color":14232864`, `"url":"https://status.example.com/incidents/7"`, `"name":"Status code","value":"502"`},
+		config.ChannelTeams:      {`"application/vnd.microsoft.card.adaptive"`, `"color":"Attention"`, `"title":"Error","value":"bad gateway"`},
+	}
+	for format, fragments := range expected {
+		payload, err := notify.ChatPayload(format, summary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, _ := json.Marshal(payload)
+		for _, fragment := range fragments {
+			if !strings.Contains(string(encoded), fragment) {
+				t.Errorf("expected %s in the %s payload: %s", fragment, format, encoded)
+			}
+		}
+	}
+
+	if _, err := notify.ChatPayload("irc", summary); err == nil {
+		t.Fatal("expected unknown formats to be rejected")
+	}
+}
+
+func TestChatSend(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	chat := notify.NewChat("discord", config.ChannelDiscord, server.URL)
+	dispatcher := notify.NewDispatcher(store.NewMemory(), notify.Options{Timeout: time.Second})
+	if _, err := dispatcher.Deliver(chat, notify.Event{Type: notify.EventTest, Message: "Hello"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if embeds, ok := received["embeds"].([]interface{}); !ok || len(embeds) != 1 {
+		t.Fatalf("expected a single embed, got %v", received)
+	}
+}
+
+type recordingChannel struct {
+	name   string
+	mu     sync.Mutex
+	events []string
+}
+
+func (c *recordingChannel) Name() string { return c.name }
+func (c *recordingChannel) Type() string { return "recording" }
+
+func (c *recordingChannel) Send(ctx context.Context, event notify.Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, event.Type)
+	return nil
+}
+
+func (c *recordingChannel) received() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.events)
+}
+
+func newRecordingDispatcher(names ...string) (*notify.Dispatcher, map[string]*recordingChannel) {
+	dispatcher := notify.NewDispatcher(store.NewMemory(), notify.Options{Timeout: time.Second})
+	channels := make(map[string]*recordingChannel)
+	for _, name := range names {
+		channels[name] = &recordingChannel{name: name}
+		dispatcher.Add(channels[name])
+	}
+	return dispatcher, channels
+}
+
+func TestNotificationRouting(t *testing.T) {
+	dispatcher, channels := newRecordingDispatcher("pager", "chat", "ops")
+	router := notify.NewRouter(dispatcher, []config.RouteSettings{
+		{Name: "critical", Match: config.RouteMatch{Severities: []string{db.SeverityCritical}}, Channels: []string{"pager"}, Continue: true},
+		{Name: "payments", Match: config.RouteMatch{Tags: []string{"payments"}}, Channels: []string{"chat"}},
+		{Name: "everything else", Channels: []string{"ops"}},
+	})
+	router.Lookup = func(monitor string) (string, []string) {
+		if monitor == "checkout" {
+			return "shop", []string{"payments"}
+		}
+		return "", nil
+	}
+
+	router.Handle(notify.Event{Type: notify.EventMonitorDown, Severity: db.SeverityMajor, Monitor: &notify.Monitor{Name: "api", Tags: []string{"payments"}}})
+	router.Handle(notify.Event{Type: notify.EventIncidentOpened, Incident: &incidents.Incident{ID: 1, Severity: db.SeverityCritical, Monitors: []string{"checkout"}}})
+	router.Handle(notify.Event{Type: notify.EventMonitorDown, Severity: db.SeverityMinor, Monitor: &notify.Monitor{Name: "blog"}})
+	dispatcher.Wait()
+
+	if received := channels["pager"].received(); !slices.Equal(received, []string{notify.EventIncidentOpened}) {
+		t.Errorf("expected the pager to only get the critical incident, got %v", received)
+	}
+	if received := channels["chat"].received(); len(received) != 2 {
+		t.Errorf("expected the chat to get both payments events, got %v", received)
+	}
+	if received := channels["ops"].received(); !slices.Equal(received, []string{notify.EventMonitorDown}) {
+		t.Errorf("expected ops to get the remaining event, got %v", received)
+	}
+}
+
+func TestNotificationEscalation(t *testing.T) {
+	dispatcher, channels := newRecordingDispatcher("chat", "pager")
+	router := notify.NewRouter(dispatcher, []config.RouteSettings{{
+		Name:           "on-call",
+		Channels:       []string{"chat"},
+		Escalation:     []config.EscalationStep{{After: 10 * time.Minute, Channels: []string{"pager"}}},
+		RepeatInterval: 30 * time.Minute,
+	}})
+	now := time.Date(2024, 11, 18, 9, 0, 0, 0, time.UTC)
+	router.Now = func() time.Time { return now }
+	tick := func(elapsed time.Duration) {
+		now = now.Add(elapsed)
+		router.Tick()
+		dispatcher.Wait()
+	}
+
+	shop := &notify.Monitor{Name: "shop"}
+	router.Handle(notify.Event{Type: notify.EventMonitorDown, Monitor: shop})
+	router.Handle(notify.Event{Type: notify.EventMonitorFlapping, Monitor: shop})
+	tick(5 * time.Minute)
+	if chat, pager := channels["chat"].received(), channels["pager"].received(); len(chat) != 1 || len(pager) != 0 {
+		t.Fatalf("expected a single deduplicated message, got %v and %v", chat, pager)
+	}
+
+	tick(5 * time.Minute)
+	if pager := channels["pager"].received(); len(pager) != 1 {
+		t.Fatalf("expected the alert to escalate after 10 minutes, got %v", pager)
+	}
+
+	tick(30 * time.Minute)
+	if chat, pager := channels["chat"].received(), channels["pager"].received(); len(chat) != 2 || len(pager) != 2 {
+		t.Fatalf("expected the alert to repeat to everyone, got %v and %v", chat, pager)
+	}
+
+	alerts := router.Alerts()
+	if len(alerts) != 1 || alerts[0].Suppressed != 1 {
+		t.Fatalf("expected one open alert with a suppressed event, got %+v", alerts)
+	}
+	if _, err := router.Acknowledge(alerts[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	tick(time.Hour)
+	if chat := channels["chat"].received(); len(chat) != 2 {
+		t.Fatalf("expected no repeats once acknowledged, got %v", chat)
+	}
+
+	router.Handle(notify.Event{Type: notify.EventMonitorUp, Monitor: shop})
+	dispatcher.Wait()
+	if chat, pager := channels["chat"].received(), channels["pager"].received(); chat[2] != notify.EventMonitorUp || pager[2] != notify.EventMonitorUp {
+		t.Fatalf("expected the recovery to reach every notified channel, got %v and %v", chat, pager)
+	}
+	if alerts := router.Alerts(); len(alerts) != 0 {
+		t.Fatalf("expected the alert to be closed, got %+v", alerts)
+	}
+}
+
+func TestNotificationIgnoresResolvedIncidents(t *testing.T) {
+	memory := store.NewMemory()
+	previousIncidents, previousAlerts := handlers.Incidents, handlers.Alerts
+	defer func() { handlers.Incidents, handlers.Alerts = previousIncidents, previousAlerts }()
+	handlers.Incidents = incidents.NewManager(memory, nil)
+
+	dispatcher, channels := newRecordingDispatcher("pager", "ops")
+	handlers.Alerts = notify.NewRouter(dispatcher, []config.RouteSettings{
+		{Name: "critical", Match: config.RouteMatch{Severities: []string{db.SeverityCritical}}, Channels: []string{"pager"}},
+		{Name: "everything else", Channels: []string{"ops"}},
+	})
+
+	// A critical outage resolved last month must not page for today's flap.
+	now := time.Now()
+	memory.CreateIncident(db.Incident{
+		Title: "shop is down", Status: db.IncidentResolved, Severity: db.SeverityCritical, Monitors: []string{"severity-shop"},
+		StartedAt: now.Add(-30 * 24 * time.Hour), ResolvedAt: now.Add(-29 * 24 * time.Hour),
+	})
+	handlers.HandleMonitorTransition(handlers.Transition{Monitor: "severity-shop", Flapping: true, At: now})
+	dispatcher.Wait()
+
+	if received := channels["pager"].received(); len(received) != 0 {
+		t.Errorf("expected no page, got %v", received)
+	}
+	if received := channels["ops"].received(); !slices.Equal(received, []string{notify.EventMonitorFlapping}) {
+		t.Errorf("expected ops to get the flapping event, got %v", received)
+	}
+
+	// The incident covering a monitor going down decides its severity.
+	if _, err := handlers.Incidents.Create("Payments outage", db.SeverityCritical, []string{"severity-shop"}, "", "ops"); err != nil {
+		t.Fatal(err)
+	}
+	handlers.HandleMonitorTransition(handlers.Transition{Monitor: "severity-shop", At: now.Add(time.Minute)})
+	dispatcher.Wait()
+	if received := channels["pager"].received(); !slices.Equal(received, []string{notify.EventMonitorDown}) {
+		t.Errorf("expected the critical incident to page, got %v", received)
+	}
+}
+
+func TestNotificationDeduplicatesIncidentMonitors(t *testing.T) {
+	dispatcher, channels := newRecordingDispatcher("pager")
+	router := notify.NewRouter(dispatcher, []config.RouteSettings{{
+		Name:           "on-call",
+		Channels:       []string{"pager"},
+		RepeatInterval: 30 * time.Minute,
+	}})
+	now := time.Date(2024, 11, 18, 9, 0, 0, 0, time.UTC)
+	router.Now = func() time.Time { return now }
+
+	incident := &incidents.Incident{ID: 7, Monitors: []string{"shop", "api"}}
+	router.Handle(notify.Event{Type: notify.EventIncidentOpened, Incident: incident})
+	router.Handle(notify.Event{Type: notify.EventMonitorDown, Monitor: &notify.Monitor{Name: "shop"}, IncidentID: 7})
+	router.Handle(notify.Event{Type: notify.EventMonitorDown, Monitor: &notify.Monitor{Name: "api"}, IncidentID: 7})
+	dispatcher.Wait()
+	if received := channels["pager"].received(); !slices.Equal(received, []string{notify.EventIncidentOpened}) {
+		t.Fatalf("expected a single page for the outage, got %v", received)
+	}
+
+	alerts := router.Alerts()
+	if len(alerts) != 1 || alerts[0].Suppressed != 2 {
+		t.Fatalf("expected one alert with the monitor events suppressed, got %+v", alerts)
+	}
+	if _, err := router.Acknowledge(alerts[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// A monitor recovering while the incident is still open keeps the alert.
+	router.Handle(notify.Event{Type: notify.EventMonitorUp, Monitor: &notify.Monitor{Name: "shop", Up: true}, IncidentID: 7})
+	now = now.Add(time.Hour)
+	router.Tick()
+	dispatcher.Wait()
+	if received := channels["pager"].received(); len(received) != 2 || received[1] != notify.EventMonitorUp {
+		t.Fatalf("expected the recovery as an update and no repeats once acknowledged, got %v", received)
+	}
+	if alerts := router.Alerts(); len(alerts) != 1 {
+		t.Fatalf("expected the alert to stay open, got %+v", alerts)
+	}
+
+	router.Handle(notify.Event{Type: notify.EventIncidentResolved, Incident: incident})
+	if alerts := router.Alerts(); len(alerts) != 0 {
+		t.Fatalf("expected the resolved incident to close the alert, got %+v", alerts)
+	}
+}
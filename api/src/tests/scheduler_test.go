@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"iammati/statuspage/scheduler"
+	"iammati/statuspage/utils"
+)
+
+func TestSchedulerProbesRegisteredMonitors(t *testing.T) {
+	var mu sync.Mutex
+	probed := map[string]int{}
+
+	sched := scheduler.New(scheduler.Options{
+		Workers: 2,
+		Probe: func(host string, path string, timeout time.Duration) (utils.Metrics, error) {
+			return utils.Metrics{Reachable: true, StatusCode: 200}, nil
+		},
+		Handler: func(result scheduler.Result) {
+			mu.Lock()
+			defer mu.Unlock()
+			probed[result.Monitor.Host]++
+		},
+	})
+
+	sched.Upsert(scheduler.Monitor{Host: "a.example.com", Interval: 20 * time.Millisecond})
+	sched.Upsert(scheduler.Monitor{Host: "b.example.com", Interval: 20 * time.Millisecond})
+	sched.Start()
+	time.Sleep(150 * time.Millisecond)
+	sched.Remove("b.example.com")
+	sched.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, host := range []string{"a.example.com", "b.example.com"} {
+		if probed[host] < 2 {
+			t.Fatalf("expected %s to be probed repeatedly, got %d runs", host, probed[host])
+		}
+	}
+	if len(sched.Monitors()) != 1 {
+		t.Fatalf("expected 1 remaining monitor, got %d", len(sched.Monitors()))
+	}
+}
+
+func TestSchedulerBoundsConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	running, peak := 0, 0
+
+	sched := scheduler.New(scheduler.Options{
+		Workers: 2,
+		Probe: func(host string, path string, timeout time.Duration) (utils.Metrics, error) {
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return utils.Metrics{Reachable: true}, nil
+		},
+	})
+
+	for _, host := range []string{"a", "b", "c", "d", "e", "f"} {
+		sched.Upsert(scheduler.Monitor{Host: host, Interval: 5 * time.Millisecond})
+	}
+	sched.Start()
+	time.Sleep(100 * time.Millisecond)
+	sched.Stop()
+
+	if peak > 2 {
+		t.Fatalf("expected at most 2 concurrent probes, got %d", peak)
+	}
+}
+
+func TestSchedulerProbesCertificateMonitors(t *testing.T) {
+	var mu sync.Mutex
+	probes := map[string]string{}
+
+	probe := func(kind string) scheduler.ProbeFunc {
+		return func(host string, path string, timeout time.Duration) (utils.Metrics, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			probes[host] = kind
+			return utils.Metrics{Reachable: true}, nil
+		}
+	}
+	sched := scheduler.New(scheduler.Options{Probe: probe("http"), CertificateProbe: probe("certificate")})
+
+	sched.Upsert(scheduler.Monitor{Host: "a.example.com", Interval: 20 * time.Millisecond})
+	sched.Upsert(scheduler.Monitor{Name: "certificate", Type: scheduler.TypeCertificate, Host: "b.example.com", Interval: 20 * time.Millisecond})
+	sched.Start()
+	time.Sleep(100 * time.Millisecond)
+	sched.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if probes["a.example.com"] != "http" || probes["b.example.com"] != "certificate" {
+		t.Fatalf("expected each monitor to be probed by its type, got %v", probes)
+	}
+}
+
+func TestProbeBoundsSilentTLSHandshake(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	// Accept connections but never answer the handshake.
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	started := time.Now()
+	metrics, err := utils.HostMetricsWithTimeout(listener.Addr().String(), "", 200*time.Millisecond)
+	if err == nil || metrics.Reachable {
+		t.Fatal("expected a silent host to fail the probe")
+	}
+	if !strings.Contains(err.Error(), "TLS handshake") {
+		t.Fatalf("expected the handshake to fail, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("expected the handshake to time out, the probe took %s", elapsed)
+	}
+}
@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"iammati/statuspage/config"
+)
+
+func TestSettingsPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.yaml")
+	err := os.WriteFile(path, []byte("http:\n  port: 9090\n  shutdownTimeout: 10s\ndatabase:\n  host: file-db\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(config.SettingsFileEnv, path)
+	t.Setenv("DB_HOST", "env-db")
+	t.Setenv("DB_PASSWORD", "hunter2")
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if settings.HTTP.Port != 9090 || settings.HTTP.ShutdownTimeout != 10*time.Second {
+		t.Fatalf("expected file values, got %+v", settings.HTTP)
+	}
+	if settings.Database.Host != "env-db" {
+		t.Fatalf("expected the environment to override the file, got %s", settings.Database.Host)
+	}
+	if settings.Database.User != "statuspage" {
+		t.Fatalf("expected the default user, got %s", settings.Database.User)
+	}
+
+	redacted := settings.Redacted()
+	if redacted.Database.Password == "hunter2" || settings.Database.Password != "hunter2" {
+		t.Fatal("expected only the copy to be redacted")
+	}
+}
+
+func TestSettingsValidation(t *testing.T) {
+	t.Setenv("HTTP_PORT", "70000")
+	t.Setenv("APP_KEY", "base64:not base64!")
+
+	_, err := config.LoadSettings()
+	if err == nil {
+		t.Fatal("expected invalid settings to be rejected")
+	}
+	for _, expected := range []string{"http.port 70000 is out of range", "appKey is not valid base64"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %v", expected, err)
+		}
+	}
+}
+
+func TestSettingsNotificationChannels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.yaml")
+	err := os.WriteFile(path, []byte("notifications:\n  channels:\n    - name: ops\n      type: webhook\n      url: https://hooks.example.com/ops\n      secret: s3cret\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.SettingsFileEnv, path)
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(settings.Notifications.Channels) != 1 || settings.Notifications.Retries != 5 {
+		t.Fatalf("expected the channel and default retries, got %+v", settings.Notifications)
+	}
+
+	redacted := settings.Redacted()
+	if redacted.Notifications.Channels[0].Secret == "s3cret" || settings.Notifications.Channels[0].Secret != "s3cret" {
+		t.Fatal("expected only the copy of the channel secret to be redacted")
+	}
+
+	settings.Notifications.Channels = append(settings.Notifications.Channels, config.ChannelSettings{Name: "ops", Type: "pager"})
+	if err := settings.Validate(); err == nil || !strings.Contains(err.Error(), "duplicate channel 'ops'") {
+		t.Fatalf("expected duplicate channels to be rejected, got %v", err)
+	}
+}
+
+func TestSettingsNotificationRoutes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.yaml")
+	err := os.WriteFile(path, []byte(`notifications:
+  channels:
+    - name: chat
+      type: slack
+      url: https://hooks.example.com/chat
+    - name: pager
+      type: webhook
+      url: https://hooks.example.com/pager
+  routes:
+    - name: on-call
+      match:
+        severities: [critical]
+      channels: [chat]
+      escalation:
+        - after: 10m
+          channels: [pager]
+      repeatInterval: 1h
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.SettingsFileEnv, path)
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	route := settings.Notifications.Routes[0]
+	if route.Escalation[0].After != 10*time.Minute || route.RepeatInterval != time.Hour {
+		t.Fatalf("expected the durations to be parsed, got %+v", route)
+	}
+
+	settings.Notifications.Routes[0].Escalation = append(route.Escalation, config.EscalationStep{After: 5 * time.Minute, Channels: []string{"phone"}})
+	err = settings.Validate()
+	if err == nil || !strings.Contains(err.Error(), "unknown channel 'phone'") || !strings.Contains(err.Error(), "increase with every step") {
+		t.Fatalf("expected the escalation step to be rejected, got %v", err)
+	}
+}
+
+func TestSettingsDiscoveryTargets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.yaml")
+	err := os.WriteFile(path, []byte(`discovery:
+  targets:
+    - name: web
+      namespaceSelector: team=web
+      podSelectors: [app=shop, "tier in (frontend)"]
+      envVars: [PUBLIC_HOST]
+      monitor:
+        path: /health
+        interval: 30s
+        expectedStatus: [200]
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.SettingsFileEnv, path)
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	target := settings.Discovery.Targets[0]
+	if target.Monitor.Interval != 30*time.Second || len(target.PodSelectors) != 2 || target.EnvVars[0] != "PUBLIC_HOST" {
+		t.Fatalf("expected the target to be parsed, got %+v", target)
+	}
+
+	settings.Discovery.Targets = append(settings.Discovery.Targets, config.DiscoveryTarget{
+		Name:         "web",
+		PodSelectors: []string{"app in (shop"},
+		Monitor:      config.MonitorTemplate{Path: "health"},
+	})
+	settings.Discovery.Ingresses.Selector = "!"
+	settings.Discovery.Ingresses.Monitor.SLO = 120
+	settings.CRD.StatusInterval = 0
+	err = settings.Validate()
+	for _, expected := range []string{"duplicate target 'web'", "invalid selector 'app in (shop'", "path 'health' must start with '/'",
+		"discovery.ingresses: invalid selector '!'", "discovery.ingresses: slo must be between 0 and 100",
+		"crd.statusInterval must be positive"} {
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %v", expected, err)
+		}
+	}
+}
+
+func TestBootstrapWithoutKubernetes(t *testing.T) {
+	previous, logger := config.AppSettings, slog.Default()
+	defer func() {
+		config.AppSettings = previous
+		slog.SetDefault(logger)
+	}()
+	t.Setenv("STORE_DRIVER", "memory")
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "missing"))
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+
+	config.Bootstrap()
+	if config.Clientset != nil || config.DynamicClient != nil {
+		t.Fatal("expected no Kubernetes clients without a cluster")
+	}
+}
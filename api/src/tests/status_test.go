@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"slices"
+	"testing"
+
+	"iammati/statuspage/config"
+	"iammati/statuspage/db"
+	"iammati/statuspage/handlers"
+	"iammati/statuspage/monitors"
+	"iammati/statuspage/scheduler"
+	"iammati/statuspage/status"
+	"iammati/statuspage/store"
+)
+
+func TestStatusComponent(t *testing.T) {
+	states := map[string]status.MonitorState{
+		"web-1": {Up: true},
+		"web-2": {Up: false},
+		"db":    {Up: true, Flapping: true},
+	}
+
+	cases := []struct {
+		monitors []string
+		expected string
+	}{
+		{[]string{"web-1"}, status.Operational},
+		{[]string{"web-1", "web-2"}, status.PartialOutage},
+		{[]string{"web-2"}, status.MajorOutage},
+		{[]string{"db", "web-1"}, status.Degraded},
+		{[]string{"missing"}, status.Unknown},
+	}
+	for _, c := range cases {
+		if actual := status.Component(c.monitors, states, nil); actual != c.expected {
+			t.Errorf("expected %v to be %s, got %s", c.monitors, c.expected, actual)
+		}
+	}
+
+	incidents := []db.Incident{
+		{Status: db.IncidentOpen, Severity: db.SeverityCritical, Monitors: []string{"web-1"}},
+		{Status: db.IncidentResolved, Severity: db.SeverityCritical, Monitors: []string{"db"}},
+	}
+	if actual := status.Component([]string{"web-1"}, states, incidents); actual != status.MajorOutage {
+		t.Errorf("expected a critical incident to cause a major outage, got %s", actual)
+	}
+	if actual := status.Component([]string{"db"}, states, incidents); actual != status.Degraded {
+		t.Errorf("expected resolved incidents to be ignored, got %s", actual)
+	}
+}
+
+func TestStatusOverall(t *testing.T) {
+	if actual := status.Overall([]string{status.Unknown, status.Operational, status.Degraded}); actual != status.Degraded {
+		t.Fatalf("expected degraded, got %s", actual)
+	}
+	if actual := status.Overall([]string{status.Unknown}); actual != status.Unknown {
+		t.Fatalf("expected unknown, got %s", actual)
+	}
+}
+
+func TestStatusMonitorComponents(t *testing.T) {
+	previousStore, previousScheduler := config.Store, handlers.Scheduler
+	config.Store = store.NewMemory()
+	handlers.Scheduler = scheduler.New(scheduler.Options{})
+	defer func() { config.Store, handlers.Scheduler = previousStore, previousScheduler }()
+
+	file, err := monitors.Parse("monitors.yaml", []byte(`groups:
+  - name: web
+monitors:
+  - name: shop
+    host: shop.example.com
+components:
+  - name: Shop
+    group: web
+    monitors: [shop]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlers.SyncComponents(file)
+
+	registry := handlers.MonitorRegistry{}
+	registry.Upsert(scheduler.Monitor{Host: "cart.example.com", Group: "web", Component: "Shop"})
+	registry.Upsert(scheduler.Monitor{Host: "api.example.com", Group: "api", Component: "API"})
+
+	components := func() map[string]db.Component {
+		stored, err := config.Store.Components()
+		if err != nil {
+			t.Fatal(err)
+		}
+		byName := map[string]db.Component{}
+		for _, component := range stored {
+			byName[component.Name] = component
+		}
+		return byName
+	}
+	byName := components()
+	if !slices.Equal(byName["Shop"].Monitors, []string{"shop", "cart.example.com"}) {
+		t.Fatalf("expected the monitor to join the declared component, got %+v", byName["Shop"])
+	}
+	if api := byName["API"]; api.Group != "api" || !slices.Equal(api.Monitors, []string{"api.example.com"}) {
+		t.Fatalf("expected a component for the monitor, got %+v", api)
+	}
+
+	// Reloading the file keeps the memberships of the monitors.
+	handlers.SyncComponents(file)
+	if !slices.Equal(components()["Shop"].Monitors, []string{"shop", "cart.example.com"}) {
+		t.Fatalf("expected the membership to survive a reload, got %+v", components()["Shop"])
+	}
+
+	registry.Remove("api.example.com")
+	if _, ok := components()["API"]; ok {
+		t.Fatal("expected the component to be removed along with its last monitor")
+	}
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"iammati/statuspage/db"
+	"iammati/statuspage/store"
+)
+
+func TestMemoryStoreProbeResults(t *testing.T) {
+	memory := store.NewMemory()
+	start := time.Date(2024, 11, 21, 12, 0, 0, 0, time.UTC)
+
+	for i, up := range []bool{true, false, true, true} {
+		_, err := memory.InsertProbeResult(db.ProbeResult{
+			Monitor:   "shop",
+			StartedAt: start.Add(time.Duration(i) * 30 * time.Second),
+			Up:        up,
+			HttpTime:  time.Duration(i+1) * 10 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	memory.InsertProbeResult(db.ProbeResult{Monitor: "blog", StartedAt: start, Up: true})
+
+	results, err := memory.ProbeResults("shop", start, start.Add(time.Hour), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || !results[0].StartedAt.After(results[1].StartedAt) {
+		t.Fatalf("expected the 3 newest results first, got %+v", results)
+	}
+
+	buckets, err := memory.ProbeResultBuckets("shop", start, start.Add(time.Hour), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 2 {
+		t.Fatalf("expected 2 buckets, got %d", len(buckets))
+	}
+	if buckets[0].Count != 2 || buckets[0].UpCount != 1 || buckets[0].HttpTime != 15*time.Millisecond {
+		t.Fatalf("unexpected first bucket: %+v", buckets[0])
+	}
+}
+
+func TestMemoryStoreIncidents(t *testing.T) {
+	var s store.Store = store.NewMemory()
+	now := time.Now()
+
+	first, _ := s.CreateIncident(db.Incident{Title: "shop down", Status: db.IncidentOpen, Monitors: []string{"shop"}, StartedAt: now.Add(-time.Hour)})
+	s.CreateIncident(db.Incident{Title: "blog down", Status: db.IncidentOpen, Monitors: []string{"blog"}, StartedAt: now})
+
+	incident, err := s.Incident(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	incident.Status = db.IncidentResolved
+	incident.ResolvedAt = now
+	if err := s.UpdateIncident(incident); err != nil {
+		t.Fatal(err)
+	}
+
+	open, _ := s.Incidents(db.IncidentFilter{Status: db.IncidentOpen})
+	if len(open) != 1 || open[0].Title != "blog down" {
+		t.Fatalf("expected only the blog incident to be open, got %+v", open)
+	}
+
+	shop, _ := s.Incidents(db.IncidentFilter{Monitor: "shop"})
+	if len(shop) != 1 || shop[0].Status != db.IncidentResolved {
+		t.Fatalf("expected the resolved shop incident, got %+v", shop)
+	}
+
+	if _, err := s.Incident(42); err != db.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStoreMonitors(t *testing.T) {
+	var s store.Store = store.NewMemory()
+
+	s.UpsertMonitor(db.Monitor{Name: "shop", Host: "shop.example.com"})
+	s.UpsertMonitor(db.Monitor{Name: "blog", Host: "blog.example.com"})
+	s.UpsertMonitor(db.Monitor{Name: "shop", Host: "shop.example.org"})
+	s.DeleteMonitor("blog")
+
+	monitors, _ := s.Monitors()
+	if len(monitors) != 1 || monitors[0].Host != "shop.example.org" {
+		t.Fatalf("unexpected monitors: %+v", monitors)
+	}
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"iammati/statuspage/tracing"
+	"iammati/statuspage/websocket"
+
+	gorilla "github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	traceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	traceparent = "00-" + traceID + "-00f067aa0ba902b7-01"
+)
+
+var (
+	spanRecorderOnce sync.Once
+	spanRecorder     *tracetest.SpanRecorder
+)
+
+// recordSpans installs a recording tracer provider. Tracers created before
+// bind to the first provider only, so every test shares the recorder.
+func recordSpans() *tracetest.SpanRecorder {
+	spanRecorderOnce.Do(func() {
+		spanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	return spanRecorder
+}
+
+func findSpan(recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	for _, span := range recorder.Ended() {
+		if span.Name() == name && span.SpanContext().TraceID().String() == traceID {
+			return span
+		}
+	}
+	return nil
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	recorder := recordSpans()
+
+	var handlerTraceID string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlerTraceID = trace.SpanContextFromContext(r.Context()).TraceID().String()
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	request := httptest.NewRequest("GET", "/api/v1/things/42", nil)
+	request.Header.Set("traceparent", traceparent)
+	tracing.Middleware(mux).ServeHTTP(httptest.NewRecorder(), request)
+
+	if handlerTraceID != traceID {
+		t.Fatalf("expected the handler to continue the trace, got %q", handlerTraceID)
+	}
+	span := findSpan(recorder, "GET /api/v1/things/{id}")
+	if span == nil {
+		t.Fatalf("expected a span named after the route, got %d spans", len(recorder.Ended()))
+	}
+	if span.SpanKind() != trace.SpanKindServer || span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("expected a server span below the remote parent, got %v below %s", span.SpanKind(), span.Parent().SpanID())
+	}
+	for _, attribute := range span.Attributes() {
+		if attribute.Key == "http.response.status_code" && attribute.Value.AsInt64() != http.StatusTeapot {
+			t.Fatalf("expected the response status, got %d", attribute.Value.AsInt64())
+		}
+	}
+}
+
+func TestTracingWebSocketMessages(t *testing.T) {
+	recorder := recordSpans()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", websocket.Handle)
+	server := httptest.NewServer(tracing.Middleware(mux))
+	defer server.Close()
+
+	conn, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("expected the upgrade to pass the middleware, got %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON(websocket.Message{API: "unknown/command", Traceparent: traceparent}); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, reply, err := conn.ReadMessage(); err != nil || !strings.Contains(string(reply), "Unknown API command") {
+		t.Fatalf("expected an error reply, got %q: %v", reply, err)
+	}
+
+	// The span ends right after the reply is sent.
+	deadline := time.Now().Add(time.Second)
+	for findSpan(recorder, "websocket unknown/command") == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the message to continue the client's trace")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"iammati/statuspage/db"
+	"iammati/statuspage/store"
+	"iammati/statuspage/uptime"
+)
+
+func TestUptimeCompute(t *testing.T) {
+	start := time.Date(2024, 11, 21, 0, 0, 0, 0, time.UTC)
+	transitions := []db.StateTransition{
+		{At: start.Add(-time.Hour), Up: true},
+		{At: start.Add(6 * time.Hour), Up: false},
+		{At: start.Add(12 * time.Hour), Up: true},
+	}
+
+	availability := uptime.Compute(transitions, start, start.Add(24*time.Hour))
+	if availability.Up != 18*time.Hour || availability.Down != 6*time.Hour {
+		t.Fatalf("unexpected availability: %+v", availability)
+	}
+	if ratio, ok := availability.Ratio(); !ok || ratio != 0.75 {
+		t.Fatalf("expected 75%% availability, got %v", ratio)
+	}
+
+	// Time before the first transition is unknown rather than down.
+	unknown := uptime.Compute(transitions[1:], start, start.Add(24*time.Hour))
+	if unknown.Known() != 18*time.Hour {
+		t.Fatalf("expected 18h of known time, got %s", unknown.Known())
+	}
+	if _, ok := uptime.Compute(nil, start, start.Add(time.Hour)).Ratio(); ok {
+		t.Fatal("expected no ratio without transitions")
+	}
+}
+
+func TestUptimeErrorBudget(t *testing.T) {
+	availability := uptime.Availability{Up: 999 * time.Minute, Down: 2 * time.Minute}
+	budget := uptime.ErrorBudget(availability, 99.9)
+
+	if math.Abs(budget.BurnRate-2) > 0.01 {
+		t.Fatalf("expected a burn rate of about 2, got %v", budget.BurnRate)
+	}
+	if budget.Remaining >= 0 {
+		t.Fatalf("expected the budget to be exceeded, got %v", budget.Remaining)
+	}
+}
+
+func TestUptimeCalculatorReport(t *testing.T) {
+	memory := store.NewMemory()
+	now := time.Date(2024, 11, 21, 12, 0, 0, 0, time.UTC)
+
+	memory.InsertStateTransition(db.StateTransition{Monitor: "shop", At: now.AddDate(0, -4, 0), Up: true})
+	memory.InsertStateTransition(db.StateTransition{Monitor: "shop", At: now.Add(-2 * time.Hour), Up: false})
+	memory.InsertStateTransition(db.StateTransition{Monitor: "shop", At: now.Add(-time.Hour), Up: true})
+	memory.InsertStateTransition(db.StateTransition{Monitor: "blog", At: now.Add(-12 * time.Hour), Up: true})
+
+	calculator := uptime.Calculator{Store: memory, Now: func() time.Time { return now }}
+	report, err := calculator.Report([]string{"shop"}, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := report.Windows[0]
+	if day.Name != "24h" || day.Down != time.Hour || day.Up != 23*time.Hour || day.Budget.Target != uptime.DefaultSLOTarget {
+		t.Fatalf("unexpected 24h window: %+v", day)
+	}
+	if len(report.Months) != 2 || report.Months[0].Name != "2024-11" || report.Months[1].Known() != 31*24*time.Hour {
+		t.Fatalf("unexpected calendar months: %+v", report.Months)
+	}
+
+	group, _ := calculator.Report([]string{"shop", "blog"}, 99, 0)
+	if group.Windows[0].Known() != 36*time.Hour || group.Windows[0].Down != time.Hour {
+		t.Fatalf("unexpected combined 24h window: %+v", group.Windows[0])
+	}
+}
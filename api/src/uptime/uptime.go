@@ -0,0 +1,222 @@
+package uptime
+
+import (
+	"time"
+
+	"iammati/statuspage/db"
+	"iammati/statuspage/maintenance"
+	"iammati/statuspage/store"
+)
+
+// DefaultSLOTarget applies to monitors that don't define an objective.
+const DefaultSLOTarget = 99.9
+
+// Window is a named period availability is reported for.
+type Window struct {
+	Name string
+	From time.Time
+	To   time.Time
+}
+
+// RollingWindows are the trailing periods ending at now.
+func RollingWindows(now time.Time) []Window {
+	return []Window{
+		{Name: "24h", From: now.Add(-24 * time.Hour), To: now},
+		{Name: "7d", From: now.AddDate(0, 0, -7), To: now},
+		{Name: "30d", From: now.AddDate(0, 0, -30), To: now},
+		{Name: "90d", From: now.AddDate(0, 0, -90), To: now},
+	}
+}
+
+// CalendarMonths returns the current and the previous months, newest first.
+// The current month ends at now.
+func CalendarMonths(now time.Time, count int) []Window {
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	windows := make([]Window, 0, count)
+	for i := 0; i < count; i++ {
+		from := start.AddDate(0, -i, 0)
+		to := from.AddDate(0, 1, 0)
+		if to.After(now) {
+			to = now
+		}
+		windows = append(windows, Window{Name: from.Format("2006-01"), From: from, To: to})
+	}
+	return windows
+}
+
+// Availability is how long a monitor was known to be up and down within a
+// window. Time before the first recorded transition counts as neither.
+type Availability struct {
+	Up   time.Duration
+	Down time.Duration
+}
+
+func (a Availability) Known() time.Duration {
+	return a.Up + a.Down
+}
+
+// Ratio returns the fraction of known time the monitor was up, and false
+// if nothing is known about the window at all.
+func (a Availability) Ratio() (float64, bool) {
+	if a.Known() == 0 {
+		return 0, false
+	}
+	return float64(a.Up) / float64(a.Known()), true
+}
+
+// Add combines the availability of several monitors, weighted by time.
+func (a Availability) Add(other Availability) Availability {
+	return Availability{Up: a.Up + other.Up, Down: a.Down + other.Down}
+}
+
+// Compute replays the transitions, sorted oldest first, over [from, to).
+// Time within the excluded intervals, e.g. maintenance, counts as neither
+// up nor down; they must not overlap each other.
+func Compute(transitions []db.StateTransition, from, to time.Time, excluded ...maintenance.Interval) Availability {
+	var availability Availability
+	for i, transition := range transitions {
+		start := transition.At
+		if start.Before(from) {
+			start = from
+		}
+		end := to
+		if i+1 < len(transitions) && transitions[i+1].At.Before(to) {
+			end = transitions[i+1].At
+		}
+		if !end.After(start) {
+			continue
+		}
+
+		duration := end.Sub(start) - overlap(start, end, excluded)
+		if transition.Up {
+			availability.Up += duration
+		} else {
+			availability.Down += duration
+		}
+	}
+	return availability
+}
+
+func overlap(start, end time.Time, intervals []maintenance.Interval) time.Duration {
+	var total time.Duration
+	for _, interval := range intervals {
+		from, to := interval.Start, interval.End
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		if to.After(from) {
+			total += to.Sub(from)
+		}
+	}
+	return total
+}
+
+// Budget is the error budget of an SLO target over a window.
+type Budget struct {
+	// Target is the availability objective in percent.
+	Target float64
+	// Allowed is the downtime the target permits for the known time.
+	Allowed time.Duration
+	// Consumed is the actual downtime.
+	Consumed time.Duration
+	// Remaining is the fraction of the budget left, negative once exceeded.
+	Remaining float64
+	// BurnRate is how fast the budget is spent, 1 meaning exactly on target.
+	BurnRate float64
+}
+
+func ErrorBudget(availability Availability, target float64) Budget {
+	budget := Budget{Target: target, Consumed: availability.Down, Remaining: 1}
+
+	allowedRatio := 1 - target/100
+	budget.Allowed = time.Duration(float64(availability.Known()) * allowedRatio)
+	if availability.Known() == 0 || allowedRatio <= 0 {
+		return budget
+	}
+
+	downRatio := float64(availability.Down) / float64(availability.Known())
+	budget.BurnRate = downRatio / allowedRatio
+	budget.Remaining = 1 - budget.BurnRate
+	return budget
+}
+
+// Report is the availability of a monitor or group over several windows.
+type Report struct {
+	Windows []WindowReport
+	Months  []WindowReport
+}
+
+type WindowReport struct {
+	Window
+	Availability
+	Budget Budget
+}
+
+// Calculator builds reports from the transitions kept in the store.
+type Calculator struct {
+	Store store.TransitionStore
+	// Now defaults to time.Now.
+	Now func() time.Time
+	// Excluded returns the maintenance of a monitor within [from, to),
+	// merged into non-overlapping intervals. Optional.
+	Excluded func(monitor string, from, to time.Time) []maintenance.Interval
+}
+
+// Report computes the availability of the given monitors, combined, for the
+// rolling windows and the given number of calendar months.
+func (c Calculator) Report(monitors []string, target float64, months int) (Report, error) {
+	now := time.Now()
+	if c.Now != nil {
+		now = c.Now()
+	}
+	if target <= 0 {
+		target = DefaultSLOTarget
+	}
+
+	windows := RollingWindows(now)
+	calendar := CalendarMonths(now, months)
+
+	from := windows[len(windows)-1].From
+	if len(calendar) > 0 && calendar[len(calendar)-1].From.Before(from) {
+		from = calendar[len(calendar)-1].From
+	}
+
+	type history struct {
+		transitions []db.StateTransition
+		excluded    []maintenance.Interval
+	}
+	histories := make([]history, 0, len(monitors))
+	for _, monitor := range monitors {
+		transitions, err := c.Store.StateTransitions(monitor, from, now)
+		if err != nil {
+			return Report{}, err
+		}
+		entry := history{transitions: transitions}
+		if c.Excluded != nil {
+			entry.excluded = c.Excluded(monitor, from, now)
+		}
+		histories = append(histories, entry)
+	}
+
+	build := func(windows []Window) []WindowReport {
+		reports := make([]WindowReport, 0, len(windows))
+		for _, window := range windows {
+			var availability Availability
+			for _, entry := range histories {
+				availability = availability.Add(Compute(entry.transitions, window.From, window.To, entry.excluded...))
+			}
+			reports = append(reports, WindowReport{
+				Window:       window,
+				Availability: availability,
+				Budget:       ErrorBudget(availability, target),
+			})
+		}
+		return reports
+	}
+
+	return Report{Windows: build(windows), Months: build(calendar)}, nil
+}
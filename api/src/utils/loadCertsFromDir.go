@@ -3,7 +3,7 @@ package utils
 import (
 	"crypto/x509"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 )
@@ -13,7 +13,7 @@ func LoadCertsFromDir(certDir string) (*x509.CertPool, error) {
 	certPool, err := x509.SystemCertPool()
 	if err != nil || certPool == nil {
 		certPool = x509.NewCertPool()
-		log.Println("Using new cert pool.")
+		slog.Warn("System certificates unavailable, using an empty cert pool")
 	}
 
 	// Read all files from the specified directory
@@ -31,12 +31,12 @@ func LoadCertsFromDir(certDir string) (*x509.CertPool, error) {
 		certPath := filepath.Join(certDir, fileInfo.Name())
 		certBytes, err := os.ReadFile(certPath)
 		if err != nil {
-			log.Printf("Failed to read %s: %v", certPath, err)
+			slog.Warn("Failed to read certificate", "path", certPath, "error", err)
 			continue // Log the error and move on to the next file
 		}
 
 		if ok := certPool.AppendCertsFromPEM(certBytes); !ok {
-			log.Printf("Failed to append %s to cert pool", certPath)
+			slog.Warn("Failed to append certificate to cert pool", "path", certPath)
 		}
 	}
 
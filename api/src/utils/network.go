@@ -1,18 +1,28 @@
 package utils
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
 	"iammati/statuspage/config"
+	"iammati/statuspage/tracing"
 )
 
+var tracer = otel.Tracer("iammati/statuspage/utils")
+
 type Metrics struct {
 	DnsResolutionTime time.Duration
 	TcpConnectionTime time.Duration
@@ -23,35 +33,79 @@ type Metrics struct {
 	Error             error
 }
 
+// ResponseTime is how long the probe took through all its phases.
+func (m Metrics) ResponseTime() time.Duration {
+	return m.DnsResolutionTime + m.TcpConnectionTime + m.TlsConnectionTime + m.HttpTime
+}
+
 func HostMetrics(hostname string, path string) (Metrics, error) {
-	var metrics Metrics
-	host, port, err := net.SplitHostPort(hostname)
-	if err != nil {
+	return HostMetricsWithTimeout(hostname, path, 5*time.Second)
+}
+
+// HostMetricsWithTimeout probes hostname like HostMetrics, bounding the TCP
+// dial and the HTTP request by the given timeout.
+func HostMetricsWithTimeout(hostname string, path string, timeout time.Duration) (Metrics, error) {
+	return HostMetricsContext(context.Background(), hostname, path, timeout)
+}
+
+// HostMetricsContext probes hostname as part of the trace in ctx, with a
+// span for the probe and one per phase.
+func HostMetricsContext(ctx context.Context, hostname string, path string, timeout time.Duration) (metrics Metrics, err error) {
+	host, port, splitErr := net.SplitHostPort(hostname)
+	if splitErr != nil {
 		host = hostname
 		port = "443"
 	}
 
+	ctx, span := tracer.Start(ctx, "probe", trace.WithAttributes(
+		semconv.ServerAddress(host),
+		attribute.String("url.path", path),
+	))
+	defer func() {
+		span.SetAttributes(attribute.Bool("probe.reachable", metrics.Reachable))
+		if metrics.StatusCode != 0 {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(metrics.StatusCode))
+		}
+		failure := err
+		if failure == nil {
+			failure = metrics.Error
+		}
+		tracing.RecordError(span, failure)
+		span.End()
+	}()
+
 	// DNS Resolution
 	start := time.Now()
-	ips, err := net.LookupIP(host)
+	_, phase := tracer.Start(ctx, "probe.dns")
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
 	metrics.DnsResolutionTime = time.Since(start)
 	if err != nil || len(ips) == 0 {
 		metrics.Reachable = false
-		return metrics, fmt.Errorf("DNS resolution failed for %s", host)
+		err = fmt.Errorf("DNS resolution failed for %s", host)
+		tracing.RecordError(phase, err)
+		phase.End()
+		return metrics, err
 	}
+	phase.End()
 
 	// TCP Connection
 	resolvedHost := net.JoinHostPort(ips[0].String(), port)
-	conn, err := net.DialTimeout("tcp", resolvedHost, 5*time.Second)
+	_, phase = tracer.Start(ctx, "probe.tcp", trace.WithAttributes(semconv.NetworkPeerAddress(ips[0].String())))
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", resolvedHost)
 	metrics.TcpConnectionTime = time.Since(start) - metrics.DnsResolutionTime
 	if err != nil {
 		metrics.Reachable = false
-		return metrics, fmt.Errorf("TCP connection failed for %s", resolvedHost)
+		err = fmt.Errorf("TCP connection failed for %s", resolvedHost)
+		tracing.RecordError(phase, err)
+		phase.End()
+		return metrics, err
 	}
+	phase.End()
 	// Do not close the TCP connection here; we need it for the TLS handshake
 
 	// Load custom CA certificates
-	caCertPool, err := LoadCertsFromDir("/usr/local/share/ca-certificates")
+	caCertPool, err := LoadCertsFromDir(config.AppSettings.CACertDir)
 	if err != nil {
 		metrics.Reachable = false
 		conn.Close() // Close the connection in case of error
@@ -60,17 +114,24 @@ func HostMetrics(hostname string, path string) (Metrics, error) {
 
 	// TLS Handshake
 	tlsStart := time.Now()
+	_, phase = tracer.Start(ctx, "probe.tls")
 	tlsConn := tls.Client(conn, &tls.Config{
 		ServerName: host,
 		RootCAs:    caCertPool,
 	})
-	err = tlsConn.Handshake()
+	handshakeCtx, cancel := context.WithTimeout(ctx, timeout)
+	err = tlsConn.HandshakeContext(handshakeCtx)
+	cancel()
 	metrics.TlsConnectionTime = time.Since(tlsStart)
 	if err != nil {
 		metrics.Reachable = false
 		conn.Close() // Close the connection in case of error
-		return metrics, fmt.Errorf("TLS handshake failed for %s: %v", resolvedHost, err)
+		err = fmt.Errorf("TLS handshake failed for %s: %v", resolvedHost, err)
+		tracing.RecordError(phase, err)
+		phase.End()
+		return metrics, err
 	}
+	phase.End()
 	defer tlsConn.Close() // Close the TLS connection after successful handshake
 
 	// Create HTTP client with custom transport
@@ -80,19 +141,38 @@ func HostMetrics(hostname string, path string) (Metrics, error) {
 				RootCAs: caCertPool,
 			},
 		},
-		Timeout: 5 * time.Second,
+		Timeout: timeout,
 	}
 
 	// HTTP Request
 	httpStart := time.Now()
 	URL := "https://" + host + path
-	response, err := client.Get(URL)
+	if port != "443" {
+		URL = "https://" + net.JoinHostPort(host, port) + path
+	}
+	httpCtx, phase := tracer.Start(ctx, "probe.http", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(semconv.URLFull(URL)))
+	defer phase.End()
+	request, err := http.NewRequestWithContext(httpCtx, http.MethodGet, URL, nil)
+	if err != nil {
+		metrics.Reachable = false
+		return metrics, fmt.Errorf("invalid URL %s: %v", "'"+URL+"'", err)
+	}
+	otel.GetTextMapPropagator().Inject(httpCtx, propagation.HeaderCarrier(request.Header))
+	response, err := client.Do(request)
 	metrics.HttpTime = time.Since(httpStart)
-	if err != nil || response.StatusCode >= 400 {
+	if err != nil {
 		metrics.Reachable = false
 		metrics.Error = fmt.Errorf("HTTP request failed for %s.\nReason: %s", "'"+URL+"'", err)
+		tracing.RecordError(phase, metrics.Error)
+		return metrics, nil
 	}
 	defer response.Body.Close()
+	phase.SetAttributes(semconv.HTTPResponseStatusCode(response.StatusCode))
+
+	if response.StatusCode >= 400 {
+		metrics.Reachable = false
+		metrics.Error = fmt.Errorf("HTTP request failed for %s.\nReason: status code %d", "'"+URL+"'", response.StatusCode)
+	}
 
 	if (response.StatusCode >= 200 && response.StatusCode < 400) || response.StatusCode == 0 {
 		metrics.Reachable = true
@@ -102,15 +182,83 @@ func HostMetrics(hostname string, path string) (Metrics, error) {
 	return metrics, nil
 }
 
+// CertificateMetrics checks the certificate of hostname with a TLS handshake
+// bounded by timeout. The host is reachable if the handshake verifies its
+// chain and name; path is ignored.
+func CertificateMetrics(hostname string, path string, timeout time.Duration) (Metrics, error) {
+	return CertificateMetricsContext(context.Background(), hostname, timeout)
+}
+
+// CertificateMetricsContext checks the certificate of hostname as part of the
+// trace in ctx.
+func CertificateMetricsContext(ctx context.Context, hostname string, timeout time.Duration) (metrics Metrics, err error) {
+	host, port, splitErr := net.SplitHostPort(hostname)
+	if splitErr != nil {
+		host = hostname
+		port = "443"
+	}
+
+	ctx, span := tracer.Start(ctx, "probe.certificate", trace.WithAttributes(semconv.ServerAddress(host)))
+	defer func() {
+		span.SetAttributes(attribute.Bool("probe.reachable", metrics.Reachable))
+		failure := err
+		if failure == nil {
+			failure = metrics.Error
+		}
+		tracing.RecordError(span, failure)
+		span.End()
+	}()
+
+	caCertPool, err := LoadCertsFromDir(config.AppSettings.CACertDir)
+	if err != nil {
+		return metrics, fmt.Errorf("failed to load custom CA certificates.\nReason: %s", err)
+	}
+
+	start := time.Now()
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config: &tls.Config{
+			ServerName: host,
+			RootCAs:    caCertPool,
+		},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	metrics.TlsConnectionTime = time.Since(start)
+	if err != nil {
+		metrics.Error = fmt.Errorf("Certificate check failed for %s.\nReason: %s", "'"+host+"'", err)
+		return metrics, nil
+	}
+	defer conn.Close()
+
+	certificates := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certificates) > 0 {
+		span.SetAttributes(attribute.String("certificate.expiry", certificates[0].NotAfter.Format(time.RFC3339)))
+	}
+	metrics.Reachable = true
+	return metrics, nil
+}
+
 func FetchCertInfo(host string) ([]CertInfo, error) {
+	return FetchCertInfoContext(context.Background(), host)
+}
+
+// FetchCertInfoContext fetches the certificate chain of host as part of the
+// trace in ctx.
+func FetchCertInfoContext(ctx context.Context, host string) ([]CertInfo, error) {
 	hostName := strings.Split(host, ":")[0]
-	conn, err := tls.Dial("tcp", host, &tls.Config{
+	ctx, span := tracer.Start(ctx, "certinfo", trace.WithAttributes(semconv.ServerAddress(hostName)))
+	defer span.End()
+
+	dialer := &tls.Dialer{Config: &tls.Config{
 		RootCAs:    config.RootCAs,
 		ServerName: hostName,
-	})
+	}}
+	netConn, err := dialer.DialContext(ctx, "tcp", host)
 	if err != nil {
+		tracing.RecordError(span, err)
 		return nil, err
 	}
+	conn := netConn.(*tls.Conn)
 	defer conn.Close()
 
 	var certInfos []CertInfo
@@ -129,9 +277,38 @@ func FetchCertInfo(host string) ([]CertInfo, error) {
 			WildcardNames: wildcardNames,
 		})
 	}
+	span.SetAttributes(attribute.Int("certinfo.certificates", len(certInfos)))
 	return certInfos, nil
 }
 
+// CertificateExpiryContext returns when the leaf certificate of host
+// expires. The chain isn't verified, so expired and untrusted certificates
+// are read as well.
+func CertificateExpiryContext(ctx context.Context, host string) (time.Time, error) {
+	hostName := strings.Split(host, ":")[0]
+	ctx, span := tracer.Start(ctx, "certinfo", trace.WithAttributes(semconv.ServerAddress(hostName)))
+	defer span.End()
+
+	dialer := &tls.Dialer{Config: &tls.Config{
+		ServerName:         hostName,
+		InsecureSkipVerify: true,
+	}}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return time.Time{}, err
+	}
+	defer conn.Close()
+
+	certificates := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certificates) == 0 {
+		err := fmt.Errorf("%s presented no certificate", host)
+		tracing.RecordError(span, err)
+		return time.Time{}, err
+	}
+	return certificates[0].NotAfter, nil
+}
+
 func HttpError(w http.ResponseWriter, errorMsg string, code int) {
 	http.Error(w, errorMsg, code)
 }
@@ -140,12 +317,12 @@ func JsonResponse(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	jsonData, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
-		log.Printf("Failed to marshal JSON: %v", err)
+		slog.Error("Failed to marshal JSON response", "error", err)
 		return
 	}
 	_, writeErr := w.Write(jsonData)
 	if writeErr != nil {
-		log.Printf("Failed to write JSON response: %v", writeErr)
+		slog.Debug("Failed to write JSON response", "error", writeErr)
 	}
 }
 
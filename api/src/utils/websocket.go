@@ -1,7 +1,7 @@
 package utils
 
 import (
-	"log"
+	"log/slog"
 
 	"github.com/gorilla/websocket"
 )
@@ -9,7 +9,7 @@ import (
 func SendMessage(ws *websocket.Conn, message string) error {
 	err := ws.WriteMessage(websocket.TextMessage, []byte(message))
 	if err != nil {
-		log.Printf("Error sending message to WebSocket client: %v", err)
+		slog.Warn("Failed to send message to WebSocket client", "error", err)
 		return err
 	}
 	return nil
@@ -1,50 +1,164 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"sync"
 
+	"iammati/statuspage/config"
+	"iammati/statuspage/db"
+	"iammati/statuspage/handlers"
 	"iammati/statuspage/handlers/k8s"
+	"iammati/statuspage/tracing"
 	"iammati/statuspage/utils"
 
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("iammati/statuspage/websocket")
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 	CheckOrigin:     func(r *http.Request) bool { return true }, // Allow all origins
 }
 
+// Message is a request from a client. Traceparent and Tracestate optionally
+// carry W3C trace context, continuing the client's trace.
 type Message struct {
 	API       string `json:"api"`
 	Namespace string `json:"namespace,omitempty"`
+	// Filter narrows down logs/tail, using the query parameters of /api/v1/logs.
+	Filter      map[string]string `json:"filter,omitempty"`
+	Traceparent string            `json:"traceparent,omitempty"`
+	Tracestate  string            `json:"tracestate,omitempty"`
+}
+
+// Event is pushed to every connected client, e.g. when an incident changes.
+type Event struct {
+	API     string      `json:"api"`
+	Payload interface{} `json:"payload"`
+}
+
+// client serializes writes, since a connection supports one writer at a time.
+type client struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+	// stopTail ends the live tail of the logs, if the client subscribed.
+	stopTail func()
+}
+
+// tailBuffer is how many log entries may queue up for a client; a slow
+// client misses entries rather than stalling the log writer.
+const tailBuffer = 256
+
+// tail sends new log entries matching filter as logs/entry events,
+// replacing any previous tail.
+func (c *client) tail(filter db.LogFilter) {
+	c.untail()
+
+	entries := make(chan db.LogEntry, tailBuffer)
+	unsubscribe := config.Store.SubscribeLogs(func(entry db.LogEntry) {
+		if !filter.Matches(entry) {
+			return
+		}
+		select {
+		case entries <- entry:
+		default:
+		}
+	})
+	go func() {
+		for entry := range entries {
+			if err := c.sendJSON(Event{API: "logs/entry", Payload: handlers.LogView(entry)}); err != nil {
+				slog.Debug("Failed to send log entry", "error", err)
+			}
+		}
+	}()
+	c.stopTail = func() {
+		// No listener runs once unsubscribe returns.
+		unsubscribe()
+		close(entries)
+	}
 }
 
-var clients = make(map[*websocket.Conn]bool) // Connected clients
-var broadcast = make(chan Message)           // Broadcast channel
+func (c *client) untail() {
+	if c.stopTail != nil {
+		c.stopTail()
+		c.stopTail = nil
+	}
+}
+
+func (c *client) send(message string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return utils.SendMessage(c.conn, message)
+}
+
+func (c *client) sendJSON(value interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.conn.WriteJSON(value)
+}
+
+var clients = make(map[*websocket.Conn]*client) // Connected clients
+var broadcast = make(chan Event, 64)            // Broadcast channel
 var mutex = &sync.Mutex{}
 
+// Publish broadcasts an event to all connected clients. Events are dropped
+// rather than blocking the caller when the broadcast routine falls behind.
+func Publish(api string, payload interface{}) {
+	select {
+	case broadcast <- Event{API: api, Payload: payload}:
+	default:
+		slog.Warn("Dropped WebSocket event, broadcast queue is full", "api", api)
+	}
+}
+
+// ClientCount returns the number of connected clients.
+func ClientCount() int {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return len(clients)
+}
+
 func Handle(w http.ResponseWriter, r *http.Request) {
 	// Upgrade HTTP request to a WebSocket connection
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("Failed to upgrade WebSocket connection: %v", err)
+		slog.Warn("Failed to upgrade WebSocket connection", "error", err)
 		http.Error(w, "Could not open websocket connection", http.StatusBadRequest)
 		return
 	}
 	defer ws.Close()
 
-	log.Println("New WebSocket client connected")
+	c := &client{conn: ws}
+	mutex.Lock()
+	clients[ws] = c
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		delete(clients, ws)
+		mutex.Unlock()
+		c.untail()
+	}()
+
+	slog.Debug("WebSocket client connected", "remote", r.RemoteAddr)
 
 	// Continuous message handling loop
 	for {
 		// Read message from client
 		_, message, err := ws.ReadMessage()
 		if err != nil {
-			log.Printf("Error reading message: %v", err)
+			slog.Debug("Stopped reading WebSocket messages", "remote", r.RemoteAddr, "error", err)
 			break // Exit the loop if there's an error (e.g., client disconnected)
 		}
 
@@ -52,37 +166,85 @@ func Handle(w http.ResponseWriter, r *http.Request) {
 		var msg Message
 		err = json.Unmarshal(message, &msg)
 		if err != nil {
-			log.Printf("Error parsing JSON: %v", err)
-			utils.SendMessage(ws, `{"error": "Invalid JSON format"}`)
+			slog.Warn("Invalid WebSocket message", "remote", r.RemoteAddr, "error", err)
+			c.send(`{"error": "Invalid JSON format"}`)
 			continue // Skip further processing for this message
 		}
 
-		switch msg.API {
-		case "k8s/namespaces/list":
-			response := k8s.ListNamespaces()
-			err := utils.SendMessage(ws, response)
-			if err != nil {
-				log.Printf("Error sending response: %v", err)
-			}
-		case "k8s/pods/list":
-			if msg.Namespace == "" {
-				log.Println("Missing 'namespace' in message payload")
-				utils.SendMessage(ws, `{"error": "Missing 'namespace' in message payload"}`)
-				continue // Skip further processing for this message
-			}
+		c.handle(messageContext(r.Context(), msg), msg)
+	}
 
-			response := k8s.ListPods(msg.Namespace)
-			err := utils.SendMessage(ws, response)
-			if err != nil {
-				log.Printf("Error sending response: %v", err)
-			}
-		default:
-			log.Printf("Unknown API command: %s", msg.API)
-			utils.SendMessage(ws, `{"error": "Unknown API command"}`)
-		}
+	slog.Debug("WebSocket client disconnected", "remote", r.RemoteAddr)
+}
+
+// messageContext continues the trace of a message if it carries trace
+// context, and that of the upgrade request otherwise.
+func messageContext(ctx context.Context, msg Message) context.Context {
+	if msg.Traceparent == "" {
+		return ctx
 	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier{
+		"traceparent": msg.Traceparent,
+		"tracestate":  msg.Tracestate,
+	})
+}
+
+func (c *client) handle(ctx context.Context, msg Message) {
+	ctx, span := tracer.Start(ctx, "websocket "+msg.API, trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
 
-	log.Println("WebSocket client disconnected")
+	switch msg.API {
+	case "k8s/namespaces/list":
+		response := k8s.ListNamespaces(ctx)
+		err := c.send(response)
+		if err != nil {
+			slog.Warn("Failed to send WebSocket response", "api", msg.API, "error", err)
+		}
+	case "k8s/pods/list":
+		if msg.Namespace == "" {
+			slog.Warn("Missing namespace in WebSocket message", "api", msg.API)
+			c.send(`{"error": "Missing 'namespace' in message payload"}`)
+			return
+		}
+
+		response := k8s.ListPods(ctx, msg.Namespace)
+		err := c.send(response)
+		if err != nil {
+			slog.Warn("Failed to send WebSocket response", "api", msg.API, "error", err)
+		}
+	case "incidents/list":
+		list, err := handlers.Incidents.List(db.IncidentFilter{Active: true})
+		if err != nil {
+			slog.Error("Failed to list incidents", "error", err)
+			tracing.RecordError(span, err)
+			c.send(`{"error": "Failed to list incidents"}`)
+			return
+		}
+		if err := c.sendJSON(Event{API: msg.API, Payload: list}); err != nil {
+			slog.Warn("Failed to send WebSocket response", "api", msg.API, "error", err)
+		}
+	case "logs/tail":
+		query := url.Values{}
+		for key, value := range msg.Filter {
+			query.Set(key, value)
+		}
+		filter, err := handlers.ParseLogFilter(query)
+		if err != nil {
+			response, _ := json.Marshal(map[string]string{"error": err.Error()})
+			c.send(string(response))
+			return
+		}
+		filter.Before = 0
+		c.tail(filter)
+		if err := c.sendJSON(Event{API: msg.API, Payload: msg.Filter}); err != nil {
+			slog.Warn("Failed to send WebSocket response", "api", msg.API, "error", err)
+		}
+	case "logs/untail":
+		c.untail()
+	default:
+		slog.Warn("Unknown WebSocket API command", "api", msg.API)
+		c.send(`{"error": "Unknown API command"}`)
+	}
 }
 
 func BroadcastMessages() {
@@ -92,12 +254,12 @@ func BroadcastMessages() {
 
 		// Send to every client connected
 		mutex.Lock()
-		for client := range clients {
-			err := client.WriteJSON(msg)
+		for conn, c := range clients {
+			err := c.sendJSON(msg)
 			if err != nil {
-				log.Printf("Error broadcasting message: %v", err)
-				client.Close()
-				delete(clients, client)
+				slog.Debug("Failed to broadcast WebSocket event, dropping client", "api", msg.API, "error", err)
+				conn.Close()
+				delete(clients, conn)
 			}
 		}
 		mutex.Unlock()